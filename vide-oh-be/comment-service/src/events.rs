@@ -0,0 +1,40 @@
+use diesel_async::{AsyncConnection, AsyncPgConnection};
+use lambda_runtime::{service_fn, Error, LambdaEvent};
+use serde_json::Value;
+
+use crate::repository;
+
+// Detail of the VideoDeleted event published by the video service
+#[derive(Deserialize, Debug)]
+#[serde(rename_all = "camelCase")]
+struct VideoDeleted {
+    video_id: i32,
+}
+
+// Handles the EventBridge events of the video service until the runtime stops
+pub async fn run(connection_string: String) -> Result<(), Error> {
+    lambda_runtime::run(service_fn(move |event: LambdaEvent<Value>| {
+        let connection_string = connection_string.clone();
+        async move { handle(event.payload, &connection_string).await }
+    }))
+    .await
+}
+
+async fn handle(event: Value, connection_string: &str) -> Result<(), Error> {
+    let detail_type = event["detail-type"].as_str().unwrap_or_default();
+    if detail_type != "VideoDeleted" {
+        println!("Ignoring event {}", detail_type);
+        return Ok(());
+    }
+    let deleted: VideoDeleted = serde_json::from_value(event["detail"].clone())?;
+
+    // Redelivered events find nothing left to delete
+    let mut conn = AsyncPgConnection::establish(connection_string).await?;
+    let removed_comments = repository::delete_comments_for_video(deleted.video_id, &mut conn).await?;
+    let removed_ratings = repository::delete_ratings_for_video(deleted.video_id, &mut conn).await?;
+    println!(
+        "Video {} deleted: removed {} comments and {} ratings",
+        deleted.video_id, removed_comments, removed_ratings
+    );
+    Ok(())
+}
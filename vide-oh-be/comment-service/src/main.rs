@@ -22,6 +22,7 @@ mod handler;
 mod router;
 mod auth;
 mod cors;
+mod events;
 
 pub const MIGRATIONS: EmbeddedMigrations = diesel_async_migrations::embed_migrations!();
 
@@ -47,6 +48,13 @@ async fn main() -> Result<(), LambdaError> {
         return Err(LambdaError::from(e));
     }
 
+    // COMMENT_SERVICE_MODE=events consumes the video service events
+    // instead of serving the API
+    if std::env::var("COMMENT_SERVICE_MODE").as_deref() == Ok("events") {
+        println!("Handling video events");
+        return events::run(connection_string).await;
+    }
+
     // Create and launch the Rocket application
     let rocket = router::create_routes(&connection_string).await?;
     println!("Before launching application");
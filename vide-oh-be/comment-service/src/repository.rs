@@ -84,3 +84,13 @@ pub async fn get_rating_for_user(user_email_provided: String, video_id_provided:
             Err(err) => Err(err)
         }
 }
+
+pub async fn delete_comments_for_video(video_id_provided: i32, connection: &mut AsyncPgConnection) -> QueryResult<usize> {
+    diesel::delete(comments.filter(video_id.eq(video_id_provided)))
+        .execute(connection).await
+}
+
+pub async fn delete_ratings_for_video(video_id_provided: i32, connection: &mut AsyncPgConnection) -> QueryResult<usize> {
+    diesel::delete(ratings.filter(rating_video_id.eq(video_id_provided)))
+        .execute(connection).await
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+
+	supportconfig "support-service/config"
+	supportcontrollers "support-service/controllers"
+	supportdb "support-service/database"
+	supporthealth "support-service/health"
+	supportmetrics "support-service/metrics"
+	supportopenapi "support-service/openapi"
+	supportrepository "support-service/repository"
+	supportrouter "support-service/router"
+	userapperrors "user-service/apperrors"
+	userauth "user-service/auth"
+	userconfig "user-service/config"
+	userdb "user-service/database"
+	userhealth "user-service/health"
+	userlogging "user-service/logging"
+	usermetrics "user-service/metrics"
+	usermiddleware "user-service/middleware"
+	useropenapi "user-service/openapi"
+	userpassword "user-service/password"
+	userrepository "user-service/repository"
+	userrouter "user-service/router"
+	usertracing "user-service/tracing"
+	userutils "user-service/utils"
+	videoconfig "video-service/config"
+	videocontrollers "video-service/controllers"
+	videodb "video-service/database"
+	videoeventbus "video-service/eventbus"
+	videohealth "video-service/health"
+	videometrics "video-service/metrics"
+	videoopenapi "video-service/openapi"
+	videorepository "video-service/repository"
+	videorouter "video-service/router"
+	videostorage "video-service/storage"
+	videoutils "video-service/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The dev gateway serves the users, videos and messages APIs, v1 and v2,
+// from a single process so the backend can run on a laptop. The API
+// Gateway authorizer is replaced by a direct call into user-service.
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	flag.Parse()
+
+	// Each service validates its own settings; they all read the same env
+	userCfg, err := userconfig.Load(true)
+	if err != nil {
+		log.Fatalf("user-service: %v", err)
+	}
+	videoCfg, err := videoconfig.Load(true)
+	if err != nil {
+		log.Fatalf("video-service: %v", err)
+	}
+	supportCfg, err := supportconfig.Load(true)
+	if err != nil {
+		log.Fatalf("support-service: %v", err)
+	}
+
+	slog.SetDefault(userlogging.New("dev-gateway", userCfg.LogLevel))
+	// The tracer provider is global, so one Init covers all three services
+	if err := usertracing.Init(context.Background(), "dev-gateway", userCfg.Tracing.Endpoint, userCfg.Tracing.SamplePercent); err != nil {
+		log.Fatal(err)
+	}
+	defer usertracing.Shutdown(context.Background())
+
+	useropenapi.SetMode(userCfg.OpenAPIValidation)
+	videoopenapi.SetMode(videoCfg.OpenAPIValidation)
+	supportopenapi.SetMode(supportCfg.OpenAPIValidation)
+
+	usermetrics.Init("user-service", true)
+	videometrics.Init("video-service", true)
+	supportmetrics.Init("support-service", true)
+
+	userauth.SetJwtKey(userCfg.JWTKey)
+	userutils.SetMailServer(userCfg.SMTP.Host, userCfg.SMTP.Port, userCfg.SMTP.From)
+	videoutils.SetMailServer(videoCfg.SMTP.Host, videoCfg.SMTP.Port, videoCfg.SMTP.From)
+	userpassword.SetPolicy(userCfg.Password.Policy())
+	bucket, err := videostorage.Open(videoCfg)
+	if err != nil {
+		log.Fatalf("video-service: %v", err)
+	}
+
+	// Every service keeps its own handle, all pointing at the same database
+	userdb.Open(userCfg)
+	userdb.AutoMigrate()
+	videodb.Open(videoCfg)
+	videodb.AutoMigrate()
+	supportdb.Open(supportCfg)
+	supportdb.Migrate()
+	userRepos := userrepository.New(userdb.Instance)
+	videos := videocontrollers.NewVideoController(videorepository.New(videodb.Instance), bucket, videoeventbus.Log{}, videocontrollers.Settings{
+		S3:         videoCfg.S3,
+		Media:      videoCfg.Media,
+		Search:     videoCfg.Search,
+		Moderation: videoCfg.Moderation,
+	})
+	messages := supportcontrollers.NewMessageController(supportrepository.New(supportdb.Instance))
+
+	if err := userhealth.Init(userCfg); err != nil {
+		log.Fatalf("user-service: %v", err)
+	}
+	if err := videohealth.Init(videoCfg); err != nil {
+		log.Fatalf("video-service: %v", err)
+	}
+	if err := supporthealth.Init(supportCfg); err != nil {
+		log.Fatalf("support-service: %v", err)
+	}
+
+	router := gin.New()
+	router.Use(userapperrors.Recovery(), userrouter.CORS())
+	router.NoRoute(userapperrors.NoRoute)
+	router.MaxMultipartMemory = 10 * 1024 * 1024
+
+	authorizer := newAuthorizer(userRepos.Users)
+	userrouter.Register(router, userRepos)
+	videorouter.Register(router, authorizer, videos)
+	supportrouter.Register(router, authorizer, messages)
+	router.GET("/metrics", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		usermetrics.WriteText(c.Writer)
+		videometrics.WriteText(c.Writer)
+		supportmetrics.WriteText(c.Writer)
+	})
+
+	routes := router.Routes()
+	problems := useropenapi.CheckRoutes(routes, "/api/users", "/api/v2/users")
+	problems = append(problems, videoopenapi.CheckRoutes(routes, "/api/videos", "/api/v2/videos")...)
+	problems = append(problems, supportopenapi.CheckRoutes(routes, "/api/messages", "/api/v2/messages")...)
+	for _, problem := range problems {
+		slog.Warn("route does not match the OpenAPI document", "problem", problem)
+	}
+
+	slog.Info("dev gateway listening", "addr", *addr)
+	if err := router.Run(*addr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newAuthorizer mirrors user-service/authorizer and the gateway responses:
+// a missing token is a 401, an invalid or blocked one is a 403.
+func newAuthorizer(users userrepository.UserRepository) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		token := context.GetHeader("Authorization")
+		if token == "" {
+			token = context.Query("token")
+			if token == "" {
+				userapperrors.Abort(context, userapperrors.Unauthenticated("Authorization token is missing"))
+				return
+			}
+		}
+
+		if err, _ := usermiddleware.ValidateTokenForLambdaAuthorizer(context.Request.Context(), users, token); err != nil {
+			userapperrors.Abort(context, userapperrors.Forbidden("User is not authorized to access this resource").Wrap(err))
+			return
+		}
+
+		context.Next()
+	}
+}
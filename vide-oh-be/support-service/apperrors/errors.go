@@ -0,0 +1,148 @@
+// Package apperrors defines the typed errors returned to API clients. Each
+// error carries a stable code the frontend can branch on and a detail that
+// is safe to show; the underlying cause is only logged.
+package apperrors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+type Code string
+
+// Generic codes shared by every handler.
+const (
+	CodeInvalidRequest       Code = "invalid_request"
+	CodeValidationFailed     Code = "validation_failed"
+	CodeUnauthenticated      Code = "unauthenticated"
+	CodeForbidden            Code = "forbidden"
+	CodeNotFound             Code = "not_found"
+	CodeConflict             Code = "conflict"
+	CodePreconditionFailed   Code = "precondition_failed"
+	CodePreconditionRequired Code = "precondition_required"
+	CodeInternal             Code = "internal"
+	CodeUnavailable          Code = "unavailable"
+)
+
+type Error struct {
+	Status int
+	Code   Code
+	// Detail is rendered to the client as is.
+	Detail string
+	// Err is the cause. It is logged but never rendered.
+	Err error
+}
+
+func New(status int, code Code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches on the code, so errors.Is(err, ErrUserBlocked) holds for a
+// wrapped copy of the sentinel.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of e with err as its cause.
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+func InvalidRequest(detail string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, detail)
+}
+
+// Invalid reports a request body or query that failed to bind. JSON syntax
+// and type errors are replaced by a generic detail.
+func Invalid(err error) *Error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return InvalidRequest("request body is not valid JSON").Wrap(err)
+	case errors.As(err, &typeErr):
+		return InvalidRequest(fmt.Sprintf("field %q has the wrong type", typeErr.Field)).Wrap(err)
+	}
+	return New(http.StatusBadRequest, CodeValidationFailed, err.Error()).Wrap(err)
+}
+
+func Unauthenticated(detail string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthenticated, detail)
+}
+
+func Forbidden(detail string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func NotFound(resource string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, resource+" not found")
+}
+
+func Conflict(detail string) *Error {
+	return New(http.StatusConflict, CodeConflict, detail)
+}
+
+// PreconditionFailed reports an If-Match that no longer matches the
+// resource, i.e. someone else changed it first.
+func PreconditionFailed(detail string) *Error {
+	return New(http.StatusPreconditionFailed, CodePreconditionFailed, detail)
+}
+
+func PreconditionRequired(detail string) *Error {
+	return New(http.StatusPreconditionRequired, CodePreconditionRequired, detail)
+}
+
+func Internal(err error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, "internal server error").Wrap(err)
+}
+
+func Unavailable(err error) *Error {
+	return New(http.StatusServiceUnavailable, CodeUnavailable, "service temporarily unavailable").Wrap(err)
+}
+
+// FromDB maps a gorm/driver error for the given resource. Missing rows
+// become 404, unique violations 409 and timeouts 503; anything else is an
+// internal error.
+func FromDB(err error, resource string) *Error {
+	if err == nil {
+		return nil
+	}
+	var sqlErr interface{ SQLState() string }
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NotFound(resource).Wrap(err)
+	case errors.Is(err, gorm.ErrDuplicatedKey), errors.As(err, &sqlErr) && sqlErr.SQLState() == "23505":
+		return Conflict(resource + " already exists").Wrap(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return Unavailable(err)
+	}
+	return Internal(err)
+}
+
+// As returns err as an *Error, treating untyped errors as internal.
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(err)
+}
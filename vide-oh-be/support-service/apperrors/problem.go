@@ -0,0 +1,66 @@
+package apperrors
+
+import (
+	"fmt"
+	"net/http"
+	"support-service/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem document. Code and CorrelationID are
+// extension members.
+type Problem struct {
+	Type          string `json:"type"`
+	Title         string `json:"title"`
+	Status        int    `json:"status"`
+	Detail        string `json:"detail,omitempty"`
+	Instance      string `json:"instance,omitempty"`
+	Code          Code   `json:"code"`
+	CorrelationID string `json:"correlationId,omitempty"`
+}
+
+func TypeURI(code Code) string {
+	return "urn:vide-oh:problem:" + string(code)
+}
+
+func NewProblem(e *Error) Problem {
+	return Problem{
+		Type:   TypeURI(e.Code),
+		Title:  http.StatusText(e.Status),
+		Status: e.Status,
+		Detail: e.Detail,
+		Code:   e.Code,
+	}
+}
+
+// Abort logs err, writes it as problem+json and aborts the handler chain.
+func Abort(c *gin.Context, err error) {
+	e := As(err)
+	ctx := c.Request.Context()
+	logger := logging.FromContext(ctx)
+	if e.Status >= http.StatusInternalServerError {
+		logger.Error("request failed", "code", e.Code, "error", err)
+	} else {
+		logger.Warn("request rejected", "code", e.Code, "error", err)
+	}
+
+	problem := NewProblem(e)
+	problem.Instance = c.Request.URL.Path
+	problem.CorrelationID = logging.CorrelationID(ctx)
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(e.Status, problem)
+}
+
+// Recovery turns panics into a 500 problem instead of an empty body.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		Abort(c, Internal(fmt.Errorf("panic: %v", recovered)))
+	})
+}
+
+func NoRoute(c *gin.Context) {
+	Abort(c, New(http.StatusNotFound, CodeNotFound, "no route for "+c.Request.Method+" "+c.Request.URL.Path))
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error lists every problem found while loading the configuration, so a
+// misconfigured function fails once with the full picture.
+type Error struct {
+	Missing []string
+	Invalid []string
+}
+
+func (e *Error) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Invalid) > 0 {
+		parts = append(parts, "invalid "+strings.Join(e.Invalid, "; "))
+	}
+	return "invalid configuration: " + strings.Join(parts, "; ")
+}
+
+func (e *Error) empty() bool {
+	return len(e.Missing) == 0 && len(e.Invalid) == 0
+}
+
+// lookup resolves a setting by its environment variable name. Environment
+// variables win over values from CONFIG_FILE.
+type lookup func(name string) (string, bool)
+
+func newLookup() (lookup, error) {
+	file := map[string]interface{}{}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CONFIG_FILE: %v", err)
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse CONFIG_FILE: %v", err)
+		}
+	}
+
+	return func(name string) (string, bool) {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			return value, true
+		}
+		if value, ok := file[name]; ok && value != nil {
+			return fmt.Sprint(value), true
+		}
+		return "", false
+	}, nil
+}
+
+// populate fills target from its struct tags:
+//
+//	env:"NAME"          variable to read
+//	default:"value"     used when the variable is unset
+//	required:"always"   must be set; "lambda" or "local" limits it to one mode
+func populate(target interface{}, get lookup, local bool, errs *Error) {
+	value := reflect.ValueOf(target).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		fieldValue := value.Field(i)
+
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+			populate(fieldValue.Addr().Interface(), get, local, errs)
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+
+		raw, ok := get(name)
+		if !ok {
+			raw, ok = field.Tag.Lookup("default")
+		}
+		if !ok {
+			switch field.Tag.Get("required") {
+			case "always":
+				errs.Missing = append(errs.Missing, name)
+			case "lambda":
+				if !local {
+					errs.Missing = append(errs.Missing, name)
+				}
+			case "local":
+				if local {
+					errs.Missing = append(errs.Missing, name)
+				}
+			}
+			continue
+		}
+
+		if err := set(fieldValue, raw); err != nil {
+			errs.Invalid = append(errs.Invalid, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+}
+
+func set(field reflect.Value, raw string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
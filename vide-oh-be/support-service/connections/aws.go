@@ -0,0 +1,119 @@
+package connections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"support-service/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/apigatewaymanagementapi"
+	apigwtypes "github.com/aws/aws-sdk-go-v2/service/apigatewaymanagementapi/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoStore keeps connections in the DynamoDB connections table.
+type DynamoStore struct {
+	db    *dynamodb.Client
+	table string
+}
+
+func NewDynamoStore(awsCfg aws.Config, table string) *DynamoStore {
+	return &DynamoStore{db: dynamodb.NewFromConfig(awsCfg), table: table}
+}
+
+func (s *DynamoStore) Put(ctx context.Context, connection models.Connection) error {
+	av, err := attributevalue.MarshalMap(connection)
+	if err != nil {
+		return fmt.Errorf("unable to marshal connection: %w", err)
+	}
+	_, err = s.db.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      av,
+	})
+	return err
+}
+
+func (s *DynamoStore) Delete(ctx context.Context, connectionID string) error {
+	_, err := s.db.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: connectionID},
+		},
+	})
+	return err
+}
+
+func (s *DynamoStore) Get(ctx context.Context, connectionID string) (models.Connection, error) {
+	result, err := s.db.Query(ctx, &dynamodb.QueryInput{
+		TableName: aws.String(s.table),
+		ExpressionAttributeNames: map[string]string{
+			"#id": "id",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: connectionID},
+		},
+		KeyConditionExpression: aws.String("#id = :id"),
+	})
+	if err != nil {
+		return models.Connection{}, err
+	}
+	var connections []models.Connection
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &connections); err != nil {
+		return models.Connection{}, err
+	}
+	switch len(connections) {
+	case 0:
+		return models.Connection{}, ErrNotFound
+	case 1:
+		return connections[0], nil
+	}
+	return models.Connection{}, errors.New("found duplicate connection ID")
+}
+
+func (s *DynamoStore) ByUserEmail(ctx context.Context, userEmail string) ([]models.Connection, error) {
+	result, err := s.db.Scan(ctx, &dynamodb.ScanInput{
+		TableName:        aws.String(s.table),
+		FilterExpression: aws.String("userEmail = :userEmail"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userEmail": &types.AttributeValueMemberS{Value: userEmail},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var connections []models.Connection
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &connections); err != nil {
+		return nil, err
+	}
+	return connections, nil
+}
+
+// APIGatewayPoster posts through the API Gateway management API of the
+// WebSocket stage.
+type APIGatewayPoster struct {
+	client *apigatewaymanagementapi.Client
+}
+
+// NewAPIGatewayPoster takes the wss:// stage URL.
+func NewAPIGatewayPoster(awsCfg aws.Config, websocketURL string) *APIGatewayPoster {
+	client := apigatewaymanagementapi.NewFromConfig(awsCfg, func(o *apigatewaymanagementapi.Options) {
+		o.BaseEndpoint = aws.String(strings.Replace(websocketURL, "wss", "https", 1))
+	})
+	return &APIGatewayPoster{client: client}
+}
+
+func (p *APIGatewayPoster) Post(ctx context.Context, connectionID string, data []byte) error {
+	_, err := p.client.PostToConnection(ctx, &apigatewaymanagementapi.PostToConnectionInput{
+		ConnectionId: aws.String(connectionID),
+		Data:         data,
+	})
+	var gone *apigwtypes.GoneException
+	if errors.As(err, &gone) {
+		return fmt.Errorf("%w: %v", ErrGone, err)
+	}
+	return err
+}
@@ -0,0 +1,30 @@
+// Package connections keeps track of open WebSocket connections and posts
+// to them. The handlers use the interfaces so they can run against the
+// in-memory fakes.
+package connections
+
+import (
+	"context"
+	"errors"
+	"support-service/models"
+)
+
+var (
+	ErrNotFound = errors.New("connection not found")
+	// ErrGone is returned when the client disconnected without a
+	// $disconnect event reaching us.
+	ErrGone = errors.New("connection gone")
+)
+
+// ConnectionStore records which user's conversation a connection joined.
+type ConnectionStore interface {
+	Put(ctx context.Context, connection models.Connection) error
+	Delete(ctx context.Context, connectionID string) error
+	Get(ctx context.Context, connectionID string) (models.Connection, error)
+	ByUserEmail(ctx context.Context, userEmail string) ([]models.Connection, error)
+}
+
+// ConnectionPoster sends a frame to one connection.
+type ConnectionPoster interface {
+	Post(ctx context.Context, connectionID string, data []byte) error
+}
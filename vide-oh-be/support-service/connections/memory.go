@@ -0,0 +1,97 @@
+package connections
+
+import (
+	"context"
+	"sort"
+	"support-service/models"
+	"sync"
+)
+
+// MemoryStore is an in-memory ConnectionStore for tests and local runs.
+type MemoryStore struct {
+	mu          sync.Mutex
+	connections map[string]models.Connection
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{connections: map[string]models.Connection{}}
+}
+
+func (s *MemoryStore) Put(ctx context.Context, connection models.Connection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connections[connection.ID] = connection
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, connectionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.connections, connectionID)
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, connectionID string) (models.Connection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	connection, ok := s.connections[connectionID]
+	if !ok {
+		return models.Connection{}, ErrNotFound
+	}
+	return connection, nil
+}
+
+// ByUserEmail returns the connections ordered by ID.
+func (s *MemoryStore) ByUserEmail(ctx context.Context, userEmail string) ([]models.Connection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []models.Connection
+	for _, connection := range s.connections {
+		if connection.UserEmail == userEmail {
+			out = append(out, connection)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// Post is a frame sent through MemoryPoster.
+type Post struct {
+	ConnectionID string
+	Data         []byte
+}
+
+// MemoryPoster records posts instead of sending them. Connections marked
+// with Gone fail with ErrGone.
+type MemoryPoster struct {
+	mu    sync.Mutex
+	posts []Post
+	gone  map[string]bool
+}
+
+func NewMemoryPoster() *MemoryPoster {
+	return &MemoryPoster{gone: map[string]bool{}}
+}
+
+func (p *MemoryPoster) Post(ctx context.Context, connectionID string, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.gone[connectionID] {
+		return ErrGone
+	}
+	p.posts = append(p.posts, Post{ConnectionID: connectionID, Data: append([]byte(nil), data...)})
+	return nil
+}
+
+func (p *MemoryPoster) Gone(connectionID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gone[connectionID] = true
+}
+
+// Posts returns the frames posted so far.
+func (p *MemoryPoster) Posts() []Post {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Post(nil), p.posts...)
+}
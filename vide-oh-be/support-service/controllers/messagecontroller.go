@@ -1,67 +1,100 @@
 package controllers
 
 import (
-	"errors"
+	"context"
 	"net/http"
-	"support-service/database"
+	"support-service/apperrors"
 	"support-service/models"
+	"support-service/repository"
 	"support-service/utils"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AddMessage(msg string, email string, jwtClaims utils.JWTClaim) (message *models.Message, err error) {
+type MessageController struct {
+	repos repository.Repositories
+}
+
+func NewMessageController(repos repository.Repositories) *MessageController {
+	return &MessageController{repos: repos}
+}
+
+func (m *MessageController) AddMessage(ctx context.Context, msg string, email string, jwtClaims utils.JWTClaim) (message *models.Message, err error) {
 	message = &models.Message{
 		Content:    msg,
 		OwnerEmail: email,
 		SentByUser: jwtClaims.Role == "RegisteredUser",
 		Date:       time.Now(),
 	}
-	record := database.Instance.Save(&message)
-
-	if record.Error != nil {
-		err = errors.New("DB error")
+	if dbErr := m.repos.Messages.Create(ctx, message); dbErr != nil {
+		err = apperrors.FromDB(dbErr, "message")
 	}
 
 	return message, err
 }
 
-func GetAllMessagesForUser(c *gin.Context) {
-	email := c.Param("email")
-	_, claims := utils.GetTokenClaims(c)
-	if claims.Role != "SupportUser" && !(claims.Role == "RegisteredUser" && claims.Email == email) {
-		c.JSON(401, gin.H{"error": "unauthorized role"})
-		c.Abort()
-		return
+func (m *MessageController) GetAllMessagesForUser(c *gin.Context) {
+	if messages, ok := m.conversation(c); ok {
+		c.JSON(http.StatusOK, messages)
 	}
+}
 
-	var messages []models.Message
+func (m *MessageController) GetAllUserEmailsWithMessages(c *gin.Context) {
+	if userEmails, ok := m.conversations(c); ok {
+		c.JSON(http.StatusOK, userEmails)
+	}
+}
 
-	if err := database.Instance.Where("owner_email = ?", email).Find(&messages).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		c.Abort()
-		return
+// ListMessages and ListConversations are the /api/v2 handlers; they
+// return empty lists instead of null.
+func (m *MessageController) ListMessages(c *gin.Context) {
+	if messages, ok := m.conversation(c); ok {
+		if messages == nil {
+			messages = []models.Message{}
+		}
+		c.JSON(http.StatusOK, messages)
 	}
+}
 
-	c.JSON(http.StatusOK, messages)
+func (m *MessageController) ListConversations(c *gin.Context) {
+	if userEmails, ok := m.conversations(c); ok {
+		if userEmails == nil {
+			userEmails = []string{}
+		}
+		c.JSON(http.StatusOK, userEmails)
+	}
 }
 
-func GetAllUserEmailsWithMessages(c *gin.Context) {
+// conversation loads the messages of the :email user. It aborts the
+// request and returns false when that fails.
+func (m *MessageController) conversation(c *gin.Context) ([]models.Message, bool) {
+	email := c.Param("email")
 	_, claims := utils.GetTokenClaims(c)
-	if claims.Role != "SupportUser" {
-		c.JSON(401, gin.H{"error": "unauthorized role"})
-		c.Abort()
-		return
+	if claims.Role != "SupportUser" && !(claims.Role == "RegisteredUser" && claims.Email == email) {
+		apperrors.Abort(c, apperrors.Forbidden("only support or the conversation owner can read these messages"))
+		return nil, false
 	}
 
-	var userEmails []string
+	messages, err := m.repos.Messages.ByOwner(c.Request.Context(), email)
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "message"))
+		return nil, false
+	}
+	return messages, true
+}
 
-	if err := database.Instance.Model(&models.Message{}).Distinct("owner_email").Find(&userEmails).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		c.Abort()
-		return
+func (m *MessageController) conversations(c *gin.Context) ([]string, bool) {
+	_, claims := utils.GetTokenClaims(c)
+	if claims.Role != "SupportUser" {
+		apperrors.Abort(c, apperrors.Forbidden("support role required"))
+		return nil, false
 	}
 
-	c.JSON(http.StatusOK, userEmails)
+	userEmails, err := m.repos.Messages.OwnerEmails(c.Request.Context())
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "message"))
+		return nil, false
+	}
+	return userEmails, true
 }
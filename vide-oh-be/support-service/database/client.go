@@ -1,8 +1,12 @@
 package database
 
 import (
+	"database/sql"
 	"log"
+	"support-service/config"
 	"support-service/models"
+	"support-service/tracing"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -11,12 +15,44 @@ import (
 var Instance *gorm.DB
 var dbError error
 
-func Connect(connectionString string) {
-	Instance, dbError = gorm.Open(postgres.Open(connectionString), &gorm.Config{})
+func Connect(credentials CredentialSource) {
+	connector, err := newConnector(credentials)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sqlDB := sql.OpenDB(connector)
+	// Recycle connections so long-lived Lambdas don't hold sessions
+	// authenticated with retired credentials forever
+	sqlDB.SetConnMaxLifetime(30 * time.Minute)
+
+	open(postgres.New(postgres.Config{Conn: sqlDB}))
+}
+
+// Open connects to the database selected by DB_DRIVER: SQLite in local
+// mode, Postgres with the configured credentials otherwise.
+func Open(cfg *config.Config) {
+	if cfg.DatabaseDriver == config.DBDriverSQLite {
+		ConnectSQLite(cfg.DatabaseDSN)
+		return
+	}
+	credentials, err := NewCredentialSource(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	Connect(credentials)
+}
+
+func open(dialector gorm.Dialector) {
+	// TranslateError turns unique violations into gorm.ErrDuplicatedKey on
+	// both dialects
+	Instance, dbError = gorm.Open(dialector, &gorm.Config{TranslateError: true})
 	if dbError != nil {
 		log.Fatal(dbError)
 		panic("Cannot connect to DB")
 	}
+	if err := Instance.Use(tracing.GormPlugin{}); err != nil {
+		log.Fatal(err)
+	}
 	log.Println("Connected to Database!")
 }
 
@@ -27,4 +63,5 @@ func Migrate() {
 	} else {
 		log.Println("Table 'messages' already exists, skipping migration.")
 	}
+	recordSchemaVersion()
 }
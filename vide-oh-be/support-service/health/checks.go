@@ -0,0 +1,60 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"support-service/config"
+	"support-service/database"
+	"support-service/tracing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+const (
+	postgresTimeout       = 2 * time.Second
+	dynamoDBTimeout       = 3 * time.Second
+	secretsManagerTimeout = 3 * time.Second
+)
+
+// Init registers the readiness checks for the dependencies the
+// configuration uses. DynamoDB only backs the WebSocket routes and, like
+// Secrets Manager, is not used in local mode.
+func Init(cfg *config.Config) error {
+	Register(Check{Name: "postgres", Timeout: postgresTimeout, Run: database.Ping})
+	if cfg.Local {
+		return nil
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.TODO(), awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	tracing.AppendAWSMiddlewares(&awsCfg.APIOptions)
+
+	dynamo := dynamodb.NewFromConfig(awsCfg)
+	Register(Check{
+		Name:    "dynamodb",
+		Timeout: dynamoDBTimeout,
+		Run: func(ctx context.Context) error {
+			_, err := dynamo.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(cfg.WebSocket.TableNameConnections)})
+			return err
+		},
+	})
+
+	if cfg.DB.AuthMode == config.DBAuthSecret {
+		secrets := secretsmanager.NewFromConfig(awsCfg)
+		Register(Check{
+			Name:    "secretsmanager",
+			Timeout: secretsManagerTimeout,
+			Run: func(ctx context.Context) error {
+				_, err := secrets.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(cfg.DBSecretName)})
+				return err
+			},
+		})
+	}
+	return nil
+}
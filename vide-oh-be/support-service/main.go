@@ -2,14 +2,23 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
-	"net/url"
+	"log/slog"
 	"os"
 
+	"support-service/config"
 	"support-service/controllers"
 	"support-service/database"
-	"support-service/utils"
+	"support-service/health"
+	"support-service/logging"
+	"support-service/metrics"
+	"support-service/middleware"
+	"support-service/openapi"
+	"support-service/repository"
+	"support-service/router"
+	"support-service/tracing"
 	"support-service/websocket"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -17,85 +26,126 @@ import (
 	ginadapter "github.com/awslabs/aws-lambda-go-api-proxy/gin"
 	"github.com/gin-gonic/gin"
 	"github.com/mitchellh/mapstructure"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var ginLambda *ginadapter.GinLambda
 
-var region string
-var tableNameConnections string
-var websocketApiUrl string
-
-func init() {
-	// Initialize Router
-	router := initRouter()
-
-	// Create the Lambda handler
-	ginLambda = ginadapter.New(router)
-}
+var cfg *config.Config
 
 func main() {
-	// Load env vars
-	secretName := os.Getenv("DB_SECRET_NAME")
-	if secretName == "" {
-		log.Fatal("DB_SECRET_NAME environment variable is not set")
-	}
-	tableNameConnections = os.Getenv("TABLE_NAME_CONNECTIONS")
-	if tableNameConnections == "" {
-		log.Fatal("TABLE_NAME_CONNECTIONS environment variable is not set")
+	local := flag.Bool("local", false, "serve the REST API over plain HTTP instead of running as a Lambda")
+	addr := flag.String("addr", ":8083", "listen address in -local mode")
+	dashboard := flag.Bool("dashboard", false, "print the CloudWatch dashboard for the service metrics and exit")
+	checkOpenAPI := flag.Bool("check-openapi", false, "compare the registered routes with openapi/openapi.json and exit")
+	flag.Parse()
+
+	if *dashboard {
+		printDashboard()
+		return
 	}
-	region = os.Getenv("REGION")
-	if secretName == "" {
-		log.Fatal("REGION environment variable is not set")
-	}
-	websocketApiUrl = os.Getenv("WEBSOCKET_API_URL")
-	if secretName == "" {
-		log.Fatal("WEBSOCKET_API_URL environment variable is not set")
+	if *checkOpenAPI {
+		checkRoutes()
+		return
 	}
 
-	// Read AWS secret DB connection info
-	secret, err := utils.GetSecret(secretName, region)
+	// Load and validate configuration (env, CONFIG_FILE)
+	var err error
+	cfg, err = config.Load(*local)
 	if err != nil {
-		log.Fatalf("Failed to retrieve secret: %v", err)
+		log.Fatal(err)
+	}
+	slog.SetDefault(logging.New("support-service", cfg.LogLevel))
+	openapi.SetMode(cfg.OpenAPIValidation)
+	metrics.Init("support-service", *local)
+	if err := tracing.Init(context.Background(), "support-service", cfg.Tracing.Endpoint, cfg.Tracing.SamplePercent); err != nil {
+		log.Fatal(err)
 	}
-
-	connectionString := fmt.Sprintf("postgresql://%s:%s@%s:%d/%s",
-		secret.Username,
-		url.QueryEscape(secret.Password),
-		secret.Host,
-		secret.Port,
-		secret.DBName,
-	)
 
 	// Initialize Database
-	database.Connect(connectionString)
+	database.Open(cfg)
+	if err := health.Init(cfg); err != nil {
+		log.Fatal(err)
+	}
 	database.Migrate()
+	messages := controllers.NewMessageController(repository.New(database.Instance))
+
+	if *local {
+		runLocal(messages, *addr)
+		return
+	}
+
+	if err := websocket.Init(cfg, messages); err != nil {
+		log.Fatal(err)
+	}
+
+	// Create the Lambda handler
+	ginLambda = ginadapter.New(router.New(nil, messages))
 
 	// Start the Lambda handler
 	lambda.Start(Handler)
 }
 
-func CORS() gin.HandlerFunc {
-	// TO allow CORS
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
+// runLocal serves the REST routes over plain HTTP with the authorizer
+// stubbed in-process. The WebSocket routes depend on API Gateway and are
+// not served locally.
+func runLocal(messages *controllers.MessageController, addr string) {
+	authorizer, err := middleware.LocalAuthorizer(cfg.JWTKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer tracing.Shutdown(context.Background())
+
+	engine := router.New(authorizer, messages)
+	engine.GET("/metrics", metrics.Handler())
+	for _, problem := range openapi.CheckRoutes(engine.Routes(), "/api/messages", "/api/v2/messages") {
+		slog.Warn("route does not match the OpenAPI document", "problem", problem)
+	}
+
+	slog.Info("support service listening", "addr", addr)
+	if err := engine.Run(addr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// checkRoutes exits non-zero when the router and openapi/openapi.json
+// disagree.
+func checkRoutes() {
+	gin.SetMode(gin.ReleaseMode)
+	problems := openapi.CheckRoutes(router.New(nil, &controllers.MessageController{}).Routes(), "/api/messages", "/api/v2/messages")
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printDashboard() {
+	region := os.Getenv("REGION")
+	if region == "" {
+		region = "eu-central-1"
 	}
+	body, err := metrics.Dashboard("support-service", region)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(body))
 }
 
 // Handler handles both WebSocket and HTTP API Gateway requests
 func Handler(ctx context.Context, req interface{}) (interface{}, error) {
+	defer tracing.Flush(ctx)
+	defer metrics.Flush()
 
 	// Try to cast to HTTP API Gateway (REST) request
 	if request, ok := req.(map[string]interface{}); ok {
 		// First, check if it's a REST API Gateway request
 		if method, found := request["httpMethod"]; found && method != nil {
-			fmt.Println("Handling HTTP API Gateway request")
 
 			// Convert the raw map to APIGatewayProxyRequest
 			var proxyRequest events.APIGatewayProxyRequest
@@ -110,8 +160,6 @@ func Handler(ctx context.Context, req interface{}) (interface{}, error) {
 
 		// If it's not an HTTP request, check if it's a WebSocket request
 		if routeKey, found := request["requestContext"].(map[string]interface{})["routeKey"]; found && routeKey != nil {
-			fmt.Println("Handling WebSocket API Gateway request")
-
 			// Convert the raw map to APIGatewayWebsocketProxyRequest
 			var websocketRequest events.APIGatewayWebsocketProxyRequest
 			err := mapstructure.Decode(request, &websocketRequest)
@@ -119,30 +167,38 @@ func Handler(ctx context.Context, req interface{}) (interface{}, error) {
 				return nil, fmt.Errorf("failed to decode request to APIGatewayWebsocketProxyRequest: %v", err)
 			}
 
+			// WebSocket events bypass gin, so attach the request logger here
+			requestContext := websocketRequest.RequestContext
+			logger := slog.Default().With(
+				"request_id", requestContext.RequestID,
+				"correlation_id", requestContext.RequestID,
+				"connection_id", requestContext.ConnectionID,
+				"route", requestContext.RouteKey,
+			)
+			ctx = logging.WithCorrelationID(ctx, requestContext.RequestID)
+			ctx = logging.WithLogger(ctx, logger)
+
+			// Continue the trace of the upgrade or message frame, if any
+			ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(websocketRequest.Headers))
+			ctx, span := tracing.Start(ctx, "websocket "+requestContext.RouteKey,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attribute.String("websocket.connection_id", requestContext.ConnectionID)),
+			)
+			defer span.End()
+
 			// Handle WebSocket connections
 			switch websocketRequest.RequestContext.RouteKey {
 			case "$connect":
-				return websocket.HandleConnect(ctx, websocketRequest, tableNameConnections, region)
+				return websocket.HandleConnect(ctx, websocketRequest)
 			case "$disconnect":
-				return websocket.HandleDisconnect(ctx, websocketRequest, tableNameConnections, region)
+				return websocket.HandleDisconnect(ctx, websocketRequest)
 			default:
-				return websocket.HandleMessage(ctx, websocketRequest, tableNameConnections, region, websocketApiUrl)
+				return websocket.HandleMessage(ctx, websocketRequest)
 			}
 		}
 	}
 
 	// If none of the above types matched, return an error
-	fmt.Println("Unknown request type")
+	slog.Warn("unknown request type")
 	return nil, fmt.Errorf("unknown request type")
 }
-
-func initRouter() *gin.Engine {
-	router := gin.Default()
-	router.Use(CORS())
-	api := router.Group("/api/messages")
-	{
-		api.GET("/:email/all", controllers.GetAllMessagesForUser)
-		api.GET("/user-emails", controllers.GetAllUserEmailsWithMessages)
-	}
-	return router
-}
@@ -0,0 +1,9 @@
+package metrics
+
+// FanOut is the number of connections a support message is posted to.
+var FanOut = NewHistogram("websocket_fanout_size", "Connections per delivered support message", UnitCount,
+	[]float64{1, 2, 3, 5, 10, 20})
+
+// PostToConnectionErrors counts failed deliveries by reason: gone (the
+// client disconnected without $disconnect) or other.
+var PostToConnectionErrors = NewCounter("post_to_connection_errors_total", "Failed PostToConnection calls by reason", "reason")
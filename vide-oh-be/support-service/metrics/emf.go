@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// CloudWatch accepts at most 100 values per metric in one EMF document.
+const emfMaxValues = 100
+
+var emfOutput io.Writer = os.Stdout
+
+type emfSeries struct {
+	labelValues []string
+	values      []float64
+}
+
+// emfBuffer collects the values recorded during one Lambda invocation.
+type emfBuffer struct {
+	mu     sync.Mutex
+	series map[*Definition]map[string]*emfSeries
+}
+
+func newEMFBuffer() *emfBuffer {
+	return &emfBuffer{series: make(map[*Definition]map[string]*emfSeries)}
+}
+
+func (buffer *emfBuffer) record(def *Definition, labelValues []string, value float64) {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+
+	byKey, ok := buffer.series[def]
+	if !ok {
+		byKey = make(map[string]*emfSeries)
+		buffer.series[def] = byKey
+	}
+	key := seriesKey(labelValues)
+	series, ok := byKey[key]
+	if !ok {
+		series = &emfSeries{labelValues: append([]string(nil), labelValues...)}
+		byKey[key] = series
+	}
+
+	// Counters are summed, histograms keep every value so CloudWatch can
+	// compute percentiles
+	if def.Kind == KindCounter && len(series.values) == 1 {
+		series.values[0] += value
+	} else {
+		series.values = append(series.values, value)
+	}
+}
+
+// Flush writes the buffered values to stdout as CloudWatch Embedded Metric
+// Format documents, one per metric and label set. Lambda handlers call it
+// before returning; it is a no-op in local mode.
+func Flush() {
+	sinkMu.RLock()
+	buffer, ok := current.(*emfBuffer)
+	sinkMu.RUnlock()
+	if !ok {
+		return
+	}
+
+	buffer.mu.Lock()
+	pending := buffer.series
+	buffer.series = make(map[*Definition]map[string]*emfSeries)
+	buffer.mu.Unlock()
+
+	timestamp := time.Now().UnixMilli()
+	encoder := json.NewEncoder(emfOutput)
+	for _, def := range definitions() {
+		for _, series := range pending[def] {
+			for start := 0; start < len(series.values); start += emfMaxValues {
+				end := min(start+emfMaxValues, len(series.values))
+				encoder.Encode(emfDocument(def, series.labelValues, series.values[start:end], timestamp))
+			}
+		}
+	}
+}
+
+func emfDocument(def *Definition, labelValues []string, values []float64, timestamp int64) map[string]interface{} {
+	dimensions := append([]string{"Service"}, def.Labels...)
+	document := map[string]interface{}{
+		"_aws": map[string]interface{}{
+			"Timestamp": timestamp,
+			"CloudWatchMetrics": []map[string]interface{}{{
+				"Namespace":  Namespace,
+				"Dimensions": [][]string{dimensions},
+				"Metrics":    []map[string]string{{"Name": def.Name, "Unit": def.Unit}},
+			}},
+		},
+		"Service": service,
+	}
+	for i, label := range def.Labels {
+		document[label] = labelValues[i]
+	}
+	if len(values) == 1 {
+		document[def.Name] = values[0]
+	} else {
+		document[def.Name] = values
+	}
+	return document
+}
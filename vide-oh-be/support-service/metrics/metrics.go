@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Namespace groups the metrics of all services in CloudWatch.
+const Namespace = "Videoh"
+
+type Kind string
+
+const (
+	KindCounter   Kind = "counter"
+	KindHistogram Kind = "histogram"
+)
+
+// Units understood by CloudWatch.
+const (
+	UnitCount   = "Count"
+	UnitSeconds = "Seconds"
+	UnitBytes   = "Bytes"
+)
+
+// Definition describes one metric in the registry. The registry drives the
+// Prometheus output, the EMF output and the generated dashboard.
+type Definition struct {
+	Name   string
+	Help   string
+	Kind   Kind
+	Unit   string
+	Labels []string
+	// Upper bounds for Prometheus histogram buckets
+	Buckets []float64
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*Definition
+)
+
+func register(def *Definition) *Definition {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, existing := range registry {
+		if existing.Name == def.Name {
+			panic(fmt.Sprintf("metrics: %s registered twice", def.Name))
+		}
+	}
+	registry = append(registry, def)
+	return def
+}
+
+// Registry returns the registered metrics sorted by name.
+func Registry() []Definition {
+	defs := make([]Definition, 0, len(registry))
+	for _, def := range definitions() {
+		defs = append(defs, *def)
+	}
+	return defs
+}
+
+func definitions() []*Definition {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	defs := append([]*Definition(nil), registry...)
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+type Counter struct {
+	def *Definition
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{register(&Definition{Name: name, Help: help, Kind: KindCounter, Unit: UnitCount, Labels: labels})}
+}
+
+// Inc adds one for the given label values, in the order the labels were
+// declared.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(value float64, labelValues ...string) {
+	record(c.def, labelValues, value)
+}
+
+type Histogram struct {
+	def *Definition
+}
+
+func NewHistogram(name, help, unit string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{register(&Definition{Name: name, Help: help, Kind: KindHistogram, Unit: unit, Labels: labels, Buckets: buckets})}
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	record(h.def, labelValues, value)
+}
+
+// sink receives every recorded value.
+type sink interface {
+	record(def *Definition, labelValues []string, value float64)
+}
+
+var (
+	sinkMu  sync.RWMutex
+	current sink
+	service string
+)
+
+// Init selects the output: an in-memory store served by Handler in local
+// mode, or CloudWatch Embedded Metric Format written by Flush on Lambda.
+// Values recorded before Init are dropped.
+func Init(serviceName string, local bool) {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	service = serviceName
+	if local {
+		current = newPrometheusStore()
+	} else {
+		current = newEMFBuffer()
+	}
+}
+
+func record(def *Definition, labelValues []string, value float64) {
+	if len(labelValues) != len(def.Labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", def.Name, len(def.Labels), len(labelValues)))
+	}
+	sinkMu.RLock()
+	defer sinkMu.RUnlock()
+	if current != nil {
+		current.record(def, labelValues, value)
+	}
+}
+
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
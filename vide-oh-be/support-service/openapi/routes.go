@@ -0,0 +1,40 @@
+package openapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckRoutes compares the gin routes under the prefixes with the document
+// and describes every route that only one side knows about.
+func CheckRoutes(routes gin.RoutesInfo, prefixes ...string) []string {
+	registered := map[string]bool{}
+	for _, route := range routes {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(route.Path, prefix) {
+				registered[route.Method+" "+toOpenAPIPath(route.Path)] = true
+			}
+		}
+	}
+
+	var problems []string
+	for key := range registered {
+		parts := strings.SplitN(key, " ", 2)
+		if _, ok := spec.operation(parts[0], parts[1]); !ok {
+			problems = append(problems, fmt.Sprintf("%s is registered but not documented", key))
+		}
+	}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			key := strings.ToUpper(method) + " " + path
+			if !registered[key] {
+				problems = append(problems, fmt.Sprintf("%s is documented but not registered", key))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
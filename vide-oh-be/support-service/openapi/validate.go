@@ -0,0 +1,229 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validateRequest checks parameters and the JSON body. The body is read
+// and put back so the handler can still bind it.
+func (d *Document) validateRequest(op Operation, context *gin.Context) []string {
+	var problems []string
+	for _, param := range op.Parameters {
+		var value string
+		var present bool
+		switch param.In {
+		case "path":
+			value = context.Param(param.Name)
+			present = value != ""
+		case "query":
+			value, present = context.GetQuery(param.Name)
+		case "header":
+			value = context.GetHeader(param.Name)
+			present = value != ""
+		default:
+			continue
+		}
+		where := fmt.Sprintf("%s parameter %q", param.In, param.Name)
+		if !present {
+			if param.Required {
+				problems = append(problems, where+" is required")
+			}
+			continue
+		}
+		d.validate(param.Schema, parseParameter(d.resolve(param.Schema), value), where, &problems)
+	}
+
+	if op.RequestBody == nil {
+		return problems
+	}
+	body, err := io.ReadAll(context.Request.Body)
+	if err != nil {
+		return append(problems, "request body could not be read")
+	}
+	context.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) == 0 {
+		if op.RequestBody.Required {
+			problems = append(problems, "request body is required")
+		}
+		return problems
+	}
+	mediaType, _, _ := mime.ParseMediaType(context.GetHeader("Content-Type"))
+	content, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		return append(problems, fmt.Sprintf("request content type %q is not accepted", mediaType))
+	}
+	if isJSON(mediaType) {
+		problems = append(problems, d.validateJSON(content.Schema, body, "request body")...)
+	}
+	return problems
+}
+
+func (d *Document) validateResponse(op Operation, status int, contentType string, body []byte) []string {
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		response, ok = op.Responses["default"]
+	}
+	if !ok {
+		return []string{fmt.Sprintf("status %d is not documented", status)}
+	}
+	if len(response.Content) == 0 {
+		if len(body) > 0 {
+			return []string{fmt.Sprintf("status %d is documented without a body", status)}
+		}
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	content, ok := response.Content[mediaType]
+	if !ok {
+		return []string{fmt.Sprintf("content type %q is not documented for status %d", mediaType, status)}
+	}
+	if isJSON(mediaType) {
+		return d.validateJSON(content.Schema, body, "response body")
+	}
+	return nil
+}
+
+func (d *Document) validateJSON(schema *Schema, body []byte, where string) []string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []string{where + " is not valid JSON"}
+	}
+	var problems []string
+	d.validate(schema, value, where, &problems)
+	return problems
+}
+
+// validate checks value, as decoded by encoding/json, against schema.
+func (d *Document) validate(schema *Schema, value interface{}, where string, problems *[]string) {
+	schema = d.resolve(schema)
+	if schema == nil {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, where+" "+fmt.Sprintf(format, args...))
+	}
+	if value == nil {
+		if !schema.Nullable {
+			fail("must not be null")
+		}
+		return
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		fail("must be one of %v", schema.Enum)
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				fail("is missing property %q", name)
+			}
+		}
+		for name, property := range object {
+			if propertySchema, ok := schema.Properties[name]; ok {
+				d.validate(propertySchema, property, where+"."+name, problems)
+			} else if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				fail("has unknown property %q", name)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range items {
+			d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", where, i), problems)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if schema.MinLength != nil && utf8.RuneCountInString(s) < *schema.MinLength {
+			fail("must be at least %d characters", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && utf8.RuneCountInString(s) > *schema.MaxLength {
+			fail("must be at most %d characters", *schema.MaxLength)
+		}
+		switch schema.Format {
+		case "email":
+			if _, err := mail.ParseAddress(s); err != nil {
+				fail("must be an email address")
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				fail("must be an RFC 3339 date-time")
+			}
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			fail("must be of type %s", schema.Type)
+			return
+		}
+		if schema.Type == "integer" && n != math.Trunc(n) {
+			fail("must be an integer")
+		}
+		if schema.Minimum != nil && n < *schema.Minimum {
+			fail("must be at least %v", *schema.Minimum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+	}
+}
+
+// parseParameter converts a raw parameter into the value encoding/json
+// would produce, so the same schema checks apply. Unparseable values are
+// left as strings and fail the type check.
+func parseParameter(schema *Schema, raw string) interface{} {
+	if schema == nil {
+		return raw
+	}
+	switch schema.Type {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(raw, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, candidate := range enum {
+		if reflect.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
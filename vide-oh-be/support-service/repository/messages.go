@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"support-service/models"
+
+	"gorm.io/gorm"
+)
+
+type messages struct {
+	db *gorm.DB
+}
+
+func (r messages) Create(ctx context.Context, message *models.Message) error {
+	return r.db.WithContext(ctx).Create(message).Error
+}
+
+func (r messages) ByOwner(ctx context.Context, ownerEmail string) ([]models.Message, error) {
+	var list []models.Message
+	err := r.db.WithContext(ctx).Where("owner_email = ?", ownerEmail).Order("id").Find(&list).Error
+	return list, err
+}
+
+func (r messages) OwnerEmails(ctx context.Context) ([]string, error) {
+	var emails []string
+	err := r.db.WithContext(ctx).Model(&models.Message{}).Distinct("owner_email").Find(&emails).Error
+	return emails, err
+}
@@ -0,0 +1,29 @@
+// Package repository is the data access layer. Controllers depend on the
+// interfaces; the gorm implementation runs on Postgres in production and on
+// SQLite for local development.
+package repository
+
+import (
+	"context"
+	"support-service/models"
+)
+
+type MessageRepository interface {
+	Create(ctx context.Context, message *models.Message) error
+	// ByOwner returns the conversation of the user in insertion order
+	ByOwner(ctx context.Context, ownerEmail string) ([]models.Message, error)
+	OwnerEmails(ctx context.Context) ([]string, error)
+}
+
+// Repositories is what controllers are constructed with. Inside
+// UnitOfWork.Do the same set is bound to the transaction.
+type Repositories struct {
+	Messages   MessageRepository
+	UnitOfWork UnitOfWork
+}
+
+// UnitOfWork runs fn in one transaction, committed when fn returns nil and
+// rolled back otherwise. Nested calls use savepoints.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(tx Repositories) error) error
+}
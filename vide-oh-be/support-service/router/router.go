@@ -0,0 +1,77 @@
+package router
+
+import (
+	"log/slog"
+	"support-service/apperrors"
+	"support-service/controllers"
+	"support-service/health"
+	"support-service/logging"
+	"support-service/middleware"
+	"support-service/openapi"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+func CORS() gin.HandlerFunc {
+	// TO allow CORS
+	return func(c *gin.Context) {
+		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, If-Match, If-None-Match")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag, Location, Deprecation, Sunset, Link")
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+		c.Next()
+	}
+}
+
+// New builds the engine served by the Lambda handler and by -local mode.
+// On Lambda the API Gateway authorizer guards every route, so authorizer
+// is nil; locally it is the in-process stand-in.
+func New(authorizer gin.HandlerFunc, messages *controllers.MessageController) *gin.Engine {
+	router := gin.New()
+	router.Use(apperrors.Recovery(), CORS())
+	router.NoRoute(apperrors.NoRoute)
+	Register(router, authorizer, messages)
+	return router
+}
+
+// The v1 routes stay until the sunset; /api/v2 replaces them.
+var (
+	v1Deprecated = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	v1Sunset     = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// Register mounts the /api/messages and /api/v2/messages routes on an
+// existing router.
+func Register(router gin.IRouter, authorizer gin.HandlerFunc, messages *controllers.MessageController) {
+	api := router.Group("/api/messages")
+	api.Use(otelgin.Middleware("support-service"), logging.Middleware(slog.Default()), openapi.Middleware())
+	{
+		api.GET("/health/live", health.Live)
+		api.GET("/health/ready", health.Ready)
+		api.GET("/openapi.json", openapi.Serve)
+
+		// protected
+		protected := api.Group("", middleware.Deprecated(v1Deprecated, v1Sunset, "/api/v2/messages"))
+		if authorizer != nil {
+			protected.Use(authorizer)
+		}
+		protected.GET("/:email/all", messages.GetAllMessagesForUser)
+		protected.GET("/user-emails", messages.GetAllUserEmailsWithMessages)
+	}
+
+	v2 := router.Group("/api/v2/messages")
+	v2.Use(otelgin.Middleware("support-service"), logging.Middleware(slog.Default()), openapi.Middleware())
+	if authorizer != nil {
+		v2.Use(authorizer)
+	}
+	{
+		v2.GET("/conversations", messages.ListConversations)
+		v2.GET("/conversations/:email", messages.ListMessages)
+	}
+}
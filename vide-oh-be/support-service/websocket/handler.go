@@ -3,25 +3,49 @@ package websocket
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
 	"strings"
+	"support-service/config"
+	"support-service/connections"
 	"support-service/controllers"
+	"support-service/logging"
+	"support-service/metrics"
 	"support-service/models"
 	"support-service/utils"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/apigatewaymanagementapi"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
-func HandleConnect(ctx context.Context, req events.APIGatewayWebsocketProxyRequest, tableNameConnections string, region string) (interface{}, error) {
+var (
+	store    connections.ConnectionStore
+	poster   connections.ConnectionPoster
+	messages *controllers.MessageController
+)
+
+// Init connects the handlers to the DynamoDB connections table and the
+// API Gateway management API.
+func Init(cfg *config.Config, messageController *controllers.MessageController) error {
+	awsCfg, err := utils.GetSession(cfg.Region)
+	if err != nil {
+		return err
+	}
+	Use(connections.NewDynamoStore(awsCfg, cfg.WebSocket.TableNameConnections),
+		connections.NewAPIGatewayPoster(awsCfg, cfg.WebSocket.APIURL),
+		messageController)
+	return nil
+}
+
+// Use replaces the connection store and poster, e.g. with the in-memory
+// fakes in tests.
+func Use(connectionStore connections.ConnectionStore, connectionPoster connections.ConnectionPoster, messageController *controllers.MessageController) {
+	store, poster, messages = connectionStore, connectionPoster, messageController
+}
+
+func HandleConnect(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (interface{}, error) {
 	token := req.QueryStringParameters["token"]
 	userEmail := req.QueryStringParameters["userEmail"]
 	_, claims := utils.GetTokenClaimsFromTokenString(token)
+	logger := logging.FromContext(ctx).With("user_email", claims.Email)
 	if claims.Role != "SupportUser" && !(claims.Role == "RegisteredUser" && claims.Email == userEmail) {
 		return events.APIGatewayProxyResponse{
 			StatusCode: 401,
@@ -36,27 +60,8 @@ func HandleConnect(ctx context.Context, req events.APIGatewayWebsocketProxyReque
 		ConnectionID: connectionID,
 		UserEmail:    userEmail,
 	}
-	av, err := attributevalue.MarshalMap(m)
-	if err != nil {
-		log.Fatalln("Unable to marshal connection map", err.Error())
-	}
-
-	input := &dynamodb.PutItemInput{
-		TableName: aws.String(tableNameConnections),
-		Item:      av,
-	}
-	cfg, err := utils.GetSession(region)
-	if err != nil {
-		log.Fatalln("Unable to get AWS session")
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Body:       "Unable to get AWS session",
-		}, nil
-	}
-	db := dynamodb.NewFromConfig(cfg)
-	_, err = db.PutItem(ctx, input)
-	if err != nil {
-		log.Fatal("INSERT ERROR", err.Error())
+	if err := store.Put(ctx, m); err != nil {
+		logger.Error("unable to insert connection", "error", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: 400,
 			Body:       "Unable to insert connection",
@@ -68,27 +73,12 @@ func HandleConnect(ctx context.Context, req events.APIGatewayWebsocketProxyReque
 	}, nil
 }
 
-func HandleDisconnect(ctx context.Context, req events.APIGatewayWebsocketProxyRequest, tableNameConnections string, region string) (interface{}, error) {
+func HandleDisconnect(ctx context.Context, req events.APIGatewayWebsocketProxyRequest) (interface{}, error) {
+	logger := logging.FromContext(ctx)
 	connectionID := req.RequestContext.ConnectionID
 
-	input := &dynamodb.DeleteItemInput{
-		TableName: aws.String(tableNameConnections),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: connectionID},
-		},
-	}
-	cfg, err := utils.GetSession(region)
-	if err != nil {
-		log.Fatalln("Unable to get AWS session")
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Body:       "Unable to get AWS session",
-		}, nil
-	}
-	db := dynamodb.NewFromConfig(cfg)
-	_, err = db.DeleteItem(ctx, input)
-	if err != nil {
-		log.Fatalln("Unable to remove connection from DynamoDB", err.Error())
+	if err := store.Delete(ctx, connectionID); err != nil {
+		logger.Error("unable to remove connection from DynamoDB", "error", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: 400,
 			Body:       "Unable to remove connection",
@@ -103,63 +93,32 @@ func HandleDisconnect(ctx context.Context, req events.APIGatewayWebsocketProxyRe
 func HandleMessage(
 	ctx context.Context,
 	req events.APIGatewayWebsocketProxyRequest,
-	tableNameConnections string,
-	region string,
-	websocketApiUrl string,
 ) (interface{}, error) {
+	logger := logging.FromContext(ctx)
 	connectionID := req.RequestContext.ConnectionID
 
 	// Convert message to JSON and parse JWT claims
 	socketMessage := models.SocketMessage{}
 	if err := json.NewDecoder(strings.NewReader(req.Body)).Decode(&socketMessage); err != nil {
-		log.Println("Unable to decode body", err.Error())
+		logger.Warn("unable to decode body", "error", err)
 	}
 	_, claims := utils.GetTokenClaimsFromTokenString(socketMessage.Token)
-
-	cfg, err := utils.GetSession(region)
-	if err != nil {
-		log.Fatalln("Unable to get AWS session")
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Body:       "Unable to get AWS session",
-		}, nil
-	}
-	db := dynamodb.NewFromConfig(cfg)
+	logger = logger.With("user_email", claims.Email)
 
 	// Get connection (with user email) for connection ID
-	queryInput := &dynamodb.QueryInput{
-		TableName: aws.String(tableNameConnections),
-		ExpressionAttributeNames: map[string]string{
-			"#id": "id",
-		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":id": &types.AttributeValueMemberS{Value: connectionID},
-		},
-		KeyConditionExpression: aws.String("#id = :id"),
-	}
-	result, err := db.Query(ctx, queryInput)
+	connection, err := store.Get(ctx, connectionID)
 	if err != nil {
-		log.Println("Unable to find connection ID", err.Error())
+		logger.Error("unable to find connection ID", "error", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: 400,
 			Body:       "Unable to find connection ID",
 		}, nil
 	}
-	connections := make([]models.Connection, result.Count)
-	attributevalue.UnmarshalListOfMaps(result.Items, &connections)
-	if len(connections) > 1 {
-		log.Println("Found duplicate connection ID")
-		return events.APIGatewayProxyResponse{
-			StatusCode: 400,
-			Body:       "Found duplicate connection ID",
-		}, nil
-	}
-	connection := connections[0]
 
 	// Add new message to DB
-	message, err := controllers.AddMessage(socketMessage.Message, connection.UserEmail, claims)
+	message, err := messages.AddMessage(ctx, socketMessage.Message, connection.UserEmail, claims)
 	if err != nil {
-		fmt.Println("error: ", err)
+		logger.Error("error adding message to DB", "error", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: 400,
 			Body:       "Error adding message to DB",
@@ -167,7 +126,7 @@ func HandleMessage(
 	}
 	messageJSON, err := json.Marshal(message)
 	if err != nil {
-		fmt.Println("error: ", err)
+		logger.Error("error marshalling message to JSON", "error", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: 400,
 			Body:       "Error marshalling message to JSON",
@@ -175,38 +134,29 @@ func HandleMessage(
 	}
 
 	// Get all connections for user email
-	params := &dynamodb.ScanInput{
-		TableName:        aws.String(tableNameConnections),
-		FilterExpression: aws.String("userEmail = :userEmail"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":userEmail": &types.AttributeValueMemberS{Value: connection.UserEmail},
-		},
-	}
-	scanResult, err := db.Scan(ctx, params)
+	connectionsWithUserEmail, err := store.ByUserEmail(ctx, connection.UserEmail)
 	if err != nil {
-		log.Println("Failed to scan user email", err.Error())
+		logger.Error("failed to scan user email", "error", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: 400,
 			Body:       "Failed to scan user email",
 		}, nil
 	}
-	connectionsWithUserEmail := make([]models.Connection, scanResult.Count)
-	attributevalue.UnmarshalListOfMaps(scanResult.Items, &connectionsWithUserEmail)
 
 	// Send message to relevant connections
-	apigatewayClient := apigatewaymanagementapi.NewFromConfig(cfg, func(o *apigatewaymanagementapi.Options) {
-		o.BaseEndpoint = aws.String(strings.Replace(websocketApiUrl, "wss", "https", 1))
-	})
 	for _, connectionWithUserEmail := range connectionsWithUserEmail {
-		input := &apigatewaymanagementapi.PostToConnectionInput{
-			ConnectionId: aws.String(connectionWithUserEmail.ConnectionID),
-			Data:         messageJSON,
-		}
-		_, err = apigatewayClient.PostToConnection(context.TODO(), input)
+		err = poster.Post(ctx, connectionWithUserEmail.ConnectionID, messageJSON)
 		if err != nil {
-			log.Println("ERROR", err.Error())
+			logger.Warn("failed to post to connection", "connection_id", connectionWithUserEmail.ConnectionID, "error", err)
+			if errors.Is(err, connections.ErrGone) {
+				metrics.PostToConnectionErrors.Inc("gone")
+			} else {
+				metrics.PostToConnectionErrors.Inc("other")
+			}
 		}
 	}
+	logger.Info("message delivered", "recipients", len(connectionsWithUserEmail))
+	metrics.FanOut.Observe(float64(len(connectionsWithUserEmail)))
 
 	return events.APIGatewayProxyResponse{
 		StatusCode: 200,
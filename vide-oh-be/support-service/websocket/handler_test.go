@@ -0,0 +1,261 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"support-service/connections"
+	"support-service/controllers"
+	"support-service/models"
+	"support-service/openapi"
+	"support-service/repository"
+	"support-service/router"
+	"support-service/utils"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	openapi.SetMode(openapi.ModeEnforce)
+	os.Exit(m.Run())
+}
+
+// env wires the handlers to the in-memory connection store and poster and
+// a SQLite database of its own.
+type env struct {
+	store  *connections.MemoryStore
+	poster *connections.MemoryPoster
+	router *gin.Engine
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "support.db")), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Message{}); err != nil {
+		t.Fatal(err)
+	}
+	messages := controllers.NewMessageController(repository.New(db))
+	e := &env{store: connections.NewMemoryStore(), poster: connections.NewMemoryPoster()}
+	Use(e.store, e.poster, messages)
+	e.router = router.New(nil, messages)
+	return e
+}
+
+func token(t *testing.T, email, role string) string {
+	t.Helper()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, utils.JWTClaim{Email: email, Role: role}).SignedString([]byte("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (e *env) connect(t *testing.T, connectionID, userEmail, token string) int {
+	t.Helper()
+	out, err := HandleConnect(context.Background(), events.APIGatewayWebsocketProxyRequest{
+		QueryStringParameters: map[string]string{"token": token, "userEmail": userEmail},
+		RequestContext:        events.APIGatewayWebsocketProxyRequestContext{ConnectionID: connectionID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.(events.APIGatewayProxyResponse).StatusCode
+}
+
+func (e *env) send(t *testing.T, connectionID, token, message string) int {
+	t.Helper()
+	body, _ := json.Marshal(models.SocketMessage{Message: message, Token: token})
+	out, err := HandleMessage(context.Background(), events.APIGatewayWebsocketProxyRequest{
+		Body:           string(body),
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: connectionID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.(events.APIGatewayProxyResponse).StatusCode
+}
+
+func (e *env) get(path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", token)
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestConnectChecksTheConversation(t *testing.T) {
+	e := newEnv(t)
+	tests := []struct {
+		name      string
+		userEmail string
+		token     string
+		want      int
+	}{
+		{"own conversation", "ana@mail.com", token(t, "ana@mail.com", "RegisteredUser"), http.StatusOK},
+		{"support", "ana@mail.com", token(t, "support@mail.com", "SupportUser"), http.StatusOK},
+		{"someone else's conversation", "ana@mail.com", token(t, "bob@mail.com", "RegisteredUser"), http.StatusUnauthorized},
+		{"administrator", "ana@mail.com", token(t, "admin@mail.com", "Administrator"), http.StatusUnauthorized},
+		{"no token", "ana@mail.com", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.connect(t, tt.name, tt.userEmail, tt.token); got != tt.want {
+				t.Fatalf("status = %d, want %d", got, tt.want)
+			}
+			_, err := e.store.Get(context.Background(), tt.name)
+			if stored := err == nil; stored != (tt.want == http.StatusOK) {
+				t.Fatalf("connection stored = %v", stored)
+			}
+		})
+	}
+}
+
+func TestMessageFansOutToTheConversation(t *testing.T) {
+	e := newEnv(t)
+	ana := token(t, "ana@mail.com", "RegisteredUser")
+	support := token(t, "support@mail.com", "SupportUser")
+	for _, c := range []struct{ id, userEmail, token string }{
+		{"ana-phone", "ana@mail.com", ana},
+		{"ana-laptop", "ana@mail.com", ana},
+		{"support-ana", "ana@mail.com", support},
+		{"support-bob", "bob@mail.com", support},
+	} {
+		if got := e.connect(t, c.id, c.userEmail, c.token); got != http.StatusOK {
+			t.Fatalf("connect %s: status %d", c.id, got)
+		}
+	}
+	// A client that vanished without $disconnect must not stop the others
+	e.poster.Gone("ana-laptop")
+
+	if got := e.send(t, "support-ana", support, "How can we help?"); got != http.StatusOK {
+		t.Fatalf("status = %d", got)
+	}
+
+	posts := e.poster.Posts()
+	var recipients []string
+	for _, post := range posts {
+		recipients = append(recipients, post.ConnectionID)
+	}
+	if want := []string{"ana-phone", "support-ana"}; !slices.Equal(recipients, want) {
+		t.Fatalf("posted to %v, want %v", recipients, want)
+	}
+	var posted models.Message
+	if err := json.Unmarshal(posts[0].Data, &posted); err != nil {
+		t.Fatal(err)
+	}
+	if posted.Content != "How can we help?" || posted.OwnerEmail != "ana@mail.com" || posted.SentByUser {
+		t.Fatalf("posted %+v", posted)
+	}
+
+	// The message is stored in the conversation
+	rec := e.get("/api/v2/messages/conversations/ana@mail.com", ana)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET conversation: %d %s", rec.Code, rec.Body)
+	}
+	var stored []models.Message
+	if err := json.Unmarshal(rec.Body.Bytes(), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].ID != posted.ID || stored[0].Content != posted.Content {
+		t.Fatalf("conversation = %+v", stored)
+	}
+}
+
+func TestMessageFromUnknownConnection(t *testing.T) {
+	e := newEnv(t)
+	if got := e.send(t, "never-connected", token(t, "ana@mail.com", "RegisteredUser"), "hi"); got != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", got)
+	}
+	if posts := e.poster.Posts(); len(posts) != 0 {
+		t.Fatalf("posted %d frames", len(posts))
+	}
+}
+
+func TestDisconnectStopsTheFanOut(t *testing.T) {
+	e := newEnv(t)
+	ana := token(t, "ana@mail.com", "RegisteredUser")
+	e.connect(t, "ana-phone", "ana@mail.com", ana)
+	e.connect(t, "ana-laptop", "ana@mail.com", ana)
+
+	out, err := HandleDisconnect(context.Background(), events.APIGatewayWebsocketProxyRequest{
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "ana-laptop"},
+	})
+	if err != nil || out.(events.APIGatewayProxyResponse).StatusCode != http.StatusOK {
+		t.Fatalf("disconnect: %v %v", out, err)
+	}
+	e.send(t, "ana-phone", ana, "hello?")
+
+	posts := e.poster.Posts()
+	if len(posts) != 1 || posts[0].ConnectionID != "ana-phone" {
+		t.Fatalf("posts = %+v", posts)
+	}
+}
+
+func TestConversationsAreProblemsForOtherUsers(t *testing.T) {
+	e := newEnv(t)
+	rec := e.get("/api/v2/messages/conversations/ana@mail.com", token(t, "bob@mail.com", "RegisteredUser"))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	var problem struct {
+		Status int    `json:"status"`
+		Code   string `json:"code"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Status != http.StatusForbidden || problem.Code != "forbidden" {
+		t.Fatalf("problem = %+v", problem)
+	}
+}
+
+func TestConversations(t *testing.T) {
+	e := newEnv(t)
+	ana := token(t, "ana@mail.com", "RegisteredUser")
+	support := token(t, "support@mail.com", "SupportUser")
+
+	// Empty lists are [], never null
+	for _, path := range []string{"/api/v2/messages/conversations", "/api/v2/messages/conversations/ana@mail.com"} {
+		if rec := e.get(path, support); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+			t.Fatalf("%s: %d %s", path, rec.Code, rec.Body)
+		}
+	}
+	e.connect(t, "ana-phone", "ana@mail.com", ana)
+	e.send(t, "ana-phone", ana, "hello?")
+
+	rec := e.get("/api/v2/messages/conversations", support)
+	var emails []string
+	if err := json.Unmarshal(rec.Body.Bytes(), &emails); err != nil || !slices.Equal(emails, []string{"ana@mail.com"}) {
+		t.Fatalf("conversations = %s, %v", rec.Body, err)
+	}
+	if rec := e.get("/api/v2/messages/conversations", ana); rec.Code != http.StatusForbidden {
+		t.Fatalf("as a registered user: status %d", rec.Code)
+	}
+
+	// The v1 route answers the same, marked deprecated
+	rec = e.get("/api/messages/user-emails", support)
+	if rec.Code != http.StatusOK || rec.Header().Get("Deprecation") == "" {
+		t.Fatalf("v1: %d %v", rec.Code, rec.Header())
+	}
+}
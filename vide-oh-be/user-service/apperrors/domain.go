@@ -0,0 +1,22 @@
+package apperrors
+
+import "net/http"
+
+const (
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeEmailTaken         Code = "email_taken"
+	CodeWeakPassword       Code = "weak_password"
+	CodeUserBlocked        Code = "user_blocked"
+)
+
+var (
+	ErrInvalidCredentials = New(http.StatusUnauthorized, CodeInvalidCredentials, "invalid email or password")
+	ErrEmailTaken         = New(http.StatusConflict, CodeEmailTaken, "an account with this email already exists")
+	ErrUserBlocked        = New(http.StatusForbidden, CodeUserBlocked, "user account is blocked")
+)
+
+// WeakPassword reports a password rejected by the policy; the policy
+// message lists what is missing.
+func WeakPassword(err error) *Error {
+	return New(http.StatusBadRequest, CodeWeakPassword, err.Error()).Wrap(err)
+}
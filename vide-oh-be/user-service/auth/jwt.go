@@ -12,10 +12,11 @@ import (
 var jwtKey []byte
 
 func SetJwtKey(secretKey string) {
-	_, err := base64.StdEncoding.DecodeString(secretKey)
+	key, err := base64.StdEncoding.DecodeString(secretKey)
 	if err != nil {
 		log.Fatalf("Failed to decode base64 string: %v", err)
 	}
+	jwtKey = key
 }
 
 type JWTClaim struct {
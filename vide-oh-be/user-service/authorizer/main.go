@@ -1,20 +1,36 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"net/url"
-	"os"
+	"log/slog"
 	"user-service/auth"
+	"user-service/config"
 	"user-service/database"
+	"user-service/logging"
 	"user-service/middleware"
-	"user-service/utils"
+	"user-service/repository"
+	"user-service/tracing"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func handler(request events.APIGatewayV2CustomAuthorizerV1Request) (events.APIGatewayCustomAuthorizerResponse, error) {
+var users repository.UserRepository
+
+func handler(ctx context.Context, request events.APIGatewayV2CustomAuthorizerV1Request) (events.APIGatewayCustomAuthorizerResponse, error) {
+	logger := slog.Default().With("request_id", request.RequestContext.RequestID, "correlation_id", request.RequestContext.RequestID)
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(request.Headers))
+	ctx, span := tracing.Start(ctx, "authorize", trace.WithSpanKind(trace.SpanKindServer))
+	defer tracing.Flush(ctx)
+	defer span.End()
+
 	token := request.Headers["Authorization"]
 	if token == "" {
 		token = request.QueryStringParameters["token"]
@@ -24,9 +40,10 @@ func handler(request events.APIGatewayV2CustomAuthorizerV1Request) (events.APIGa
 	}
 
 	// Validate token
-	err, claims := middleware.ValidateTokenForLambdaAuthorizer(token)
+	err, claims := middleware.ValidateTokenForLambdaAuthorizer(ctx, users, token)
 	if err != nil {
-		fmt.Println(err)
+		logger.Warn("authorization denied", "error", err)
+		span.SetAttributes(attribute.Bool("authorized", false))
 		return generateForbiddenResponse(fmt.Sprintf("unauthorized: %v", err)), nil
 	}
 
@@ -94,35 +111,23 @@ func generatePolicy(principalId, effect, resource string) events.APIGatewayCusto
 }
 
 func main() {
-	// Load env vars
-	dbSecretName := os.Getenv("DB_SECRET_NAME")
-	if dbSecretName == "" {
-		log.Fatal("DB_SECRET_NAME environment variable is not set")
-	}
-	region := os.Getenv("REGION")
-	if region == "" {
-		log.Fatal("REGION environment variable is not set")
+	// Load and validate configuration (env, CONFIG_FILE, Secrets Manager)
+	cfg, err := config.Load(false)
+	if err != nil {
+		log.Fatal(err)
 	}
-	keySecretName := os.Getenv("KEY_SECRET_NAME")
-	if keySecretName == "" {
-		log.Fatal("KEY_SECRET_NAME environment variable is not set")
+	slog.SetDefault(logging.New("user-authorizer", cfg.LogLevel))
+	if err := tracing.Init(context.Background(), "user-authorizer", cfg.Tracing.Endpoint, cfg.Tracing.SamplePercent); err != nil {
+		log.Fatal(err)
 	}
+	auth.SetJwtKey(cfg.JWTKey)
 
-	// Fetch secrets from SM
-	dbSecret, keySecret, err := utils.GetSecrets(dbSecretName, keySecretName, region)
+	credentials, err := database.NewCredentialSource(cfg)
 	if err != nil {
-		log.Fatalf("Failed to retrieve secret: %v", err)
+		log.Fatal(err)
 	}
-	connectionString := fmt.Sprintf("postgresql://%s:%s@%s:%d/%s",
-		dbSecret.Username,
-		url.QueryEscape(dbSecret.Password),
-		dbSecret.Host,
-		dbSecret.Port,
-		dbSecret.DBName,
-	)
-	auth.SetJwtKey(keySecret.SecretKey)
-
-	database.Connect(connectionString)
+	database.Connect(credentials)
+	users = repository.New(database.Instance).Users
 
 	lambda.Start(handler)
 }
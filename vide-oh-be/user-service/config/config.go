@@ -0,0 +1,196 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+	"user-service/password"
+)
+
+type Config struct {
+	// Local is set by the -local flag: settings come from the environment
+	// only and Secrets Manager is not used
+	Local bool
+
+	Region        string `env:"REGION" required:"lambda"`
+	LogLevel      string `env:"LOG_LEVEL" default:"info"`
+	DBSecretName  string `env:"DB_SECRET_NAME"`
+	DB            DB
+	KeySecretName string `env:"KEY_SECRET_NAME" required:"lambda"`
+
+	// Used instead of DB_SECRET_NAME/DB in local mode
+	DatabaseDSN string `env:"DB_DSN" required:"local"`
+	// postgres, or sqlite in local mode, where DB_DSN is the database file
+	DatabaseDriver string `env:"DB_DRIVER" default:"postgres"`
+	// Filled from Secrets Manager on Lambda
+	JWTKey string `env:"JWT_SECRET_KEY" required:"local"`
+
+	SMTP     SMTP
+	Password Password
+	Tracing  Tracing
+
+	// off, report or enforce; defaults to enforce in local mode
+	OpenAPIValidation string `env:"OPENAPI_VALIDATION"`
+}
+
+const (
+	DBAuthSecret = "secret"
+	DBAuthIAM    = "iam"
+)
+
+const (
+	DBDriverPostgres = "postgres"
+	DBDriverSQLite   = "sqlite"
+)
+
+// DB selects how connections authenticate outside local mode: with the RDS
+// secret named by DB_SECRET_NAME, or with IAM auth tokens (e.g. through
+// RDS Proxy) for the user and endpoint given here.
+type DB struct {
+	AuthMode  string        `env:"DB_AUTH_MODE" default:"secret"`
+	SecretTTL time.Duration `env:"DB_SECRET_TTL" default:"5m"`
+	Host      string        `env:"DB_HOST"`
+	Port      int           `env:"DB_PORT" default:"5432"`
+	User      string        `env:"DB_USER"`
+	Name      string        `env:"DB_NAME"`
+}
+
+func (db DB) validate(secretName string, errs *Error) {
+	switch db.AuthMode {
+	case DBAuthSecret:
+		if secretName == "" {
+			errs.Missing = append(errs.Missing, "DB_SECRET_NAME")
+		}
+	case DBAuthIAM:
+		if db.Host == "" {
+			errs.Missing = append(errs.Missing, "DB_HOST")
+		}
+		if db.User == "" {
+			errs.Missing = append(errs.Missing, "DB_USER")
+		}
+		if db.Name == "" {
+			errs.Missing = append(errs.Missing, "DB_NAME")
+		}
+	default:
+		errs.Invalid = append(errs.Invalid, fmt.Sprintf("DB_AUTH_MODE: %q is not one of secret, iam", db.AuthMode))
+	}
+}
+
+type SMTP struct {
+	Host string `env:"SMTP_HOST" default:"localhost"`
+	Port int    `env:"SMTP_PORT" default:"1025"`
+	From string `env:"MAIL_FROM" default:"vide.oh@smtp.com"`
+}
+
+type Password struct {
+	MinLength      int  `env:"PASSWORD_MIN_LENGTH" default:"8"`
+	MaxLength      int  `env:"PASSWORD_MAX_LENGTH" default:"128"`
+	RequireUpper   bool `env:"PASSWORD_REQUIRE_UPPER" default:"false"`
+	RequireLower   bool `env:"PASSWORD_REQUIRE_LOWER" default:"true"`
+	RequireDigit   bool `env:"PASSWORD_REQUIRE_DIGIT" default:"true"`
+	RequireSymbol  bool `env:"PASSWORD_REQUIRE_SYMBOL" default:"false"`
+	RejectBreached bool `env:"PASSWORD_REJECT_BREACHED" default:"true"`
+}
+
+// Tracing is off unless an OTLP/HTTP endpoint is set, e.g. a local
+// collector on http://localhost:4318.
+type Tracing struct {
+	Endpoint      string `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	SamplePercent int    `env:"TRACING_SAMPLE_PERCENT" default:"100"`
+}
+
+func (p Password) Policy() password.Policy {
+	return password.Policy{
+		MinLength:      p.MinLength,
+		MaxLength:      p.MaxLength,
+		RequireUpper:   p.RequireUpper,
+		RequireLower:   p.RequireLower,
+		RequireDigit:   p.RequireDigit,
+		RequireSymbol:  p.RequireSymbol,
+		RejectBreached: p.RejectBreached,
+	}
+}
+
+// Load reads the configuration from the environment, the optional JSON file
+// named by CONFIG_FILE and, outside local mode, the JWT key from Secrets
+// Manager. Every missing or malformed value is reported in a single error.
+// Database credentials are fetched later by the database package so they
+// can be refreshed after a rotation.
+func Load(local bool) (*Config, error) {
+	get, err := newLookup()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{Local: local}
+	errs := &Error{}
+	populate(cfg, get, local, errs)
+	if cfg.Password.MaxLength > 0 && cfg.Password.MinLength > cfg.Password.MaxLength {
+		errs.Invalid = append(errs.Invalid, "PASSWORD_MIN_LENGTH: greater than PASSWORD_MAX_LENGTH")
+	}
+	if cfg.Tracing.SamplePercent < 0 || cfg.Tracing.SamplePercent > 100 {
+		errs.Invalid = append(errs.Invalid, "TRACING_SAMPLE_PERCENT: must be between 0 and 100")
+	}
+	if cfg.OpenAPIValidation == "" {
+		cfg.OpenAPIValidation = "off"
+		if local {
+			cfg.OpenAPIValidation = "enforce"
+		}
+	}
+	switch cfg.OpenAPIValidation {
+	case "off", "report", "enforce":
+	default:
+		errs.Invalid = append(errs.Invalid, fmt.Sprintf("OPENAPI_VALIDATION: %q is not one of off, report, enforce", cfg.OpenAPIValidation))
+	}
+	switch cfg.DatabaseDriver {
+	case DBDriverPostgres:
+	case DBDriverSQLite:
+		if !local {
+			errs.Invalid = append(errs.Invalid, "DB_DRIVER: sqlite is only supported in local mode")
+		}
+	default:
+		errs.Invalid = append(errs.Invalid, fmt.Sprintf("DB_DRIVER: %q is not one of postgres, sqlite", cfg.DatabaseDriver))
+	}
+	if !local {
+		cfg.DB.validate(cfg.DBSecretName, errs)
+	}
+	if !errs.empty() {
+		return nil, errs
+	}
+
+	if !local {
+		if err := cfg.loadSecrets(context.TODO()); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(cfg.JWTKey); err != nil {
+		return nil, &Error{Invalid: []string{"JWT_SECRET_KEY: not valid base64"}}
+	}
+
+	return cfg, nil
+}
+
+func (cfg *Config) loadSecrets(ctx context.Context) error {
+	client, err := newSecretsClient(ctx, cfg.Region)
+	if err != nil {
+		return err
+	}
+
+	var keySecret KeySecret
+	if err := getSecret(ctx, client, cfg.KeySecretName, &keySecret); err != nil {
+		return err
+	}
+	if keySecret.SecretKey == "" {
+		return &Error{Missing: []string{cfg.KeySecretName + ".secretKey"}}
+	}
+
+	cfg.JWTKey = keySecret.SecretKey
+
+	return nil
+}
+
+type KeySecret struct {
+	SecretKey string `json:"secretKey"`
+}
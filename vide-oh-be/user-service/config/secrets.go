@@ -0,0 +1,34 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+func newSecretsClient(ctx context.Context, region string) (*secretsmanager.Client, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	return secretsmanager.NewFromConfig(cfg), nil
+}
+
+func getSecret(ctx context.Context, client *secretsmanager.Client, secretName string, out interface{}) error {
+	result, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to retrieve secret %s: %v", secretName, err)
+	}
+
+	if err := json.Unmarshal([]byte(*result.SecretString), out); err != nil {
+		return fmt.Errorf("failed to unmarshal secret %s: %v", secretName, err)
+	}
+
+	return nil
+}
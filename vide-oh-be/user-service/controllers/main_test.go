@@ -0,0 +1,147 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"user-service/apperrors"
+	"user-service/auth"
+	"user-service/models"
+	"user-service/openapi"
+	"user-service/repository"
+	"user-service/router"
+	"user-service/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The tests drive the router the Lambda serves, with the OpenAPI document
+// enforced on every request and response, against SQLite.
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	openapi.SetMode(openapi.ModeEnforce)
+	auth.SetJwtKey(base64.StdEncoding.EncodeToString([]byte("test signing key")))
+	// Mails fail fast and are only logged
+	utils.SetMailServer("127.0.0.1", 1, "vide.oh@smtp.com")
+	os.Exit(m.Run())
+}
+
+// strong passes the password policy.
+const strong = "correct horse 42"
+
+type env struct {
+	t      *testing.T
+	db     *gorm.DB
+	router *gin.Engine
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "users.db")), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatal(err)
+	}
+	return &env{t: t, db: db, router: router.New(repository.New(db))}
+}
+
+// user stores a user with password strong and returns a token for them.
+func (e *env) user(email string, role models.UserRole) string {
+	e.t.Helper()
+	user := models.User{Name: "Test", Email: email, Role: role}
+	if err := user.HashPassword(strong); err != nil {
+		e.t.Fatal(err)
+	}
+	if err := e.db.Create(&user).Error; err != nil {
+		e.t.Fatal(err)
+	}
+	token, err := auth.GenerateJWT(email, role.String())
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return token
+}
+
+func (e *env) stored(email string) models.User {
+	e.t.Helper()
+	var user models.User
+	if err := e.db.Where("email = ?", email).First(&user).Error; err != nil {
+		e.t.Fatal(err)
+	}
+	return user
+}
+
+// do sends a request as the user of token, if any. headers are name,
+// value pairs.
+func (e *env) do(method, path, token string, body io.Reader, headers ...string) *httptest.ResponseRecorder {
+	e.t.Helper()
+	req := httptest.NewRequest(method, path, body)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (e *env) get(path, token string, headers ...string) *httptest.ResponseRecorder {
+	e.t.Helper()
+	return e.do(http.MethodGet, path, token, nil, headers...)
+}
+
+// send sends value as the JSON body.
+func (e *env) send(method, path, token string, value any, headers ...string) *httptest.ResponseRecorder {
+	e.t.Helper()
+	body, err := json.Marshal(value)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return e.do(method, path, token, bytes.NewReader(body), headers...)
+}
+
+// decode checks the status of rec and decodes its JSON body.
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder, status int) T {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, status, rec.Body)
+	}
+	var value T
+	if err := json.Unmarshal(rec.Body.Bytes(), &value); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	return value
+}
+
+// problem checks that rec is a problem document with status and code.
+func problem(t *testing.T, rec *httptest.ResponseRecorder, status int, code apperrors.Code) apperrors.Problem {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != apperrors.ContentType {
+		t.Fatalf("Content-Type = %q, want %s: %s", ct, apperrors.ContentType, rec.Body)
+	}
+	p := decode[apperrors.Problem](t, rec, status)
+	if p.Status != status || p.Code != code || p.Type != apperrors.TypeURI(code) {
+		t.Fatalf("problem = %+v, want %d %s", p, status, code)
+	}
+	return p
+}
@@ -1,13 +1,16 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
+	"user-service/apperrors"
 	"user-service/auth"
-	"user-service/database"
-	"user-service/models"
+	"user-service/logging"
+	"user-service/metrics"
+	"user-service/password"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type TokenRequest struct {
@@ -15,39 +18,58 @@ type TokenRequest struct {
 	Password string `json:"password"`
 }
 
-func Login(context *gin.Context) {
+func (u *UserController) Login(context *gin.Context) {
 	var request TokenRequest
-	var user models.User
+	logger := logging.FromContext(context.Request.Context())
 	if err := context.ShouldBindJSON(&request); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		fmt.Println("xd")
-		context.Abort()
+		metrics.Logins.Inc("invalid_request")
+		apperrors.Abort(context, apperrors.Invalid(err))
 		return
 	}
-	fmt.Println(request)
+	logger = logger.With("user_email", request.Email)
+	context.Request = context.Request.WithContext(logging.WithLogger(context.Request.Context(), logger))
 
-	record := database.Instance.Where("email = ?", request.Email).First(&user)
-	if record.Error != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": record.Error.Error()})
-		fmt.Println("xd2")
-		context.Abort()
+	user, err := u.repos.Users.ByEmail(context.Request.Context(), request.Email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Same answer, after the same hashing work, as a wrong password, so
+		// emails cannot be probed
+		password.VerifyDummy(request.Password)
+		metrics.Logins.Inc("unknown_user")
+		apperrors.Abort(context, apperrors.ErrInvalidCredentials.Wrap(err))
+		return
+	}
+	if err != nil {
+		metrics.Logins.Inc("error")
+		apperrors.Abort(context, apperrors.FromDB(err, "user"))
 		return
 	}
 
 	credentialError := user.CheckPassword(request.Password)
 	if credentialError != nil {
-		context.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
-		fmt.Println("xd3")
-		context.Abort()
+		metrics.Logins.Inc("invalid_credentials")
+		apperrors.Abort(context, apperrors.ErrInvalidCredentials.Wrap(credentialError))
 		return
 	}
 
+	// Transparently upgrade legacy bcrypt hashes and outdated parameters
+	if user.PasswordNeedsRehash() {
+		if err := user.HashPassword(request.Password); err != nil {
+			metrics.PasswordRehashes.Inc("error")
+			logger.Error("failed to rehash password", "error", err)
+		} else if err := u.repos.Users.UpdatePassword(context.Request.Context(), user); err != nil {
+			metrics.PasswordRehashes.Inc("error")
+			logger.Error("failed to store rehashed password", "error", err)
+		} else {
+			metrics.PasswordRehashes.Inc("upgraded")
+		}
+	}
+
 	tokenString, err := auth.GenerateJWT(user.Email, user.Role.String())
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		fmt.Println("xd4")
-		context.Abort()
+		metrics.Logins.Inc("error")
+		apperrors.Abort(context, apperrors.Internal(err))
 		return
 	}
+	metrics.Logins.Inc("success")
 	context.JSON(http.StatusOK, gin.H{"token": tokenString})
 }
@@ -1,80 +1,104 @@
 package controllers
 
 import (
+	"context"
 	"net/http"
-	"user-service/database"
+	"user-service/apperrors"
+	"user-service/logging"
 	"user-service/models"
+	"user-service/password"
+	"user-service/repository"
 	"user-service/utils"
 
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterUser(context *gin.Context) {
+type UserController struct {
+	repos repository.Repositories
+}
+
+func NewUserController(repos repository.Repositories) *UserController {
+	return &UserController{repos: repos}
+}
+
+func (u *UserController) RegisterUser(context *gin.Context) {
 	var user models.User
 	if err := context.ShouldBindJSON(&user); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		context.Abort()
+		apperrors.Abort(context, apperrors.Invalid(err))
 		return
 	}
-	if err := user.HashPassword(user.Password); err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		context.Abort()
+	if err := u.create(context.Request.Context(), &user); err != nil {
+		apperrors.Abort(context, err)
 		return
 	}
+	context.JSON(http.StatusCreated, gin.H{"userId": user.ID, "email": user.Email})
+}
+
+// create stores user as a registered user, hashing the plain password it
+// holds.
+func (u *UserController) create(ctx context.Context, user *models.User) error {
+	if err := password.CurrentPolicy().Validate(user.Password); err != nil {
+		return apperrors.WeakPassword(err)
+	}
+	if err := user.HashPassword(user.Password); err != nil {
+		return apperrors.Internal(err)
+	}
 	user.Role = models.RegisteredUser
-	record := database.Instance.Create(&user)
-	if record.Error != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": record.Error.Error()})
-		context.Abort()
-		return
+	if dbErr := u.repos.Users.Create(ctx, user); dbErr != nil {
+		err := apperrors.FromDB(dbErr, "user")
+		if err.Code == apperrors.CodeConflict {
+			err = apperrors.ErrEmailTaken.Wrap(dbErr)
+		}
+		return err
 	}
-	context.JSON(http.StatusCreated, gin.H{"userId": user.ID, "email": user.Email})
+	return nil
 }
 
-func BlockUser(context *gin.Context) {
+func (u *UserController) BlockUser(context *gin.Context) {
 	// RBAC
 	_, claims := utils.GetTokenClaims(context)
 	if claims.Role != models.Administrator.String() {
-		context.JSON(401, gin.H{"error": "unauthorized role"})
-		context.Abort()
+		apperrors.Abort(context, apperrors.Forbidden("administrator role required"))
 		return
 	}
 
-	userEmail := context.Param("email")
-	var user models.User
-
-	if err := database.Instance.Where("email = ?", userEmail).First(&user).Error; err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		context.Abort()
+	reason := strings.TrimSpace(context.Query("reason"))
+	user, err := u.update(context.Request.Context(), byEmail(context.Param("email")), func(user *models.User) error {
+		user.Blocked = true
+		user.BlockedReason = reason
+		return nil
+	})
+	if err != nil {
+		apperrors.Abort(context, apperrors.FromDB(err, "user"))
 		return
 	}
+	logging.FromContext(context.Request.Context()).Info("user blocked", "blocked_email", user.Email, "reason", reason)
 
-	user.Blocked = true
-
-	database.Instance.Save(&user)
-
-	utils.SendBlockedMail(user.Email)
+	utils.SendBlockedMail(context.Request.Context(), user.Email, reason)
 
 	context.Status(http.StatusOK)
 }
 
-func GetAllRegisteredUsers(context *gin.Context) {
+func (u *UserController) GetAllRegisteredUsers(context *gin.Context) {
 	_, claims := utils.GetTokenClaims(context)
 	if claims.Role != models.Administrator.String() {
-		context.JSON(401, gin.H{"error": "unauthorized role"})
-		context.Abort()
+		apperrors.Abort(context, apperrors.Forbidden("administrator role required"))
 		return
 	}
 
-	var users []models.User
-	database.Instance.Where("role = ?", models.RegisteredUser).Find(&users)
+	users, err := u.repos.Users.ListByRole(context.Request.Context(), models.RegisteredUser)
+	if err != nil {
+		apperrors.Abort(context, apperrors.FromDB(err, "user"))
+		return
+	}
 
 	context.JSON(http.StatusOK, users)
 }
 
-func GetUserById(context *gin.Context) {
+func (u *UserController) GetUserById(context *gin.Context) {
 	// // RBAC
 	// _, claims := utils.GetTokenClaims(context)
 	// if claims.Role != models.Administrator.String() {
@@ -83,47 +107,83 @@ func GetUserById(context *gin.Context) {
 	// 	return
 	// }
 
-	userId := context.Param("id")
-	var user models.User
+	userId, err := strconv.ParseUint(context.Param("id"), 10, 64)
+	if err != nil {
+		apperrors.Abort(context, apperrors.InvalidRequest("user id must be a positive integer"))
+		return
+	}
 
-	if err := database.Instance.First(&user, userId).Error; err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		context.Abort()
+	user, err := u.repos.Users.ByID(context.Request.Context(), userId)
+	if err != nil {
+		apperrors.Abort(context, apperrors.FromDB(err, "user"))
 		return
 	}
 
 	context.JSON(http.StatusOK, user)
 }
 
-func GetCurrentUser(context *gin.Context) {
+func (u *UserController) GetCurrentUser(context *gin.Context) {
 	_, claims := utils.GetTokenClaims(context)
 
-	var user models.User
-	database.Instance.Where("email = ?", claims.Email).First(&user)
+	user, err := u.repos.Users.ByEmail(context.Request.Context(), claims.Email)
+	if err != nil {
+		apperrors.Abort(context, apperrors.FromDB(err, "user"))
+		return
+	}
 
 	context.JSON(http.StatusOK, user)
 }
 
-func ChangeName(context *gin.Context) {
+func (u *UserController) ChangeName(context *gin.Context) {
 	_, claims := utils.GetTokenClaims(context)
 
-	var user models.User
-	if err := database.Instance.Where("email = ?", claims.Email).First(&user).Error; err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		context.Abort()
+	name := context.Query("name")
+	if strings.TrimSpace(name) == "" {
+		apperrors.Abort(context, apperrors.InvalidRequest("name must not be blank"))
 		return
 	}
 
-	name := context.Query("name")
-	if strings.TrimSpace(name) == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "white space string"})
-		context.Abort()
+	_, err := u.update(context.Request.Context(), byEmail(claims.Email), func(user *models.User) error {
+		user.Name = name
+		return nil
+	})
+	if err != nil {
+		apperrors.Abort(context, apperrors.FromDB(err, "user"))
 		return
 	}
 
-	user.Name = name
+	context.Status(http.StatusOK)
+}
 
-	database.Instance.Save(&user)
+// update loads a user with find, applies change and saves it in one
+// transaction. The row stays locked in between, so the If-Match checks
+// in change cannot lose a concurrent update. A change that returns an
+// error rolls it back.
+func (u *UserController) update(ctx context.Context, find finder, change func(*models.User) error) (*models.User, error) {
+	var user *models.User
+	err := u.repos.UnitOfWork.Do(ctx, func(tx repository.Repositories) error {
+		var err error
+		if user, err = find(ctx, tx.Users.ForUpdate()); err != nil {
+			return err
+		}
+		if err := change(user); err != nil {
+			return err
+		}
+		return tx.Users.Save(ctx, user)
+	})
+	return user, err
+}
 
-	context.Status(http.StatusOK)
+type finder func(context.Context, repository.UserRepository) (*models.User, error)
+
+func byEmail(email string) finder {
+	return func(ctx context.Context, users repository.UserRepository) (*models.User, error) {
+		return users.ByEmail(ctx, email)
+	}
+}
+
+func byID(id uint64) finder {
+	return func(ctx context.Context, users repository.UserRepository) (*models.User, error) {
+		return users.ByID(ctx, id)
+	}
 }
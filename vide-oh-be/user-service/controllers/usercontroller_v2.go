@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"user-service/apperrors"
+	"user-service/etag"
+	"user-service/logging"
+	"user-service/models"
+	"user-service/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The /api/v2 handlers. They take JSON bodies instead of query strings,
+// never return the password hash and version users with ETags.
+
+type Registration struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type UserPatch struct {
+	Name *string `json:"name"`
+}
+
+type BlockRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (u *UserController) CreateUser(context *gin.Context) {
+	var request Registration
+	if err := context.ShouldBindJSON(&request); err != nil {
+		apperrors.Abort(context, apperrors.Invalid(err))
+		return
+	}
+	user := models.User{Name: request.Name, Email: request.Email, Password: request.Password}
+	if err := u.create(context.Request.Context(), &user); err != nil {
+		apperrors.Abort(context, err)
+		return
+	}
+	context.Header("Location", strings.TrimSuffix(context.FullPath(), "/")+"/"+strconv.FormatUint(uint64(user.ID), 10))
+	writeUser(context, http.StatusCreated, &user)
+}
+
+func (u *UserController) ListUsers(context *gin.Context) {
+	if !isAdministrator(context) {
+		return
+	}
+
+	users, err := u.repos.Users.ListByRole(context.Request.Context(), models.RegisteredUser)
+	if err != nil {
+		apperrors.Abort(context, apperrors.FromDB(err, "user"))
+		return
+	}
+
+	list := make([]models.UserDTO, 0, len(users))
+	for _, user := range users {
+		list = append(list, user.DTO())
+	}
+	context.JSON(http.StatusOK, list)
+}
+
+func (u *UserController) GetUser(context *gin.Context) {
+	id, ok := userID(context)
+	if !ok {
+		return
+	}
+	user, err := u.repos.Users.ByID(context.Request.Context(), id)
+	if err != nil {
+		apperrors.Abort(context, apperrors.FromDB(err, "user"))
+		return
+	}
+	readUser(context, user)
+}
+
+func (u *UserController) GetMe(context *gin.Context) {
+	_, claims := utils.GetTokenClaims(context)
+	user, err := u.repos.Users.ByEmail(context.Request.Context(), claims.Email)
+	if err != nil {
+		apperrors.Abort(context, apperrors.FromDB(err, "user"))
+		return
+	}
+	readUser(context, user)
+}
+
+// UpdateMe applies a partial update of the current user. If-Match is
+// required so concurrent edits are not lost.
+func (u *UserController) UpdateMe(context *gin.Context) {
+	_, claims := utils.GetTokenClaims(context)
+
+	var patch UserPatch
+	if err := context.ShouldBindJSON(&patch); err != nil {
+		apperrors.Abort(context, apperrors.Invalid(err))
+		return
+	}
+	if patch.Name != nil && strings.TrimSpace(*patch.Name) == "" {
+		apperrors.Abort(context, apperrors.InvalidRequest("name must not be blank"))
+		return
+	}
+
+	ifMatch := context.GetHeader("If-Match")
+	user, err := u.update(context.Request.Context(), byEmail(claims.Email), func(user *models.User) error {
+		if err := etag.Check(ifMatch, etag.Of(user.ID, user.UpdatedAt), true); err != nil {
+			return err
+		}
+		if patch.Name != nil {
+			user.Name = *patch.Name
+		}
+		return nil
+	})
+	if err != nil {
+		apperrors.Abort(context, updateError(err))
+		return
+	}
+	writeUser(context, http.StatusOK, user)
+}
+
+// SetBlock blocks a user and mails them the reason. If-Match is optional.
+func (u *UserController) SetBlock(context *gin.Context) {
+	if !isAdministrator(context) {
+		return
+	}
+	id, ok := userID(context)
+	if !ok {
+		return
+	}
+	var request BlockRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		apperrors.Abort(context, apperrors.Invalid(err))
+		return
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		apperrors.Abort(context, apperrors.InvalidRequest("reason must not be blank"))
+		return
+	}
+
+	ifMatch := context.GetHeader("If-Match")
+	user, err := u.update(context.Request.Context(), byID(id), func(user *models.User) error {
+		if err := etag.Check(ifMatch, etag.Of(user.ID, user.UpdatedAt), false); err != nil {
+			return err
+		}
+		user.Blocked = true
+		user.BlockedReason = reason
+		return nil
+	})
+	if err != nil {
+		apperrors.Abort(context, updateError(err))
+		return
+	}
+	logging.FromContext(context.Request.Context()).Info("user blocked", "blocked_email", user.Email, "reason", reason)
+
+	utils.SendBlockedMail(context.Request.Context(), user.Email, reason)
+
+	writeUser(context, http.StatusOK, user)
+}
+
+// ClearBlock unblocks a user. The body with the reason for the mail is
+// optional.
+func (u *UserController) ClearBlock(context *gin.Context) {
+	if !isAdministrator(context) {
+		return
+	}
+	id, ok := userID(context)
+	if !ok {
+		return
+	}
+	var request BlockRequest
+	if err := context.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		apperrors.Abort(context, apperrors.Invalid(err))
+		return
+	}
+	reason := strings.TrimSpace(request.Reason)
+
+	ifMatch := context.GetHeader("If-Match")
+	user, err := u.update(context.Request.Context(), byID(id), func(user *models.User) error {
+		if err := etag.Check(ifMatch, etag.Of(user.ID, user.UpdatedAt), false); err != nil {
+			return err
+		}
+		user.Blocked = false
+		user.BlockedReason = ""
+		return nil
+	})
+	if err != nil {
+		apperrors.Abort(context, updateError(err))
+		return
+	}
+	logging.FromContext(context.Request.Context()).Info("user unblocked", "unblocked_email", user.Email, "reason", reason)
+
+	utils.SendUnblockedMail(context.Request.Context(), user.Email, reason)
+
+	writeUser(context, http.StatusOK, user)
+}
+
+func isAdministrator(context *gin.Context) bool {
+	_, claims := utils.GetTokenClaims(context)
+	if claims.Role != models.Administrator.String() {
+		apperrors.Abort(context, apperrors.Forbidden("administrator role required"))
+		return false
+	}
+	return true
+}
+
+func userID(context *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(context.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		apperrors.Abort(context, apperrors.InvalidRequest("user id must be a positive integer"))
+		return 0, false
+	}
+	return id, true
+}
+
+// readUser answers a GET, with 304 when the client's copy is current.
+func readUser(context *gin.Context, user *models.User) {
+	if etag.NotModified(context, etag.Of(user.ID, user.UpdatedAt)) {
+		return
+	}
+	writeUser(context, http.StatusOK, user)
+}
+
+func writeUser(context *gin.Context, status int, user *models.User) {
+	etag.Set(context, etag.Of(user.ID, user.UpdatedAt))
+	context.JSON(status, user.DTO())
+}
+
+// updateError keeps the typed errors of a change and maps the rest as
+// database errors.
+func updateError(err error) error {
+	var appErr *apperrors.Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return apperrors.FromDB(err, "user")
+}
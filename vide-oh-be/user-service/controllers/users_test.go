@@ -0,0 +1,127 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"user-service/apperrors"
+	"user-service/auth"
+	"user-service/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRegisterUser(t *testing.T) {
+	e := newEnv(t)
+	created := decode[map[string]any](t, e.send(http.MethodPost, "/api/users/register", "", map[string]string{
+		"name": "Ana", "email": "ana@mail.com", "password": strong,
+	}), http.StatusCreated)
+	if created["email"] != "ana@mail.com" {
+		t.Fatalf("created = %v", created)
+	}
+	user := e.stored("ana@mail.com")
+	if user.Role != models.RegisteredUser || !strings.HasPrefix(user.Password, "$argon2id$") {
+		t.Fatalf("user = %+v", user)
+	}
+
+	problem(t, e.send(http.MethodPost, "/api/users/register", "", map[string]string{
+		"name": "Ana", "email": "ana@mail.com", "password": strong,
+	}), http.StatusConflict, apperrors.CodeEmailTaken)
+	problem(t, e.send(http.MethodPost, "/api/users/register", "", map[string]string{
+		"name": "Bob", "email": "bob@mail.com", "password": "password1",
+	}), http.StatusBadRequest, apperrors.CodeWeakPassword)
+}
+
+func TestLogin(t *testing.T) {
+	e := newEnv(t)
+	e.user("ana@mail.com", models.RegisteredUser)
+
+	token := decode[map[string]string](t, e.send(http.MethodPost, "/api/users/login", "", map[string]string{
+		"email": "ana@mail.com", "password": strong,
+	}), http.StatusOK)["token"]
+	err, claims := auth.ValidateToken(token)
+	if err != nil || claims.Email != "ana@mail.com" || claims.Role != "RegisteredUser" {
+		t.Fatalf("claims = %+v, %v", claims, err)
+	}
+
+	// Unknown emails get the same answer as wrong passwords
+	for _, credentials := range []map[string]string{
+		{"email": "ana@mail.com", "password": "wrong password 1"},
+		{"email": "nobody@mail.com", "password": strong},
+	} {
+		problem(t, e.send(http.MethodPost, "/api/users/login", "", credentials), http.StatusUnauthorized, apperrors.CodeInvalidCredentials)
+	}
+}
+
+func TestLoginUpgradesBcryptHashes(t *testing.T) {
+	e := newEnv(t)
+	legacy, err := bcrypt.GenerateFromPassword([]byte(strong), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.db.Create(&models.User{Name: "Ana", Email: "ana@mail.com", Password: string(legacy), Role: models.RegisteredUser}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	decode[map[string]string](t, e.send(http.MethodPost, "/api/users/login", "", map[string]string{
+		"email": "ana@mail.com", "password": strong,
+	}), http.StatusOK)
+	if hash := e.stored("ana@mail.com").Password; !strings.HasPrefix(hash, "$argon2id$") {
+		t.Fatalf("hash after login = %q", hash)
+	}
+}
+
+func TestBlockUser(t *testing.T) {
+	e := newEnv(t)
+	adminToken := e.user("admin@mail.com", models.Administrator)
+	anaToken := e.user("ana@mail.com", models.RegisteredUser)
+
+	problem(t, e.get("/api/users/secured/block/ana@mail.com", anaToken), http.StatusForbidden, apperrors.CodeForbidden)
+	problem(t, e.get("/api/users/secured/block/nobody@mail.com", adminToken), http.StatusNotFound, apperrors.CodeNotFound)
+
+	if rec := e.get("/api/users/secured/block/ana@mail.com?reason=spam", adminToken); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if user := e.stored("ana@mail.com"); !user.Blocked || user.BlockedReason != "spam" {
+		t.Fatalf("user = %+v", user)
+	}
+	// Blocked users are locked out of the secured routes
+	problem(t, e.get("/api/users/secured/user/current", anaToken), http.StatusForbidden, apperrors.CodeUserBlocked)
+
+	// Unblocking is only the v2 DELETE
+	if rec := e.get("/api/users/secured/unblock/ana@mail.com", adminToken); rec.Code != http.StatusNotFound {
+		t.Fatalf("v1 unblock: status %d", rec.Code)
+	}
+	decode[models.UserDTO](t, e.do(http.MethodDelete, userPath(e.stored("ana@mail.com").ID, "block"), adminToken, nil), http.StatusOK)
+	if user := e.stored("ana@mail.com"); user.Blocked || user.BlockedReason != "" {
+		t.Fatalf("user = %+v", user)
+	}
+	decode[models.User](t, e.get("/api/users/secured/user/current", anaToken), http.StatusOK)
+}
+
+func TestSecuredRoutesNeedAToken(t *testing.T) {
+	e := newEnv(t)
+	problem(t, e.get("/api/users/secured/user/current", ""), http.StatusUnauthorized, apperrors.CodeUnauthenticated)
+	problem(t, e.get("/api/users/secured/user/current", "not.a.token"), http.StatusUnauthorized, apperrors.CodeUnauthenticated)
+}
+
+func TestCurrentUserAndChangeName(t *testing.T) {
+	e := newEnv(t)
+	anaToken := e.user("ana@mail.com", models.RegisteredUser)
+	adminToken := e.user("admin@mail.com", models.Administrator)
+
+	problem(t, e.get("/api/users/secured/user/change-name?name=+", anaToken), http.StatusBadRequest, apperrors.CodeInvalidRequest)
+	if rec := e.get("/api/users/secured/user/change-name?name=Ana+Lee", anaToken); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	current := decode[models.User](t, e.get("/api/users/secured/user/current", anaToken), http.StatusOK)
+	if current.Email != "ana@mail.com" || current.Name != "Ana Lee" {
+		t.Fatalf("current = %+v", current)
+	}
+
+	users := decode[[]models.User](t, e.get("/api/users/secured/user/all-registered", adminToken), http.StatusOK)
+	if len(users) != 1 || users[0].Email != "ana@mail.com" {
+		t.Fatalf("registered users = %+v", users)
+	}
+	problem(t, e.get("/api/users/secured/user/all-registered", anaToken), http.StatusForbidden, apperrors.CodeForbidden)
+}
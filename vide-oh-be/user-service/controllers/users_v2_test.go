@@ -0,0 +1,108 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"user-service/apperrors"
+	"user-service/auth"
+	"user-service/models"
+)
+
+func userPath(id uint, rest ...string) string {
+	path := "/api/v2/users/" + strconv.FormatUint(uint64(id), 10)
+	for _, segment := range rest {
+		path += "/" + segment
+	}
+	return path
+}
+
+func TestCreateUser(t *testing.T) {
+	e := newEnv(t)
+	rec := e.send(http.MethodPost, "/api/v2/users", "", map[string]string{
+		"name": "Ana", "email": "ana@mail.com", "password": strong,
+	})
+	created := decode[map[string]any](t, rec, http.StatusCreated)
+	if _, ok := created["password"]; ok {
+		t.Fatal("the response carries the password hash")
+	}
+	user := e.stored("ana@mail.com")
+	if want := userPath(user.ID); rec.Header().Get("Location") != want {
+		t.Errorf("Location = %q, want %q", rec.Header().Get("Location"), want)
+	}
+	if rec.Header().Get("ETag") == "" {
+		t.Error("no ETag")
+	}
+
+	problem(t, e.send(http.MethodPost, "/api/v2/users", "", map[string]string{
+		"name": "Ana", "email": "ana@mail.com", "password": strong,
+	}), http.StatusConflict, apperrors.CodeEmailTaken)
+}
+
+func TestCreateSession(t *testing.T) {
+	e := newEnv(t)
+	e.user("ana@mail.com", models.RegisteredUser)
+	token := decode[map[string]string](t, e.send(http.MethodPost, "/api/v2/users/sessions", "", map[string]string{
+		"email": "ana@mail.com", "password": strong,
+	}), http.StatusOK)["token"]
+	if err, claims := auth.ValidateToken(token); err != nil || claims.Email != "ana@mail.com" {
+		t.Fatalf("claims = %+v, %v", claims, err)
+	}
+}
+
+func TestUpdateMe(t *testing.T) {
+	e := newEnv(t)
+	token := e.user("ana@mail.com", models.RegisteredUser)
+
+	rec := e.get("/api/v2/users/me", token)
+	me := decode[models.UserDTO](t, rec, http.StatusOK)
+	tag := rec.Header().Get("ETag")
+	if me.Email != "ana@mail.com" || tag == "" {
+		t.Fatalf("me = %+v, ETag %q", me, tag)
+	}
+	if rec := e.get("/api/v2/users/me", token, "If-None-Match", tag); rec.Code != http.StatusNotModified {
+		t.Fatalf("If-None-Match: status %d", rec.Code)
+	}
+
+	rename := map[string]string{"name": "Ana Maria"}
+	problem(t, e.send(http.MethodPatch, "/api/v2/users/me", token, rename), http.StatusPreconditionRequired, apperrors.CodePreconditionRequired)
+	problem(t, e.send(http.MethodPatch, "/api/v2/users/me", token, rename, "If-Match", `"stale"`), http.StatusPreconditionFailed, apperrors.CodePreconditionFailed)
+	problem(t, e.send(http.MethodPatch, "/api/v2/users/me", token, map[string]string{"name": " "}, "If-Match", tag), http.StatusBadRequest, apperrors.CodeInvalidRequest)
+
+	rec = e.send(http.MethodPatch, "/api/v2/users/me", token, rename, "If-Match", tag)
+	if updated := decode[models.UserDTO](t, rec, http.StatusOK); updated.Name != "Ana Maria" {
+		t.Fatalf("updated = %+v", updated)
+	}
+	if e.stored("ana@mail.com").Name != "Ana Maria" {
+		t.Fatal("the name was not stored")
+	}
+	// The old tag is stale now
+	problem(t, e.send(http.MethodPatch, "/api/v2/users/me", token, rename, "If-Match", tag), http.StatusPreconditionFailed, apperrors.CodePreconditionFailed)
+}
+
+func TestSetAndClearBlock(t *testing.T) {
+	e := newEnv(t)
+	admin := e.user("admin@mail.com", models.Administrator)
+	token := e.user("ana@mail.com", models.RegisteredUser)
+	path := userPath(e.stored("ana@mail.com").ID, "block")
+
+	problem(t, e.send(http.MethodPut, path, token, map[string]string{"reason": "spam"}), http.StatusForbidden, apperrors.CodeForbidden)
+	problem(t, e.send(http.MethodPut, path, admin, map[string]string{"reason": " "}), http.StatusBadRequest, apperrors.CodeInvalidRequest)
+	problem(t, e.send(http.MethodPut, userPath(999, "block"), admin, map[string]string{"reason": "spam"}), http.StatusNotFound, apperrors.CodeNotFound)
+
+	blocked := decode[models.UserDTO](t, e.send(http.MethodPut, path, admin, map[string]string{"reason": "spam"}), http.StatusOK)
+	if !blocked.Blocked || blocked.BlockedReason != "spam" {
+		t.Fatalf("blocked = %+v", blocked)
+	}
+	problem(t, e.get("/api/v2/users/me", token), http.StatusForbidden, apperrors.CodeUserBlocked)
+
+	users := decode[[]models.UserDTO](t, e.get("/api/v2/users", admin), http.StatusOK)
+	if len(users) != 1 || !users[0].Blocked {
+		t.Fatalf("users = %+v", users)
+	}
+
+	unblocked := decode[models.UserDTO](t, e.do(http.MethodDelete, path, admin, nil), http.StatusOK)
+	if unblocked.Blocked || unblocked.BlockedReason != "" {
+		t.Fatalf("unblocked = %+v", unblocked)
+	}
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"user-service/logging"
+)
+
+// Postgres SQLSTATE codes for rejected credentials
+const (
+	invalidPassword              = "28P01"
+	invalidAuthorizationSpecific = "28000"
+)
+
+// connector opens every new pooled connection with the current credentials.
+// Connections that are already open keep working after a rotation; new
+// ones that fail authentication trigger one refetch and retry.
+type connector struct {
+	credentials CredentialSource
+	driver      driver.Driver
+}
+
+func newConnector(credentials CredentialSource) (*connector, error) {
+	// The pgx stdlib driver is registered by the gorm postgres driver
+	db, err := sql.Open("pgx", "")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return &connector{credentials: credentials, driver: db.Driver()}, nil
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.open(ctx)
+	if err == nil || !isAuthError(err) {
+		return conn, err
+	}
+
+	logging.FromContext(ctx).Warn("database rejected credentials, refreshing them", "error", err)
+	c.credentials.Invalidate()
+	return c.open(ctx)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+func (c *connector) open(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.credentials.DSN(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if driverCtx, ok := c.driver.(driver.DriverContext); ok {
+		dsnConnector, err := driverCtx.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return dsnConnector.Connect(ctx)
+	}
+	return c.driver.Open(dsn)
+}
+
+func isAuthError(err error) bool {
+	// Matches *pgconn.PgError from both pgx v4 and v5
+	var pgErr interface{ SQLState() string }
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	code := pgErr.SQLState()
+	return code == invalidPassword || code == invalidAuthorizationSpecific
+}
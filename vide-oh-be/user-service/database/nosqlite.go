@@ -0,0 +1,9 @@
+//go:build !sqlite
+
+package database
+
+import "log"
+
+func ConnectSQLite(path string) {
+	log.Fatal("built without SQLite support; rebuild with -tags sqlite")
+}
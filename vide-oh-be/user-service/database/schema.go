@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+const serviceName = "user-service"
+
+// SchemaVersion is the schema level this build migrates to. Bump it with
+// every model change that needs a migration.
+const SchemaVersion = 2
+
+// SchemaMigration records the schema level each service has applied to the
+// shared database.
+type SchemaMigration struct {
+	Service   string `gorm:"primaryKey"`
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	AppliedAt time.Time
+}
+
+func recordSchemaVersion() {
+	Instance.AutoMigrate(&SchemaMigration{})
+	Instance.Save(&SchemaMigration{Service: serviceName, Version: SchemaVersion, AppliedAt: time.Now()})
+}
+
+// AppliedSchemaVersion returns the highest schema level recorded for this
+// service, or 0 if the migrations never ran.
+func AppliedSchemaVersion(ctx context.Context) (int, error) {
+	// HasTable reports false rather than an error when Postgres is down
+	if err := Ping(ctx); err != nil {
+		return 0, err
+	}
+	db := Instance.WithContext(ctx)
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return 0, nil
+	}
+	var version int
+	err := db.Model(&SchemaMigration{}).
+		Where("service = ?", serviceName).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&version).Error
+	return version, err
+}
+
+// Ping checks that a connection to Postgres can be made.
+func Ping(ctx context.Context) error {
+	sqlDB, err := Instance.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,44 @@
+package etag
+
+import (
+	"testing"
+	"time"
+	"user-service/apperrors"
+)
+
+func TestOfIgnoresNanoseconds(t *testing.T) {
+	saved := time.Date(2026, 10, 19, 12, 0, 0, 123456789, time.UTC)
+	if Of(1, saved) != Of(1, saved.Truncate(time.Microsecond)) {
+		t.Fatal("the tag of a row read back from Postgres differs from the one just written")
+	}
+	if Of(1, saved) == Of(1, saved.Add(time.Microsecond)) || Of(1, saved) == Of(2, saved) {
+		t.Fatal("distinct versions share a tag")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tag := Of(1, time.Now())
+	tests := []struct {
+		ifMatch  string
+		required bool
+		want     apperrors.Code
+	}{
+		{"", false, ""},
+		{"", true, apperrors.CodePreconditionRequired},
+		{tag, true, ""},
+		{"W/" + tag, true, ""},
+		{`"other", ` + tag, true, ""},
+		{"*", true, ""},
+		{`"other"`, false, apperrors.CodePreconditionFailed},
+	}
+	for _, tt := range tests {
+		err := Check(tt.ifMatch, tag, tt.required)
+		var got apperrors.Code
+		if err != nil {
+			got = err.(*apperrors.Error).Code
+		}
+		if got != tt.want {
+			t.Errorf("Check(%q, required %v) = %v, want %q", tt.ifMatch, tt.required, err, tt.want)
+		}
+	}
+}
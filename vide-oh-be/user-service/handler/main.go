@@ -2,16 +2,24 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
-	"net/url"
+	"log/slog"
 	"os"
 
 	"user-service/auth"
-	"user-service/controllers"
+	"user-service/config"
 	"user-service/database"
-	"user-service/middleware"
+	"user-service/health"
+	"user-service/logging"
+	"user-service/metrics"
 	"user-service/models"
+	"user-service/openapi"
+	"user-service/password"
+	"user-service/repository"
+	"user-service/router"
+	"user-service/tracing"
 	"user-service/utils"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -22,48 +30,109 @@ import (
 
 var ginLambda *ginadapter.GinLambda
 
-func init() {
-	// Initialize Router
-	router := initRouter()
+func main() {
+	local := flag.Bool("local", false, "serve the API over plain HTTP instead of running as a Lambda")
+	addr := flag.String("addr", ":8081", "listen address in -local mode")
+	dashboard := flag.Bool("dashboard", false, "print the CloudWatch dashboard for the service metrics and exit")
+	checkOpenAPI := flag.Bool("check-openapi", false, "compare the registered routes with openapi/openapi.json and exit")
+	flag.Parse()
+
+	if *dashboard {
+		printDashboard()
+		return
+	}
+	if *checkOpenAPI {
+		checkRoutes()
+		return
+	}
+
+	// Load and validate configuration (env, CONFIG_FILE, Secrets Manager)
+	cfg, err := config.Load(*local)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logging.New("user-service", cfg.LogLevel))
+	openapi.SetMode(cfg.OpenAPIValidation)
+	metrics.Init("user-service", *local)
+	if err := tracing.Init(context.Background(), "user-service", cfg.Tracing.Endpoint, cfg.Tracing.SamplePercent); err != nil {
+		log.Fatal(err)
+	}
+	auth.SetJwtKey(cfg.JWTKey)
+	utils.SetMailServer(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.From)
+	password.SetPolicy(cfg.Password.Policy())
+
+	// Initialize Database
+	database.Open(cfg)
+	if err := health.Init(cfg); err != nil {
+		log.Fatal(err)
+	}
+	repos := repository.New(database.Instance)
+
+	if *local {
+		runLocal(repos, *addr)
+		return
+	}
+
+	// database.Migrate()
+
+	seedUsers(repos.Users)
 
 	// Create the Lambda handler
-	ginLambda = ginadapter.New(router)
+	ginLambda = ginadapter.New(router.New(repos))
+
+	// Start the Lambda handler
+	lambda.Start(Handler)
 }
 
-func main() {
-	// Load env vars
-	dbSecretName := os.Getenv("DB_SECRET_NAME")
-	if dbSecretName == "" {
-		log.Fatal("DB_SECRET_NAME environment variable is not set")
+// runLocal serves the router over plain HTTP.
+func runLocal(repos repository.Repositories, addr string) {
+	database.AutoMigrate()
+
+	seedUsers(repos.Users)
+
+	defer tracing.Shutdown(context.Background())
+
+	engine := router.New(repos)
+	engine.GET("/metrics", metrics.Handler())
+	for _, problem := range openapi.CheckRoutes(engine.Routes(), "/api/users", "/api/v2/users") {
+		slog.Warn("route does not match the OpenAPI document", "problem", problem)
 	}
-	region := os.Getenv("REGION")
-	if region == "" {
-		log.Fatal("REGION environment variable is not set")
+
+	slog.Info("user service listening", "addr", addr)
+	if err := engine.Run(addr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// checkRoutes exits non-zero when the router and openapi/openapi.json
+// disagree.
+func checkRoutes() {
+	gin.SetMode(gin.ReleaseMode)
+	problems := openapi.CheckRoutes(router.New(repository.Repositories{}).Routes(), "/api/users", "/api/v2/users")
+	for _, problem := range problems {
+		fmt.Println(problem)
 	}
-	keySecretName := os.Getenv("KEY_SECRET_NAME")
-	if keySecretName == "" {
-		log.Fatal("KEY_SECRET_NAME environment variable is not set")
+	if len(problems) > 0 {
+		os.Exit(1)
 	}
+}
 
-	// Fetch secrets from SM
-	dbSecret, keySecret, err := utils.GetSecrets(dbSecretName, keySecretName, region)
+func printDashboard() {
+	region := os.Getenv("REGION")
+	if region == "" {
+		region = "eu-central-1"
+	}
+	body, err := metrics.Dashboard("user-service", region)
 	if err != nil {
-		log.Fatalf("Failed to retrieve secret: %v", err)
+		log.Fatal(err)
 	}
-	connectionString := fmt.Sprintf("postgresql://%s:%s@%s:%d/%s",
-		dbSecret.Username,
-		url.QueryEscape(dbSecret.Password),
-		dbSecret.Host,
-		dbSecret.Port,
-		dbSecret.DBName,
-	)
-	auth.SetJwtKey(keySecret.SecretKey)
+	fmt.Println(string(body))
+}
 
-	// Initialize Database
-	database.Connect(connectionString)
-	// database.Migrate()
+// Initial Data; existing users are left alone
+func seedUsers(users repository.UserRepository) {
+	ctx := context.Background()
 
-	// Initial Data
 	user := &models.User{
 		Name:     "Admin Adminsky",
 		Email:    "admin@admin.com",
@@ -72,7 +141,7 @@ func main() {
 		Blocked:  false,
 	}
 	user.HashPassword(user.Password)
-	database.Instance.Save(&user)
+	users.Save(ctx, user)
 
 	user2 := &models.User{
 		Name:     "User Usersky",
@@ -82,7 +151,7 @@ func main() {
 		Blocked:  false,
 	}
 	user2.HashPassword(user2.Password)
-	database.Instance.Save(&user2)
+	users.Save(ctx, user2)
 
 	user3 := &models.User{
 		Name:     "User2 Usersky2",
@@ -92,7 +161,7 @@ func main() {
 		Blocked:  false,
 	}
 	user3.HashPassword(user3.Password)
-	database.Instance.Save(&user3)
+	users.Save(ctx, user3)
 
 	supportUser := &models.User{
 		Name:     "Tech Support",
@@ -102,49 +171,11 @@ func main() {
 		Blocked:  false,
 	}
 	supportUser.HashPassword(supportUser.Password)
-	database.Instance.Save(&supportUser)
-
-	// Start the Lambda handler
-	lambda.Start(Handler)
-
-	fmt.Println("User service lambda started!")
+	users.Save(ctx, supportUser)
 }
 
 func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	defer tracing.Flush(ctx)
+	defer metrics.Flush()
 	return ginLambda.ProxyWithContext(ctx, req)
 }
-
-func CORS() gin.HandlerFunc {
-	// TO allow CORS
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	}
-}
-
-func initRouter() *gin.Engine {
-	router := gin.Default()
-	router.Use(CORS())
-	api := router.Group("/api/users")
-	{
-		api.POST("/login", controllers.Login)
-		api.POST("/register", controllers.RegisterUser)
-		api.GET("/ping", controllers.Ping)
-		secured := api.Group("/secured").Use(middleware.Auth())
-		{
-			secured.GET("/ping", controllers.Ping)
-			secured.GET("/user/all-registered", controllers.GetAllRegisteredUsers) // only admin
-			secured.GET("/block/:email", controllers.BlockUser)                    // only admin
-			secured.GET("/user/:id", controllers.GetUserById)
-			secured.GET("/user/current", controllers.GetCurrentUser)
-			secured.GET("/user/change-name", controllers.ChangeName)
-		}
-	}
-	return router
-}
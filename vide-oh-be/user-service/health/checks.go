@@ -0,0 +1,61 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user-service/config"
+	"user-service/database"
+	"user-service/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+const (
+	postgresTimeout       = 2 * time.Second
+	secretsManagerTimeout = 3 * time.Second
+)
+
+// Init registers the readiness checks for the dependencies the
+// configuration uses. Secrets Manager is not used in local mode.
+func Init(cfg *config.Config) error {
+	Register(Check{Name: "postgres", Timeout: postgresTimeout, Run: database.Ping})
+	if cfg.Local {
+		return nil
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.TODO(), awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	tracing.AppendAWSMiddlewares(&awsCfg.APIOptions)
+
+	secretNames := []string{cfg.KeySecretName}
+	if cfg.DB.AuthMode == config.DBAuthSecret {
+		secretNames = append(secretNames, cfg.DBSecretName)
+	}
+	Register(Check{
+		Name:    "secretsmanager",
+		Timeout: secretsManagerTimeout,
+		Run:     secretsCheck(secretsmanager.NewFromConfig(awsCfg), secretNames),
+	})
+	return nil
+}
+
+// secretsCheck reads each secret the service depends on, which proves both
+// the IAM permission and the KMS key are usable.
+func secretsCheck(client *secretsmanager.Client, names []string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		for _, name := range names {
+			_, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+				SecretId: aws.String(name),
+			})
+			if err != nil {
+				return fmt.Errorf("secret %s: %v", name, err)
+			}
+		}
+		return nil
+	}
+}
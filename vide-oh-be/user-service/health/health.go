@@ -0,0 +1,119 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+	"user-service/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Check is one readiness probe of a dependency. Run gets a context that
+// expires after Timeout; a check that ignores it is still reported as
+// failed once the timeout passes.
+type Check struct {
+	Name    string
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+var checks []Check
+
+func Register(check ...Check) {
+	checks = append(checks, check...)
+}
+
+type checkResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+type migrationLevel struct {
+	Expected int    `json:"expected"`
+	Applied  *int   `json:"applied,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Live answers as long as the process serves requests. It checks no
+// dependencies, so an outage elsewhere does not get healthy instances
+// recycled.
+func Live(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{"status": "ok", "build": Build()})
+}
+
+// Ready runs every registered check in parallel and answers 503 if any of
+// them fails. The migration level is reported but does not affect the
+// status, since Lambda deployments do not migrate on start.
+func Ready(context *gin.Context) {
+	ctx := context.Request.Context()
+
+	results := make(map[string]checkResult, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := run(ctx, check)
+			mu.Lock()
+			results[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	status, overall := http.StatusOK, "ok"
+	for _, result := range results {
+		if result.Status != "ok" {
+			status, overall = http.StatusServiceUnavailable, "unavailable"
+		}
+	}
+
+	context.JSON(status, gin.H{
+		"status":    overall,
+		"build":     Build(),
+		"migration": migration(ctx),
+		"checks":    results,
+	})
+}
+
+func run(parent context.Context, check Check) checkResult {
+	ctx, cancel := context.WithTimeout(parent, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := checkResult{Status: "ok", DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func migration(ctx context.Context) migrationLevel {
+	level := migrationLevel{Expected: database.SchemaVersion}
+	ctx, cancel := context.WithTimeout(ctx, postgresTimeout)
+	defer cancel()
+	applied, err := database.AppliedSchemaVersion(ctx)
+	if err != nil {
+		level.Error = err.Error()
+	} else {
+		level.Applied = &applied
+	}
+	return level
+}
@@ -0,0 +1,21 @@
+package logging
+
+import "net/http"
+
+// Transport forwards the correlation ID of the request context to outgoing
+// service-to-service calls.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if id := CorrelationID(req.Context()); id != "" && req.Header.Get(CorrelationIDHeader) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(CorrelationIDHeader, id)
+	}
+	return base.RoundTrip(req)
+}
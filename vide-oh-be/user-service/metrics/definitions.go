@@ -0,0 +1,9 @@
+package metrics
+
+// Logins counts login attempts by outcome: success, invalid_request,
+// unknown_user, invalid_credentials or error.
+var Logins = NewCounter("logins_total", "Login attempts by outcome", "outcome")
+
+// PasswordRehashes counts hashes upgraded on login by outcome: upgraded or
+// error, so a stalled migration to Argon2id shows up on the dashboard.
+var PasswordRehashes = NewCounter("password_rehashes_total", "Password hash upgrades on login by outcome", "outcome")
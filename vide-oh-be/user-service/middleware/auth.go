@@ -1,40 +1,42 @@
 package middleware
 
 import (
+	"context"
 	"errors"
+	"user-service/apperrors"
 	"user-service/auth"
-	"user-service/database"
-	"user-service/models"
+	"user-service/repository"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func Auth() gin.HandlerFunc {
+// Auth rejects requests without a valid token or whose user is blocked.
+func Auth(users repository.UserRepository) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		tokenString := context.GetHeader("Authorization")
 		if tokenString == "" {
-			context.JSON(401, gin.H{"error": "request does not contain an access token"})
-			context.Abort()
+			apperrors.Abort(context, apperrors.Unauthenticated("request does not contain an access token"))
 			return
 		}
 		err, claims := auth.ValidateToken(tokenString)
 		if err != nil {
-			context.JSON(401, gin.H{"error": err.Error()})
-			context.Abort()
+			apperrors.Abort(context, apperrors.Unauthenticated("access token is invalid or expired").Wrap(err))
 			return
 		}
 
 		// auth invalid if user blocked
-		var user models.User
-
-		if err := database.Instance.Where("email = ?", claims.Email).First(&user).Error; err != nil {
-			context.JSON(401, gin.H{"error": err.Error()})
-			context.Abort()
+		user, err := users.ByEmail(context.Request.Context(), claims.Email)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apperrors.Abort(context, apperrors.Unauthenticated("token user no longer exists").Wrap(err))
+			} else {
+				apperrors.Abort(context, apperrors.FromDB(err, "user"))
+			}
 			return
 		}
 		if user.Blocked {
-			context.JSON(401, gin.H{"error": "user blocked"})
-			context.Abort()
+			apperrors.Abort(context, apperrors.ErrUserBlocked)
 			return
 		}
 
@@ -42,19 +44,19 @@ func Auth() gin.HandlerFunc {
 	}
 }
 
-func ValidateTokenForLambdaAuthorizer(token string) (err error, jwtClaims auth.JWTClaim) {
+func ValidateTokenForLambdaAuthorizer(ctx context.Context, users repository.UserRepository, token string) (err error, jwtClaims auth.JWTClaim) {
 	err, claims := auth.ValidateToken(token)
 	if err != nil {
 		return
 	}
 
 	// auth invalid if user blocked
-	var user models.User
-	if err = database.Instance.Where("email = ?", claims.Email).First(&user).Error; err != nil {
+	user, err := users.ByEmail(ctx, claims.Email)
+	if err != nil {
 		return
 	}
 	if user.Blocked {
-		err = errors.New("user account is blocked")
+		err = apperrors.ErrUserBlocked
 		return
 	}
 
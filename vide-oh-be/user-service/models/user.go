@@ -2,8 +2,9 @@ package models
 
 import (
 	"fmt"
+	"time"
+	"user-service/password"
 
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -35,21 +36,51 @@ type User struct {
 	Password string   `json:"password" gorm:"not null"`
 	Role     UserRole `json:"userRole" gorm:"not null"`
 	Blocked  bool     `json:"blocked" gorm:"default:false"`
+	// Shown to the user in the block mail; cleared on unblock
+	BlockedReason string `json:"blockedReason"`
 }
 
-func (user *User) HashPassword(password string) error {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	if err != nil {
-		return err
+// UserDTO is the v2 representation of a user. Unlike User it leaves out
+// the password hash.
+type UserDTO struct {
+	ID            uint      `json:"ID"`
+	CreatedAt     time.Time `json:"CreatedAt"`
+	UpdatedAt     time.Time `json:"UpdatedAt"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	Role          UserRole  `json:"userRole"`
+	Blocked       bool      `json:"blocked"`
+	BlockedReason string    `json:"blockedReason"`
+}
+
+func (user *User) DTO() UserDTO {
+	return UserDTO{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Name:          user.Name,
+		Email:         user.Email,
+		Role:          user.Role,
+		Blocked:       user.Blocked,
+		BlockedReason: user.BlockedReason,
 	}
-	user.Password = string(bytes)
-	return nil
 }
 
-func (user *User) CheckPassword(providedPassword string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(providedPassword))
+func (user *User) HashPassword(providedPassword string) error {
+	hash, err := password.Hash(providedPassword)
 	if err != nil {
 		return err
 	}
+	user.Password = hash
 	return nil
 }
+
+func (user *User) CheckPassword(providedPassword string) error {
+	return password.Verify(user.Password, providedPassword)
+}
+
+// PasswordNeedsRehash reports whether the stored hash should be upgraded
+// to the current format after a successful login.
+func (user *User) PasswordNeedsRehash() bool {
+	return password.NeedsRehash(user.Password)
+}
@@ -0,0 +1,62 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+)
+
+var ErrBreached = errors.New("password has appeared in a data breach")
+
+// breached.txt uses the Pwned Passwords range format: the first five hex
+// characters of the SHA-1 hash are the range key, the rest is the suffix.
+// Lookups only ever touch the range for one prefix, so the list can be
+// swapped for a larger k-anonymity dump without changing this code.
+//
+//go:embed breached.txt
+var breachedList string
+
+var (
+	breachedOnce   sync.Once
+	breachedRanges map[string]map[string]struct{}
+)
+
+func loadBreached() {
+	breachedRanges = make(map[string]map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(breachedList))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix, suffix, found := strings.Cut(line, ":")
+		if !found || len(prefix) != 5 {
+			continue
+		}
+		// Range dumps may carry a ":count" column
+		suffix, _, _ = strings.Cut(suffix, ":")
+		if breachedRanges[prefix] == nil {
+			breachedRanges[prefix] = make(map[string]struct{})
+		}
+		breachedRanges[prefix][strings.ToUpper(suffix)] = struct{}{}
+	}
+}
+
+// IsBreached reports whether the password is in the bundled breach list.
+func IsBreached(password string) bool {
+	breachedOnce.Do(loadBreached)
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, ok := breachedRanges[hash[:5]]
+	if !ok {
+		return false
+	}
+	_, found := suffixes[hash[5:]]
+	return found
+}
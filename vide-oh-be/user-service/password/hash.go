@@ -0,0 +1,170 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrMismatch      = errors.New("password does not match")
+	ErrUnknownFormat = errors.New("unknown password hash format")
+)
+
+// Params are the Argon2id cost parameters encoded into every hash.
+type Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams follow the OWASP recommendation for Argon2id and fit
+// comfortably inside a 512 MB Lambda.
+var DefaultParams = Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Hash returns a PHC-formatted Argon2id hash:
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+func Hash(password string) (string, error) {
+	return hashWithParams(password, DefaultParams)
+}
+
+func hashWithParams(password string, p Params) (string, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		p.Memory,
+		p.Iterations,
+		p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify checks a password against an Argon2id or legacy bcrypt hash.
+func Verify(encoded, password string) error {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		p, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return err
+		}
+		other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return ErrMismatch
+		}
+		return nil
+	case isBcrypt(encoded):
+		if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+			return ErrMismatch
+		}
+		return nil
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+var (
+	dummyOnce sync.Once
+	dummyHash string
+)
+
+// VerifyDummy does the work of Verify against a hash of DefaultParams that
+// no password matches. Logins of unknown users call it so they take as
+// long as wrong passwords, and response times do not reveal which emails
+// are registered.
+func VerifyDummy(password string) {
+	dummyOnce.Do(func() {
+		var err error
+		if dummyHash, err = Hash("dummy password for unknown users"); err != nil {
+			panic(err)
+		}
+	})
+	_ = Verify(dummyHash, password)
+}
+
+// NeedsRehash reports whether a hash was produced by an older algorithm or
+// with parameters that differ from DefaultParams.
+func NeedsRehash(encoded string) bool {
+	if !strings.HasPrefix(encoded, "$argon2id$") {
+		return true
+	}
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return p.Memory != DefaultParams.Memory ||
+		p.Iterations != DefaultParams.Iterations ||
+		p.Parallelism != DefaultParams.Parallelism ||
+		uint32(len(salt)) != DefaultParams.SaltLength ||
+		uint32(len(key)) != DefaultParams.KeyLength
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+func decodeArgon2id(encoded string) (p Params, salt, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		err = ErrUnknownFormat
+		return
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return
+	}
+	if version != argon2.Version {
+		err = fmt.Errorf("unsupported argon2 version %d", version)
+		return
+	}
+
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return
+	}
+	// argon2.IDKey panics on zero iterations or parallelism
+	if p.Iterations == 0 || p.Parallelism == 0 {
+		err = fmt.Errorf("invalid argon2 parameters %q", parts[3])
+		return
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return
+	}
+	// An empty key would compare equal to the empty key derived from any
+	// password
+	if len(key) == 0 {
+		err = errors.New("empty argon2 key")
+		return
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return
+}
@@ -0,0 +1,153 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	hash, err := Hash("correct horse 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Fatalf("hash = %q", hash)
+	}
+	if err := Verify(hash, "correct horse 1"); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := Verify(hash, "correct horse 2"); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("Verify with a wrong password = %v", err)
+	}
+	if NeedsRehash(hash) {
+		t.Fatal("a hash with the default parameters needs a rehash")
+	}
+
+	// Salted: the same password hashes differently every time
+	again, err := Hash("correct horse 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == hash {
+		t.Fatal("two hashes of one password are equal")
+	}
+}
+
+func TestBcryptHashesNeedRehash(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse 1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(string(legacy), "correct horse 1"); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := Verify(string(legacy), "correct horse 2"); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("Verify with a wrong password = %v", err)
+	}
+	if !NeedsRehash(string(legacy)) {
+		t.Fatal("a bcrypt hash does not need a rehash")
+	}
+
+	cheaper := DefaultParams
+	cheaper.Iterations = 1
+	outdated, err := hashWithParams("correct horse 1", cheaper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(outdated, "correct horse 1"); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !NeedsRehash(outdated) {
+		t.Fatal("a hash with other parameters does not need a rehash")
+	}
+}
+
+func TestVerifyRejectsMalformedHashes(t *testing.T) {
+	tests := []string{
+		"",
+		"plain text",
+		"$argon2id$v=19$m=19456,t=2,p=1$c2FsdA",
+		"$argon2id$v=18$m=19456,t=2,p=1$c2FsdHNhbHRzYWx0$a2V5",
+		"$argon2id$v=19$m=19456,t=0,p=1$c2FsdHNhbHRzYWx0$a2V5",
+		"$argon2id$v=19$m=19456,t=2,p=0$c2FsdHNhbHRzYWx0$a2V5",
+		"$argon2id$v=19$m=19456,t=2,p=1$c2FsdHNhbHRzYWx0$",
+		"$argon2id$v=19$m=19456,t=2,p=1$not base64!$a2V5",
+	}
+	for _, encoded := range tests {
+		t.Run(encoded, func(t *testing.T) {
+			if err := Verify(encoded, "anything"); err == nil {
+				t.Fatal("Verify accepted the hash")
+			}
+			if !NeedsRehash(encoded) {
+				t.Fatal("NeedsRehash = false")
+			}
+		})
+	}
+}
+
+func TestVerifyDummy(t *testing.T) {
+	// It only has to take the time of a real check
+	VerifyDummy("correct horse 1")
+	VerifyDummy("")
+}
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"tr0ub4dor", ""},
+		{"short1", "at least 8 characters"},
+		{"nodigitshere", "a digit"},
+		{"12345678901", "a lowercase letter"},
+		{strings.Repeat("a1", 65), "at most 128 characters"},
+		// Characters count, not bytes
+		{"ééééééé1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			err := DefaultPolicy.Validate(tt.password)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	strict := DefaultPolicy
+	strict.RequireUpper, strict.RequireSymbol = true, true
+	if err := strict.Validate("tr0ub4dor"); err == nil || !strings.Contains(err.Error(), "an uppercase letter, a symbol") {
+		t.Fatalf("strict Validate = %v", err)
+	}
+	if err := strict.Validate("Tr0ub4dor!"); err != nil {
+		t.Fatalf("strict Validate = %v", err)
+	}
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	for _, breached := range []string{"password1", "qwerty123", "letmein1"} {
+		if !IsBreached(breached) {
+			t.Errorf("IsBreached(%q) = false", breached)
+		}
+		if err := DefaultPolicy.Validate(breached); !errors.Is(err, ErrBreached) {
+			t.Errorf("Validate(%q) = %v, want ErrBreached", breached, err)
+		}
+	}
+	if IsBreached("tr0ub4dor") {
+		t.Error("IsBreached(tr0ub4dor) = true")
+	}
+
+	lenient := DefaultPolicy
+	lenient.RejectBreached = false
+	if err := lenient.Validate("password1"); err != nil {
+		t.Errorf("Validate without the breach check = %v", err)
+	}
+}
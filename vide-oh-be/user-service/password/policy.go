@@ -0,0 +1,90 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Policy describes the rules a new password has to satisfy.
+type Policy struct {
+	MinLength      int
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSymbol  bool
+	RejectBreached bool
+}
+
+var DefaultPolicy = Policy{
+	MinLength:      8,
+	MaxLength:      128,
+	RequireUpper:   false,
+	RequireLower:   true,
+	RequireDigit:   true,
+	RequireSymbol:  false,
+	RejectBreached: true,
+}
+
+var current = DefaultPolicy
+
+// SetPolicy replaces the policy returned by CurrentPolicy.
+func SetPolicy(policy Policy) {
+	current = policy
+}
+
+func CurrentPolicy() Policy {
+	return current
+}
+
+// Validate returns an error describing every rule the password breaks.
+func (policy Policy) Validate(password string) error {
+	var problems []string
+
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		problems = append(problems, fmt.Sprintf("at least %d characters", policy.MinLength))
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		problems = append(problems, fmt.Sprintf("at most %d characters", policy.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUpper && !hasUpper {
+		problems = append(problems, "an uppercase letter")
+	}
+	if policy.RequireLower && !hasLower {
+		problems = append(problems, "a lowercase letter")
+	}
+	if policy.RequireDigit && !hasDigit {
+		problems = append(problems, "a digit")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		problems = append(problems, "a symbol")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("password must contain " + strings.Join(problems, ", "))
+	}
+
+	if policy.RejectBreached && IsBreached(password) {
+		return ErrBreached
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+// Package repository is the data access layer. Controllers depend on the
+// interfaces; the gorm implementation runs on Postgres in production and on
+// SQLite for local development.
+package repository
+
+import (
+	"context"
+	"user-service/models"
+)
+
+// Lookups return gorm.ErrRecordNotFound for missing rows and writes
+// gorm.ErrDuplicatedKey for unique violations, so apperrors.FromDB can map
+// them on either database.
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	Save(ctx context.Context, user *models.User) error
+	UpdatePassword(ctx context.Context, user *models.User) error
+	ByID(ctx context.Context, id uint64) (*models.User, error)
+	ByEmail(ctx context.Context, email string) (*models.User, error)
+	ListByRole(ctx context.Context, role models.UserRole) ([]models.User, error)
+	// ForUpdate returns the repository with lookups that lock the rows
+	// they read until the transaction ends, so a check on a row still
+	// holds when it is saved. Only useful inside UnitOfWork.Do.
+	ForUpdate() UserRepository
+}
+
+// Repositories is what controllers are constructed with. Inside
+// UnitOfWork.Do the same set is bound to the transaction.
+type Repositories struct {
+	Users      UserRepository
+	UnitOfWork UnitOfWork
+}
+
+// UnitOfWork runs fn in one transaction, committed when fn returns nil and
+// rolled back otherwise. Nested calls use savepoints.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(tx Repositories) error) error
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"user-service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type users struct {
+	db *gorm.DB
+}
+
+func (r users) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r users) Save(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Save(user).Error
+}
+
+func (r users) UpdatePassword(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Model(user).Update("password", user.Password).Error
+}
+
+func (r users) ByID(ctx context.Context, id uint64) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r users) ByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r users) ListByRole(ctx context.Context, role models.UserRole) ([]models.User, error) {
+	var list []models.User
+	err := r.db.WithContext(ctx).Where("role = ?", role).Find(&list).Error
+	return list, err
+}
+
+// ForUpdate adds SELECT … FOR UPDATE to the lookups. SQLite has no row
+// locks and drops the clause; it serializes the writing transactions.
+func (r users) ForUpdate() UserRepository {
+	return users{db: r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Session(&gorm.Session{})}
+}
@@ -0,0 +1,58 @@
+package repository_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"user-service/repository"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// statements records the SQL gorm runs.
+type statements struct {
+	logger.Interface
+	sql []string
+}
+
+func (s *statements) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	s.sql = append(s.sql, sql)
+}
+
+func TestForUpdateLocksTheRows(t *testing.T) {
+	// SQLite drops the locking clause, so build the Postgres statements
+	// without running them
+	recorded := &statements{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun: true, DisableAutomaticPing: true, Logger: recorded,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := repository.New(db).Users
+
+	ctx := context.Background()
+	users.ByID(ctx, 1)
+	locked := users.ForUpdate()
+	locked.ByEmail(ctx, "ana@mail.com")
+	locked.ByID(ctx, 1)
+	if len(recorded.sql) != 3 {
+		t.Fatalf("statements = %q", recorded.sql)
+	}
+	if strings.Contains(recorded.sql[0], "FOR UPDATE") {
+		t.Errorf("plain lookup locks: %s", recorded.sql[0])
+	}
+	for _, sql := range recorded.sql[1:] {
+		if !strings.HasSuffix(sql, "FOR UPDATE") {
+			t.Errorf("locked lookup: %s", sql)
+		}
+	}
+	// The locked repository is reusable: no conditions carry over
+	if strings.Contains(recorded.sql[2], "email") {
+		t.Errorf("second lookup: %s", recorded.sql[2])
+	}
+}
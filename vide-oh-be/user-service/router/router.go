@@ -0,0 +1,92 @@
+package router
+
+import (
+	"log/slog"
+	"time"
+	"user-service/apperrors"
+	"user-service/controllers"
+	"user-service/health"
+	"user-service/logging"
+	"user-service/middleware"
+	"user-service/openapi"
+	"user-service/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+func CORS() gin.HandlerFunc {
+	// TO allow CORS
+	return func(c *gin.Context) {
+		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, If-Match, If-None-Match")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag, Location, Deprecation, Sunset, Link")
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+		c.Next()
+	}
+}
+
+// New builds the engine served by the Lambda handler and by -local mode.
+func New(repos repository.Repositories) *gin.Engine {
+	router := gin.New()
+	router.Use(apperrors.Recovery(), CORS())
+	router.NoRoute(apperrors.NoRoute)
+	Register(router, repos)
+	return router
+}
+
+// The v1 routes stay until the sunset; /api/v2 replaces them.
+var (
+	v1Deprecated = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	v1Sunset     = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// Register mounts the /api/users and /api/v2/users routes on an existing
+// router, which lets the dev gateway serve every service from a single
+// engine.
+func Register(router gin.IRouter, repos repository.Repositories) {
+	users := controllers.NewUserController(repos)
+	auth := middleware.Auth(repos.Users)
+
+	api := router.Group("/api/users")
+	api.Use(otelgin.Middleware("user-service"), logging.Middleware(slog.Default()), openapi.Middleware())
+	{
+		api.GET("/health/live", health.Live)
+		api.GET("/health/ready", health.Ready)
+		api.GET("/openapi.json", openapi.Serve)
+
+		v1 := api.Group("", middleware.Deprecated(v1Deprecated, v1Sunset, "/api/v2/users"))
+		v1.POST("/login", users.Login)
+		v1.POST("/register", users.RegisterUser)
+		v1.GET("/ping", controllers.Ping)
+		secured := v1.Group("/secured").Use(auth)
+		{
+			secured.GET("/ping", controllers.Ping)
+			secured.GET("/user/all-registered", users.GetAllRegisteredUsers) // only admin
+			secured.GET("/block/:email", users.BlockUser)                    // only admin
+			secured.GET("/user/:id", users.GetUserById)
+			secured.GET("/user/current", users.GetCurrentUser)
+			secured.GET("/user/change-name", users.ChangeName)
+		}
+	}
+
+	v2 := router.Group("/api/v2/users")
+	v2.Use(otelgin.Middleware("user-service"), logging.Middleware(slog.Default()), openapi.Middleware())
+	{
+		v2.POST("", users.CreateUser)
+		v2.POST("/sessions", users.Login)
+		secured := v2.Group("").Use(auth)
+		{
+			secured.GET("", users.ListUsers) // only admin
+			secured.GET("/me", users.GetMe)
+			secured.PATCH("/me", users.UpdateMe)
+			secured.GET("/:id", users.GetUser)
+			secured.PUT("/:id/block", users.SetBlock)      // only admin
+			secured.DELETE("/:id/block", users.ClearBlock) // only admin
+		}
+	}
+}
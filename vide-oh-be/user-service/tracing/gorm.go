@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	gormSpanKey      = "tracing:span"
+	gormOperationKey = "tracing:operation"
+)
+
+// GormPlugin starts a client span around every gorm create, query, update,
+// delete, row and raw call. The SQL is recorded with placeholders only, so
+// bound values never reach the trace backend.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	return errors.Join(
+		callback.Create().Before("gorm:create").Register("tracing:before_create", beforeGorm("INSERT")),
+		callback.Create().After("gorm:create").Register("tracing:after_create", afterGorm),
+		callback.Query().Before("gorm:query").Register("tracing:before_query", beforeGorm("SELECT")),
+		callback.Query().After("gorm:query").Register("tracing:after_query", afterGorm),
+		callback.Update().Before("gorm:update").Register("tracing:before_update", beforeGorm("UPDATE")),
+		callback.Update().After("gorm:update").Register("tracing:after_update", afterGorm),
+		callback.Delete().Before("gorm:delete").Register("tracing:before_delete", beforeGorm("DELETE")),
+		callback.Delete().After("gorm:delete").Register("tracing:after_delete", afterGorm),
+		callback.Row().Before("gorm:row").Register("tracing:before_row", beforeGorm("ROW")),
+		callback.Row().After("gorm:row").Register("tracing:after_row", afterGorm),
+		callback.Raw().Before("gorm:raw").Register("tracing:before_raw", beforeGorm("RAW")),
+		callback.Raw().After("gorm:raw").Register("tracing:after_raw", afterGorm),
+	)
+}
+
+func beforeGorm(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+		ctx, span := Start(db.Statement.Context, operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", dbSystem(db)),
+				attribute.String("db.operation", operation),
+			),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, span)
+		db.InstanceSet(gormOperationKey, operation)
+	}
+}
+
+func dbSystem(db *gorm.DB) string {
+	if db.Dialector.Name() == "sqlite" {
+		return "sqlite"
+	}
+	return "postgresql"
+}
+
+func afterGorm(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+
+	// Name the span "SELECT videos" once the table is known
+	if table := db.Statement.Table; table != "" {
+		if operation, ok := db.InstanceGet(gormOperationKey); ok {
+			span.SetName(operation.(string) + " " + table)
+		}
+		span.SetAttributes(attribute.String("db.sql.table", table))
+	}
+	span.SetAttributes(
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	End(span, err)
+}
@@ -1,36 +1,54 @@
 package utils
 
 import (
-	"fmt"
+	"context"
 	"net/smtp"
+	"strconv"
+	"user-service/logging"
 )
 
-func SendBlockedMail(email string) {
+var (
+	smtpHost = "localhost"
+	smtpPort = "1025"
+	mailFrom = "vide.oh@smtp.com"
+)
 
-	// Sender data.
-	from := "vide.oh@smtp.com"
-	// password := "strongpw#1"
+// SetMailServer configures the SMTP relay used for notifications.
+func SetMailServer(host string, port int, from string) {
+	smtpHost = host
+	smtpPort = strconv.Itoa(port)
+	mailFrom = from
+}
 
-	// Receiver email address.
-	to := []string{
-		email,
+func SendBlockedMail(ctx context.Context, email string, reason string) {
+	message := "Your account has been blocked."
+	if reason != "" {
+		message += "\nReason: " + reason
 	}
+	sendMail(ctx, email, "blocked", message)
+}
 
-	// smtp server configuration.
-	smtpHost := "localhost"
-	smtpPort := "1025"
+func SendUnblockedMail(ctx context.Context, email string, reason string) {
+	message := "Your account has been unblocked."
+	if reason != "" {
+		message += "\nReason: " + reason
+	}
+	sendMail(ctx, email, "unblocked", message)
+}
 
-	// Message.
-	message := []byte("Your account has been blocked.")
+func sendMail(ctx context.Context, email string, kind string, message string) {
+	logger := logging.FromContext(ctx).With("recipient", email)
 
-	// Authentication.
-	// auth := smtp.PlainAuth("", from, password, smtpHost)
+	// Receiver email address.
+	to := []string{
+		email,
+	}
 
 	// Sending email.
-	err := smtp.SendMail(smtpHost+":"+smtpPort, nil, from, to, message)
+	err := smtp.SendMail(smtpHost+":"+smtpPort, nil, mailFrom, to, []byte(message))
 	if err != nil {
-		fmt.Println(err)
+		logger.Error("failed to send "+kind+" mail", "error", err)
 		return
 	}
-	fmt.Println("Email Sent Successfully!")
+	logger.Info(kind + " mail sent")
 }
@@ -0,0 +1,41 @@
+package apperrors
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeUploadFailed         Code = "upload_failed"
+	CodeUploadExpired        Code = "upload_expired"
+	CodeTooLarge             Code = "too_large"
+	CodeInvalidVideo         Code = "invalid_video"
+	CodeVideoTooLong         Code = "video_too_long"
+	CodeResolutionTooHigh    Code = "resolution_too_high"
+)
+
+var (
+	ErrUnsupportedMediaType = New(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "only MP4, MOV, WebM and MKV videos are accepted")
+	ErrUploadFailed         = New(http.StatusInternalServerError, CodeUploadFailed, "failed to store the uploaded video")
+	ErrUploadExpired        = New(http.StatusGone, CodeUploadExpired, "the upload session expired; start a new one")
+	ErrInvalidVideo         = New(http.StatusUnprocessableEntity, CodeInvalidVideo, "the file is not a video that can be decoded")
+	ErrUnsupportedImage     = New(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "thumbnails must be PNG, JPEG or WebP images")
+)
+
+func TooLarge(maxSize int64) *Error {
+	return New(http.StatusRequestEntityTooLarge, CodeTooLarge, fmt.Sprintf("videos may be at most %d bytes", maxSize))
+}
+
+func ThumbnailTooLarge(maxSize int64) *Error {
+	return New(http.StatusRequestEntityTooLarge, CodeTooLarge, fmt.Sprintf("thumbnails may be at most %d bytes", maxSize))
+}
+
+func VideoTooLong(maxDuration time.Duration) *Error {
+	return New(http.StatusUnprocessableEntity, CodeVideoTooLong, fmt.Sprintf("videos may be at most %s long", maxDuration))
+}
+
+func ResolutionTooHigh(maxWidth, maxHeight int) *Error {
+	return New(http.StatusUnprocessableEntity, CodeResolutionTooHigh, fmt.Sprintf("videos may be at most %dx%d, in either orientation", maxWidth, maxHeight))
+}
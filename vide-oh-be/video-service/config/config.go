@@ -0,0 +1,242 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+type Config struct {
+	// Local is set by the -local flag: settings come from the environment
+	// only and Secrets Manager is not used
+	Local bool
+
+	Region       string `env:"REGION" required:"always"`
+	LogLevel     string `env:"LOG_LEVEL" default:"info"`
+	DBSecretName string `env:"DB_SECRET_NAME"`
+	DB           DB
+
+	// Used instead of DB_SECRET_NAME/DB in local mode
+	DatabaseDSN string `env:"DB_DSN" required:"local"`
+	// postgres, or sqlite in local mode, where DB_DSN is the database file
+	DatabaseDriver string `env:"DB_DRIVER" default:"postgres"`
+	// Only needed by the in-process authorizer in local mode
+	JWTKey string `env:"JWT_SECRET_KEY" required:"local"`
+
+	S3         S3
+	Media      Media
+	Search     Search
+	Moderation Moderation
+	Tracing    Tracing
+	Events     Events
+	SMTP       SMTP
+
+	// off, report or enforce; defaults to enforce in local mode
+	OpenAPIValidation string `env:"OPENAPI_VALIDATION"`
+}
+
+const (
+	DBAuthSecret = "secret"
+	DBAuthIAM    = "iam"
+)
+
+const (
+	DBDriverPostgres = "postgres"
+	DBDriverSQLite   = "sqlite"
+)
+
+// DB selects how connections authenticate outside local mode: with the RDS
+// secret named by DB_SECRET_NAME, or with IAM auth tokens (e.g. through
+// RDS Proxy) for the user and endpoint given here.
+type DB struct {
+	AuthMode  string        `env:"DB_AUTH_MODE" default:"secret"`
+	SecretTTL time.Duration `env:"DB_SECRET_TTL" default:"5m"`
+	Host      string        `env:"DB_HOST"`
+	Port      int           `env:"DB_PORT" default:"5432"`
+	User      string        `env:"DB_USER"`
+	Name      string        `env:"DB_NAME"`
+}
+
+func (db DB) validate(secretName string, errs *Error) {
+	switch db.AuthMode {
+	case DBAuthSecret:
+		if secretName == "" {
+			errs.Missing = append(errs.Missing, "DB_SECRET_NAME")
+		}
+	case DBAuthIAM:
+		if db.Host == "" {
+			errs.Missing = append(errs.Missing, "DB_HOST")
+		}
+		if db.User == "" {
+			errs.Missing = append(errs.Missing, "DB_USER")
+		}
+		if db.Name == "" {
+			errs.Missing = append(errs.Missing, "DB_NAME")
+		}
+	default:
+		errs.Invalid = append(errs.Invalid, fmt.Sprintf("DB_AUTH_MODE: %q is not one of secret, iam", db.AuthMode))
+	}
+}
+
+// Tracing is off unless an OTLP/HTTP endpoint is set, e.g. a local
+// collector on http://localhost:4318.
+type Tracing struct {
+	Endpoint      string `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	SamplePercent int    `env:"TRACING_SAMPLE_PERCENT" default:"100"`
+}
+
+// Events are only logged unless a custom EventBridge bus is named.
+type Events struct {
+	BusName string `env:"EVENT_BUS_NAME"`
+}
+
+// SMTP is the relay for the mails to video owners.
+type SMTP struct {
+	Host string `env:"SMTP_HOST" default:"localhost"`
+	Port int    `env:"SMTP_PORT" default:"1025"`
+	From string `env:"MAIL_FROM" default:"vide.oh@smtp.com"`
+}
+
+type S3 struct {
+	BucketName string        `env:"BUCKET_NAME" required:"always"`
+	PresignTTL time.Duration `env:"PRESIGN_TTL" default:"15m"`
+	// Lets local mode talk to MinIO or LocalStack through AWS_ENDPOINT_URL
+	ForcePathStyle bool `env:"S3_FORCE_PATH_STYLE" default:"false"`
+	// Direct multipart uploads: the largest accepted file (10 GiB), and how
+	// long a session stays open before the cleanup aborts it
+	MaxUploadSize    int64         `env:"MAX_UPLOAD_SIZE" default:"10737418240"`
+	UploadSessionTTL time.Duration `env:"UPLOAD_SESSION_TTL" default:"24h"`
+}
+
+// Media limits what videos are accepted and how they are processed and
+// streamed.
+type Media struct {
+	// Longest and largest video accepted; the resolution limit applies to
+	// portrait videos turned sideways. One videoProcessor invocation has to
+	// convert and transcode the whole video within Lambda's 15 minutes.
+	MaxVideoDuration time.Duration `env:"MAX_VIDEO_DURATION" default:"10m"`
+	MaxVideoWidth    int           `env:"MAX_VIDEO_WIDTH" default:"3840"`
+	MaxVideoHeight   int           `env:"MAX_VIDEO_HEIGHT" default:"2160"`
+	// events waits for the S3 ObjectCreated notification to process an
+	// upload, inline processes it right after the upload; defaults to
+	// inline in local mode, which gets no S3 events
+	Processing string `env:"VIDEO_PROCESSING"`
+	// HLS playlists hand out presigned segment URLs valid this long, which
+	// must cover a viewing; the Lambda credentials that sign them last a
+	// few hours at most
+	StreamURLTTL time.Duration `env:"STREAM_URL_TTL" default:"4h"`
+	// Base of the playlist URLs, e.g. the API Gateway stage URL; taken from
+	// the request when empty
+	PublicURL string `env:"PUBLIC_API_URL"`
+}
+
+// Moderation covers how long deleted videos are kept and when reports
+// hide a video.
+type Moderation struct {
+	// Deleted videos keep their row this long before the purge removes it
+	// for good; failed videos keep it and their upload as long
+	DeletedRetention time.Duration `env:"DELETED_VIDEO_RETENTION" default:"720h"`
+	// Weight of open reports at which a video is hidden from searches;
+	// see models.ReportWeights
+	ReportHideThreshold int `env:"REPORT_HIDE_THRESHOLD" default:"5"`
+}
+
+// Search configures the full-text search over titles and descriptions.
+type Search struct {
+	// Postgres text search configuration that stems titles and
+	// descriptions, e.g. english or simple
+	Language string `env:"SEARCH_LANGUAGE" default:"english"`
+}
+
+var searchLanguage = regexp.MustCompile(`^[a-z_]+$`)
+
+const (
+	ProcessingEvents = "events"
+	ProcessingInline = "inline"
+)
+
+// Load reads the configuration from the environment and the optional JSON
+// file named by CONFIG_FILE. Every missing or malformed value is reported in
+// a single error. Database credentials are fetched later by the database
+// package so they can be refreshed after a rotation.
+func Load(local bool) (*Config, error) {
+	get, err := newLookup()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{Local: local}
+	errs := &Error{}
+	populate(cfg, get, local, errs)
+	if cfg.S3.PresignTTL <= 0 || cfg.S3.PresignTTL > 7*24*time.Hour {
+		errs.Invalid = append(errs.Invalid, "PRESIGN_TTL: must be between 0 and 168h")
+	}
+	if cfg.Media.StreamURLTTL <= 0 || cfg.Media.StreamURLTTL > 12*time.Hour {
+		errs.Invalid = append(errs.Invalid, "STREAM_URL_TTL: must be between 0 and 12h")
+	}
+	// S3 objects are at most 5 TiB
+	if cfg.S3.MaxUploadSize <= 0 || cfg.S3.MaxUploadSize > 5<<40 {
+		errs.Invalid = append(errs.Invalid, "MAX_UPLOAD_SIZE: must be between 1 byte and 5 TiB")
+	}
+	if cfg.S3.UploadSessionTTL <= 0 {
+		errs.Invalid = append(errs.Invalid, "UPLOAD_SESSION_TTL: must be positive")
+	}
+	if cfg.Media.MaxVideoDuration <= 0 {
+		errs.Invalid = append(errs.Invalid, "MAX_VIDEO_DURATION: must be positive")
+	}
+	if cfg.Media.MaxVideoWidth <= 0 || cfg.Media.MaxVideoHeight <= 0 || cfg.Media.MaxVideoHeight > cfg.Media.MaxVideoWidth {
+		errs.Invalid = append(errs.Invalid, "MAX_VIDEO_WIDTH, MAX_VIDEO_HEIGHT: must be positive, with the width the larger one")
+	}
+	if cfg.Moderation.DeletedRetention <= 0 {
+		errs.Invalid = append(errs.Invalid, "DELETED_VIDEO_RETENTION: must be positive")
+	}
+	if cfg.Moderation.ReportHideThreshold <= 0 {
+		errs.Invalid = append(errs.Invalid, "REPORT_HIDE_THRESHOLD: must be positive")
+	}
+	// Goes into the DDL of the search index and its name
+	if !searchLanguage.MatchString(cfg.Search.Language) {
+		errs.Invalid = append(errs.Invalid, fmt.Sprintf("SEARCH_LANGUAGE: %q is not a text search configuration name", cfg.Search.Language))
+	}
+	if cfg.Tracing.SamplePercent < 0 || cfg.Tracing.SamplePercent > 100 {
+		errs.Invalid = append(errs.Invalid, "TRACING_SAMPLE_PERCENT: must be between 0 and 100")
+	}
+	if cfg.OpenAPIValidation == "" {
+		cfg.OpenAPIValidation = "off"
+		if local {
+			cfg.OpenAPIValidation = "enforce"
+		}
+	}
+	switch cfg.OpenAPIValidation {
+	case "off", "report", "enforce":
+	default:
+		errs.Invalid = append(errs.Invalid, fmt.Sprintf("OPENAPI_VALIDATION: %q is not one of off, report, enforce", cfg.OpenAPIValidation))
+	}
+	if cfg.Media.Processing == "" {
+		cfg.Media.Processing = ProcessingEvents
+		if local {
+			cfg.Media.Processing = ProcessingInline
+		}
+	}
+	switch cfg.Media.Processing {
+	case ProcessingEvents, ProcessingInline:
+	default:
+		errs.Invalid = append(errs.Invalid, fmt.Sprintf("VIDEO_PROCESSING: %q is not one of events, inline", cfg.Media.Processing))
+	}
+	switch cfg.DatabaseDriver {
+	case DBDriverPostgres:
+	case DBDriverSQLite:
+		if !local {
+			errs.Invalid = append(errs.Invalid, "DB_DRIVER: sqlite is only supported in local mode")
+		}
+	default:
+		errs.Invalid = append(errs.Invalid, fmt.Sprintf("DB_DRIVER: %q is not one of postgres, sqlite", cfg.DatabaseDriver))
+	}
+	if !local {
+		cfg.DB.validate(cfg.DBSecretName, errs)
+	}
+	if !errs.empty() {
+		return nil, errs
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+	"video-service/apperrors"
+	"video-service/etag"
+	"video-service/eventbus"
+	"video-service/logging"
+	"video-service/metrics"
+	"video-service/models"
+	"video-service/repository"
+	"video-service/utils"
+)
+
+// Deletion: the row is soft-deleted and every object of the video removed
+// right away, then VideoDeleted is published for the other services. The
+// purge removes the row after the retention period, deleting the objects
+// again in case that failed or processing wrote some afterwards.
+
+// delete removes the video if claims may do so and ifMatch, when set,
+// still matches it. The owner is mailed, with reason, when someone else
+// deleted it.
+func (v *VideoController) delete(ctx context.Context, id uint64, claims utils.JWTClaim, ifMatch, reason string) error {
+	var video *models.Video
+	err := v.repos.UnitOfWork.Do(ctx, func(tx repository.Repositories) error {
+		var err error
+		if video, err = tx.Videos.ForUpdate().ByID(ctx, id); err != nil {
+			return apperrors.FromDB(err, "video")
+		}
+		if claims.Role == "RegisteredUser" && claims.Email != video.OwnerEmail {
+			return apperrors.Forbidden("you can only delete your own videos")
+		}
+		if err := etag.Check(ifMatch, etag.Of(video.ID, video.UpdatedAt), false); err != nil {
+			return err
+		}
+		if err := tx.Videos.Delete(ctx, video.ID); err != nil {
+			return apperrors.FromDB(err, "video")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger := logging.FromContext(ctx).With("video_id", video.ID)
+	logger.Info("video deleted", "deleted_by", claims.Email, "deleted_by_role", claims.Role)
+	// The purge retries what fails from here on, so the request succeeds
+	if err := v.deleteObjects(ctx, video.Filename); err != nil {
+		logger.Error("failed to delete the video objects", "error", err)
+	}
+	v.publish(ctx, eventbus.TypeVideoDeleted, eventbus.VideoDeleted{
+		VideoID:       video.ID,
+		OwnerEmail:    video.OwnerEmail,
+		DeletedBy:     claims.Email,
+		DeletedByRole: claims.Role,
+		DeletedAt:     time.Now().UTC(),
+	})
+	if claims.Email != video.OwnerEmail {
+		utils.SendVideoDeletedMail(ctx, video.OwnerEmail, video.Title, reason)
+	}
+	return nil
+}
+
+// deleteObjects removes everything stored for a video: the upload, the
+// .mp4, the generated thumbnail and the HLS, storyboard and later
+// thumbnail files below <filename>/.
+func (v *VideoController) deleteObjects(ctx context.Context, filename string) error {
+	var errs []error
+	for _, key := range []string{originalKey(filename), filename + ".mp4", filename + ".png"} {
+		if err := v.bucket.DeleteObject(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if _, err := v.bucket.DeletePrefix(ctx, filename+"/"); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// publish sends an event without failing the request; consumers only
+// clean up after the video, so a lost event leaves stale data behind.
+func (v *VideoController) publish(ctx context.Context, detailType string, detail any) {
+	if err := v.events.Publish(ctx, detailType, detail); err != nil {
+		metrics.PublishedEvents.Inc("failed")
+		logging.FromContext(ctx).Error("failed to publish event", "detail_type", detailType, "error", err)
+		return
+	}
+	metrics.PublishedEvents.Inc("published")
+}
+
+// PurgeVideos removes the videos deleted, or failed, longer than the
+// retention period ago for good, with their objects, edit history and
+// reports.
+func (v *VideoController) PurgeVideos(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
+	before := time.Now().Add(-v.settings.Moderation.DeletedRetention)
+
+	deleted, err := v.repos.Videos.DeletedBefore(ctx, before)
+	if err != nil {
+		return err
+	}
+	for _, video := range deleted {
+		if err := v.purge(ctx, video); err != nil {
+			return err
+		}
+		metrics.PurgedVideos.Inc("deleted")
+	}
+	failed, err := v.repos.Videos.FailedBefore(ctx, before)
+	if err != nil {
+		return err
+	}
+	for _, video := range failed {
+		if err := v.purge(ctx, video); err != nil {
+			return err
+		}
+		metrics.PurgedVideos.Inc("failed")
+	}
+	logger.Info("video purge finished", "deleted", len(deleted), "failed", len(failed))
+	return nil
+}
+
+func (v *VideoController) purge(ctx context.Context, video models.Video) error {
+	if err := v.deleteObjects(ctx, video.Filename); err != nil {
+		return err
+	}
+	return v.repos.UnitOfWork.Do(ctx, func(tx repository.Repositories) error {
+		if err := tx.Edits.DeleteByVideo(ctx, video.ID); err != nil {
+			return err
+		}
+		if err := tx.Reports.DeleteByVideo(ctx, video.ID); err != nil {
+			return err
+		}
+		return tx.Videos.Purge(ctx, video.ID)
+	})
+}
+
+// Cleanup is the scheduled maintenance: upload sessions, then the purge.
+func (v *VideoController) Cleanup(ctx context.Context) error {
+	return errors.Join(v.CleanupUploads(ctx), v.PurgeVideos(ctx))
+}
@@ -0,0 +1,124 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"video-service/apperrors"
+	"video-service/eventbus"
+	"video-service/models"
+)
+
+// stored lists the objects of the video with filename.
+func (e *env) stored(filename string) []string {
+	var keys []string
+	for _, key := range e.bucket.Keys() {
+		if strings.HasPrefix(key, filename) || key == "originals/"+filename {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestRemoveVideo(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	path := videoPath(video.ID)
+
+	problem(t, e.do(http.MethodDelete, path, ownerToken, "", nil, "If-Match", `"stale"`), http.StatusPreconditionFailed, apperrors.CodePreconditionFailed)
+	problem(t, e.do(http.MethodDelete, path, otherToken, "", nil), http.StatusForbidden, apperrors.CodeForbidden)
+	if len(e.stored(video.Filename)) == 0 || len(e.events.published()) != 0 {
+		t.Fatal("a rejected delete removed the video")
+	}
+
+	if rec := e.do(http.MethodDelete, path, ownerToken, "", nil, "If-Match", e.tag(video.ID)); rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	problem(t, e.get(path, ""), http.StatusNotFound, apperrors.CodeNotFound)
+	if keys := e.stored(video.Filename); len(keys) != 0 {
+		t.Fatalf("objects left: %v", keys)
+	}
+	if e.video(video.ID).DeletedAt.Time.IsZero() {
+		t.Fatal("the row was not soft-deleted")
+	}
+
+	events := e.events.published()
+	if len(events) != 1 || events[0].detailType != eventbus.TypeVideoDeleted {
+		t.Fatalf("events = %+v", events)
+	}
+	deleted := events[0].detail.(eventbus.VideoDeleted)
+	if deleted.VideoID != video.ID || deleted.OwnerEmail != owner || deleted.DeletedBy != owner || deleted.DeletedByRole != "RegisteredUser" {
+		t.Fatalf("event = %+v", deleted)
+	}
+	problem(t, e.do(http.MethodDelete, path, ownerToken, "", nil), http.StatusNotFound, apperrors.CodeNotFound)
+}
+
+func TestRemoveVideoByAdministrator(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	if rec := e.do(http.MethodDelete, videoPath(video.ID)+"?reason=spam", adminToken, "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	events := e.events.published()
+	if len(events) != 1 {
+		t.Fatalf("events = %+v", events)
+	}
+	if deleted := events[0].detail.(eventbus.VideoDeleted); deleted.DeletedBy != admin || deleted.DeletedByRole != "Administrator" {
+		t.Fatalf("deleted = %+v", deleted)
+	}
+}
+
+func TestDeleteVideoV1(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	path := "/api/videos/delete-video/" + strconv.FormatUint(uint64(video.ID), 10)
+
+	problem(t, e.get(path, otherToken), http.StatusForbidden, apperrors.CodeForbidden)
+	if rec := e.get(path, ownerToken); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	problem(t, e.get(videoPath(video.ID), ""), http.StatusNotFound, apperrors.CodeNotFound)
+}
+
+func TestPurgeVideos(t *testing.T) {
+	e := newEnv(t)
+	kept := e.upload("Kept", "")
+	purged := e.upload("Purged", "")
+	e.report(purged.ID, otherToken, "spam")
+	e.send(http.MethodPatch, videoPath(purged.ID), ownerToken, map[string]string{"title": "Edited"}, "If-Match", e.tag(purged.ID))
+	if rec := e.do(http.MethodDelete, videoPath(purged.ID), ownerToken, "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	ctx := context.Background()
+	// Deleted within the retention period
+	if err := e.videos.PurgeVideos(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.db.Unscoped().First(&models.Video{}, purged.ID).Error; err != nil {
+		t.Fatalf("purged too early: %v", err)
+	}
+
+	if err := e.db.Unscoped().Model(&models.Video{}).Where("id = ?", purged.ID).
+		Update("deleted_at", time.Now().Add(-721*time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := e.videos.PurgeVideos(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	e.db.Unscoped().Model(&models.Video{}).Where("id = ?", purged.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("the row was not purged")
+	}
+	for _, table := range []any{&models.VideoEdit{}, &models.VideoReport{}} {
+		e.db.Unscoped().Model(table).Where("video_id = ?", purged.ID).Count(&count)
+		if count != 0 {
+			t.Fatalf("%T rows left: %d", table, count)
+		}
+	}
+	decode[models.VideoSearchResultDTO](t, e.get(videoPath(kept.ID), ""), http.StatusOK)
+}
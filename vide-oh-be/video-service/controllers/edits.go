@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+	"video-service/apperrors"
+	"video-service/etag"
+	"video-service/logging"
+	"video-service/models"
+	"video-service/repository"
+	"video-service/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VideoPatch struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	// Seconds; picks the poster frame at that time as the thumbnail
+	ThumbnailTime *float64 `json:"thumbnailTime" binding:"omitempty,min=0"`
+}
+
+// validateText trims a title and description in place and checks them
+// against the rules shared by uploads and edits. A nil one is skipped, as
+// in a patch that leaves it alone.
+func validateText(title, description *string) error {
+	if title != nil {
+		*title = strings.TrimSpace(*title)
+		if *title == "" {
+			return apperrors.InvalidRequest("title must not be blank")
+		}
+		if utf8.RuneCountInString(*title) > models.MaxTitleLength {
+			return apperrors.InvalidRequest(fmt.Sprintf("title may be at most %d characters", models.MaxTitleLength))
+		}
+	}
+	if description != nil {
+		*description = strings.TrimSpace(*description)
+		if utf8.RuneCountInString(*description) > models.MaxDescriptionLength {
+			return apperrors.InvalidRequest(fmt.Sprintf("description may be at most %d characters", models.MaxDescriptionLength))
+		}
+	}
+	return nil
+}
+
+// UpdateVideo edits the title, description or poster frame of a video,
+// for its owner or an administrator. If-Match is required. The previous
+// title, description and thumbnail time go to the edit history.
+func (v *VideoController) UpdateVideo(c *gin.Context) {
+	id, ok := videoID(c)
+	if !ok {
+		return
+	}
+	_, claims := utils.GetTokenClaims(c)
+	if claims.Role != "RegisteredUser" && claims.Role != "Administrator" {
+		apperrors.Abort(c, apperrors.Forbidden("only the owner or an administrator can edit a video"))
+		return
+	}
+
+	var patch VideoPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		apperrors.Abort(c, apperrors.Invalid(err))
+		return
+	}
+	if patch.Title == nil && patch.Description == nil && patch.ThumbnailTime == nil {
+		apperrors.Abort(c, apperrors.InvalidRequest("nothing to change"))
+		return
+	}
+	if err := validateText(patch.Title, patch.Description); err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	ifMatch := c.GetHeader("If-Match")
+	check := func(video *models.Video) error {
+		if claims.Role == "RegisteredUser" && claims.Email != video.OwnerEmail {
+			return apperrors.Forbidden("you can only edit your own videos")
+		}
+		if err := etag.Check(ifMatch, etag.Of(video.ID, video.UpdatedAt), true); err != nil {
+			return err
+		}
+		if patch.ThumbnailTime != nil && !video.Ready() {
+			return apperrors.Conflict("the poster frame can only be picked once the video is ready")
+		}
+		return nil
+	}
+
+	// ffmpeg runs and the poster frame is stored under a new key before
+	// the transaction; the edit then points the video at it
+	var key string
+	if patch.ThumbnailTime != nil {
+		video, err := v.repos.Videos.ByID(ctx, id)
+		if err == nil {
+			err = check(video)
+		}
+		var thumbnail string
+		if err == nil {
+			thumbnail, err = v.renderPosterFrame(ctx, video, *patch.ThumbnailTime)
+		}
+		if err != nil {
+			apperrors.Abort(c, updateError(err))
+			return
+		}
+		key, err = v.storeNewThumbnail(ctx, thumbnail, video.Filename)
+		removeThumbnail(ctx, thumbnail)
+		if err != nil {
+			apperrors.Abort(c, apperrors.Internal(err))
+			return
+		}
+	}
+
+	video, err := v.editVideo(ctx, id, claims, check, func(video *models.Video) {
+		if patch.Title != nil {
+			video.Title = *patch.Title
+		}
+		if patch.Description != nil {
+			video.Description = *patch.Description
+		}
+		if patch.ThumbnailTime != nil {
+			video.ThumbnailTime = patch.ThumbnailTime
+			video.Thumbnail = key
+		}
+	})
+	if err != nil {
+		if key != "" {
+			v.discardThumbnail(ctx, key)
+		}
+		apperrors.Abort(c, updateError(err))
+		return
+	}
+	logging.FromContext(ctx).Info("video edited", "video_id", video.ID, "editor_role", claims.Role)
+
+	v.writeVideo(c, http.StatusOK, video)
+}
+
+// editVideo applies change to the :id video and records the title,
+// description and thumbnail time it had before in the edit history, in
+// one transaction. check runs first, on the row read and locked in the
+// transaction, so an If-Match check holds until the save.
+func (v *VideoController) editVideo(ctx context.Context, id uint64, claims utils.JWTClaim, check func(*models.Video) error, change func(*models.Video)) (*models.Video, error) {
+	var video *models.Video
+	err := v.repos.UnitOfWork.Do(ctx, func(tx repository.Repositories) error {
+		var err error
+		if video, err = tx.Videos.ForUpdate().ByID(ctx, id); err != nil {
+			return err
+		}
+		if err := check(video); err != nil {
+			return err
+		}
+		edit := &models.VideoEdit{
+			VideoID:       video.ID,
+			EditorEmail:   claims.Email,
+			EditorRole:    claims.Role,
+			Title:         video.Title,
+			Description:   video.Description,
+			ThumbnailTime: video.ThumbnailTime,
+			Thumbnail:     video.Thumbnail,
+		}
+		if err := tx.Edits.Create(ctx, edit); err != nil {
+			return err
+		}
+		change(video)
+		return tx.Videos.Save(ctx, video)
+	})
+	return video, err
+}
+
+// ListVideoEdits returns the edit history of a video, newest first.
+// Administrator only.
+func (v *VideoController) ListVideoEdits(c *gin.Context) {
+	_, claims := utils.GetTokenClaims(c)
+	if claims.Role != "Administrator" {
+		apperrors.Abort(c, apperrors.Forbidden("administrator role required"))
+		return
+	}
+	id, ok := videoID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	video, err := v.repos.Videos.ByID(ctx, id)
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "video"))
+		return
+	}
+	edits, err := v.repos.Edits.ByVideo(ctx, video.ID)
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "video edit"))
+		return
+	}
+	results := make([]models.VideoEditDTO, 0, len(edits))
+	for _, edit := range edits {
+		thumbnailURL, err := v.presignedURL(ctx, models.ThumbnailKey(video.Filename, edit.Thumbnail))
+		if err != nil {
+			apperrors.Abort(c, apperrors.Internal(err))
+			return
+		}
+		results = append(results, models.VideoEditDTO{
+			ID:            edit.ID,
+			EditedAt:      edit.CreatedAt,
+			EditorEmail:   edit.EditorEmail,
+			EditorRole:    edit.EditorRole,
+			Title:         edit.Title,
+			Description:   edit.Description,
+			ThumbnailTime: edit.ThumbnailTime,
+			ThumbnailURL:  thumbnailURL,
+		})
+	}
+	c.JSON(http.StatusOK, results)
+}
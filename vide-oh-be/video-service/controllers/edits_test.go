@@ -0,0 +1,179 @@
+package controllers_test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"video-service/apperrors"
+	"video-service/models"
+)
+
+// tag returns the current ETag of a ready video.
+func (e *env) tag(id uint) string {
+	e.t.Helper()
+	rec := e.get(videoPath(id), "")
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == "" {
+		e.t.Fatalf("GET %d: %d, ETag %q", id, rec.Code, rec.Header().Get("ETag"))
+	}
+	return rec.Header().Get("ETag")
+}
+
+func (e *env) edits(id uint) []models.VideoEditDTO {
+	e.t.Helper()
+	return decode[[]models.VideoEditDTO](e.t, e.get(videoPath(id, "edits"), adminToken), http.StatusOK)
+}
+
+func TestUpdateVideo(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "Two cats")
+	path := videoPath(video.ID)
+	tag := e.tag(video.ID)
+
+	problem(t, e.send(http.MethodPatch, path, ownerToken, map[string]string{"title": "Kittens"}),
+		http.StatusPreconditionRequired, apperrors.CodePreconditionRequired)
+	problem(t, e.send(http.MethodPatch, path, ownerToken, map[string]string{"title": "Kittens"}, "If-Match", `"stale"`),
+		http.StatusPreconditionFailed, apperrors.CodePreconditionFailed)
+	problem(t, e.send(http.MethodPatch, path, otherToken, map[string]string{"title": "Kittens"}, "If-Match", tag),
+		http.StatusForbidden, apperrors.CodeForbidden)
+	problem(t, e.send(http.MethodPatch, path, ownerToken, map[string]string{"title": "  "}, "If-Match", tag),
+		http.StatusBadRequest, apperrors.CodeInvalidRequest)
+	problem(t, e.send(http.MethodPatch, path, ownerToken, map[string]string{}, "If-Match", tag),
+		http.StatusBadRequest, apperrors.CodeInvalidRequest)
+
+	rec := e.send(http.MethodPatch, path, ownerToken, map[string]string{"title": " Kittens "}, "If-Match", tag)
+	edited := decode[models.VideoSearchResultDTO](t, rec, http.StatusOK)
+	if edited.Title != "Kittens" || edited.Description != "Two cats" {
+		t.Fatalf("edited = %+v", edited)
+	}
+	newTag := rec.Header().Get("ETag")
+	if newTag == "" || newTag == tag {
+		t.Fatalf("ETag %q, before %q", newTag, tag)
+	}
+	// The old tag no longer matches
+	problem(t, e.send(http.MethodPatch, path, ownerToken, map[string]string{"description": "Cats"}, "If-Match", tag),
+		http.StatusPreconditionFailed, apperrors.CodePreconditionFailed)
+
+	// Administrators edit any video
+	decode[models.VideoSearchResultDTO](t, e.send(http.MethodPatch, path, adminToken, map[string]string{"description": "Moderated"}, "If-Match", e.tag(video.ID)), http.StatusOK)
+
+	edits := e.edits(video.ID)
+	if len(edits) != 2 {
+		t.Fatalf("edits = %+v", edits)
+	}
+	// Newest first, each with what the video had before
+	if edits[0].EditorEmail != admin || edits[0].EditorRole != "Administrator" || edits[0].Title != "Kittens" || edits[0].Description != "Two cats" {
+		t.Errorf("edits[0] = %+v", edits[0])
+	}
+	if edits[1].EditorEmail != owner || edits[1].EditorRole != "RegisteredUser" || edits[1].Title != "Cats" {
+		t.Errorf("edits[1] = %+v", edits[1])
+	}
+	problem(t, e.get(videoPath(video.ID, "edits"), ownerToken), http.StatusForbidden, apperrors.CodeForbidden)
+}
+
+func TestUpdateVideoPosterFrame(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	path := videoPath(video.ID)
+
+	// Neither past the end nor where ffmpeg finds no frame
+	for _, at := range []float64{13, 12.4} {
+		problem(t, e.send(http.MethodPatch, path, ownerToken, map[string]float64{"thumbnailTime": at}, "If-Match", e.tag(video.ID)),
+			http.StatusBadRequest, apperrors.CodeInvalidRequest)
+	}
+	if edits := e.edits(video.ID); len(edits) != 0 {
+		t.Fatalf("rejected edits were recorded: %+v", edits)
+	}
+
+	decode[models.VideoSearchResultDTO](t, e.send(http.MethodPatch, path, ownerToken, map[string]float64{"thumbnailTime": 5}, "If-Match", e.tag(video.ID)), http.StatusOK)
+	if got := e.thumbnail(video.ID); got != "png at 5.000" {
+		t.Fatalf("thumbnail = %q", got)
+	}
+	if at := e.video(video.ID).ThumbnailTime; at == nil || *at != 5 {
+		t.Fatalf("thumbnail time = %v", at)
+	}
+	// The edit keeps the first frame it replaced
+	edits := e.edits(video.ID)
+	if len(edits) != 1 || edits[0].ThumbnailTime == nil || *edits[0].ThumbnailTime != 0 {
+		t.Fatalf("edits = %+v", edits)
+	}
+}
+
+func TestTextLimits(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	long := strings.Repeat("é", models.MaxTitleLength+1)
+
+	problem(t, e.send(http.MethodPatch, videoPath(video.ID), ownerToken, map[string]string{"title": long}, "If-Match", e.tag(video.ID)),
+		http.StatusBadRequest, apperrors.CodeValidationFailed)
+	problem(t, e.send(http.MethodPatch, videoPath(video.ID), ownerToken, map[string]string{"description": strings.Repeat("x", models.MaxDescriptionLength+1)}, "If-Match", e.tag(video.ID)),
+		http.StatusBadRequest, apperrors.CodeValidationFailed)
+	// Characters count, not bytes
+	decode[models.VideoSearchResultDTO](t, e.send(http.MethodPatch, videoPath(video.ID), ownerToken, map[string]string{"title": long[2:]}, "If-Match", e.tag(video.ID)), http.StatusOK)
+
+	contentType, body := form(t, "file", "cats.mp4", mp4, "title", long)
+	problem(t, e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body), http.StatusBadRequest, apperrors.CodeInvalidRequest)
+}
+
+func TestBlankTitles(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+
+	problem(t, e.send(http.MethodPatch, videoPath(video.ID), ownerToken, map[string]string{"title": " \t"}, "If-Match", e.tag(video.ID)),
+		http.StatusBadRequest, apperrors.CodeInvalidRequest)
+	contentType, body := form(t, "file", "cats.mp4", mp4)
+	problem(t, e.do(http.MethodPost, "/api/videos/upload-video?title=+++", ownerToken, contentType, body), http.StatusBadRequest, apperrors.CodeInvalidRequest)
+	contentType, body = form(t, "file", "cats.mp4", mp4, "title", "   ")
+	problem(t, e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body), http.StatusBadRequest, apperrors.CodeInvalidRequest)
+	problem(t, e.send(http.MethodPost, "/api/v2/videos/uploads", ownerToken, map[string]any{"filename": "cats.mp4", "size": 100, "title": " "}),
+		http.StatusBadRequest, apperrors.CodeInvalidRequest)
+
+	// Titles and descriptions are stored trimmed
+	contentType, body = form(t, "file", "cats.mp4", mp4, "title", " Dogs ", "description", "Two dogs\n")
+	created := decode[models.VideoSearchResultDTO](t, e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body), http.StatusCreated)
+	if created.Title != "Dogs" || created.Description != "Two dogs" {
+		t.Fatalf("created = %+v", created)
+	}
+}
+
+func TestThumbnailEndpointsRecordEdits(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	path := videoPath(video.ID, "thumbnail")
+
+	decode[map[string]any](t, e.send(http.MethodPost, path, ownerToken, map[string]any{"time": 3}), http.StatusOK)
+	posterFrame := e.video(video.ID)
+	if at := posterFrame.ThumbnailTime; at == nil || *at != 3 {
+		t.Fatalf("thumbnail time = %v", at)
+	}
+	// Administrators can change any thumbnail, like the rest of a video
+	contentType, body := form(t, "image", "cover.png", png)
+	decode[map[string]any](t, e.do(http.MethodPut, path, adminToken, contentType, body), http.StatusOK)
+	uploaded := e.video(video.ID)
+	// An uploaded picture has no time
+	if at := uploaded.ThumbnailTime; at != nil {
+		t.Fatalf("thumbnail time = %v", *at)
+	}
+
+	// Every thumbnail has its own key, so the history still has the
+	// earlier ones
+	keys := []string{video.Filename + ".png", posterFrame.ThumbnailKey(), uploaded.ThumbnailKey()}
+	if keys[1] == keys[0] || keys[2] == keys[1] || !strings.HasPrefix(keys[1], video.Filename+"/thumbnails/") {
+		t.Fatalf("thumbnail keys = %q", keys)
+	}
+	if object, ok := e.bucket.Object(keys[1]); !ok || string(bytes.TrimSpace(object.Body)) != "png at 3.000" {
+		t.Fatalf("poster frame = %v, %v", object, ok)
+	}
+
+	edits := e.edits(video.ID)
+	if len(edits) != 2 {
+		t.Fatalf("edits = %+v", edits)
+	}
+	if edits[0].ThumbnailTime == nil || *edits[0].ThumbnailTime != 3 || edits[0].EditorEmail != admin || !strings.Contains(edits[0].ThumbnailURL, strings.TrimPrefix(keys[1], video.Filename+"/thumbnails/")) {
+		t.Errorf("upload edit = %+v", edits[0])
+	}
+	if edits[1].ThumbnailTime == nil || *edits[1].ThumbnailTime != 0 || !strings.Contains(edits[1].ThumbnailURL, keys[0]) {
+		t.Errorf("poster frame edit = %+v", edits[1])
+	}
+	problem(t, e.send(http.MethodPost, path, token("support@mail.com", "SupportUser"), map[string]any{"time": 1}), http.StatusForbidden, apperrors.CodeForbidden)
+}
@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"video-service/apperrors"
+	"video-service/logging"
+	"video-service/media"
+	"video-service/metrics"
+	"video-service/models"
+	"video-service/storage"
+	"video-service/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HLS streaming: processing transcodes every video into the renditions of
+// media.Ladder under <filename>/hls/ in the bucket. The API serves the
+// playlists and rewrites the segment names into presigned URLs, so the
+// segments stay private.
+
+const hlsContentType = "application/vnd.apple.mpegurl"
+
+// Segments uploaded in parallel after transcoding.
+const segmentUploads = 8
+
+func hlsKey(filename string, elem ...string) string {
+	return path.Join(append([]string{filename, "hls"}, elem...)...)
+}
+
+// transcode writes the HLS renditions of video, read from input, to the
+// bucket.
+func (v *VideoController) transcode(ctx context.Context, video *models.Video, input string, source *media.Source) error {
+	logger := logging.FromContext(ctx).With("video_id", video.ID)
+	renditions := media.LadderFor(source)
+
+	dir, err := os.MkdirTemp("", video.Filename+"-hls-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Error("failed to delete HLS files", "error", err)
+		}
+	}()
+
+	transcodeCtx, span := tracing.Start(ctx, "ffmpeg hls", trace.WithAttributes(attribute.Int("hls.renditions", len(renditions))))
+	start := time.Now()
+	err = media.TranscodeHLS(transcodeCtx, input, dir, source, renditions)
+	tracing.End(span, err)
+	if err != nil {
+		return err
+	}
+	metrics.TranscodeDuration.Observe(time.Since(start).Seconds())
+	logger.Info("video transcoded", "width", source.Width, "height", source.Height, "renditions", len(renditions))
+
+	return v.uploadDir(ctx, dir, hlsKey(video.Filename))
+}
+
+// uploadDir copies the files below dir to the bucket under prefix.
+func (v *VideoController) uploadDir(ctx context.Context, dir, prefix string) error {
+	var files []string
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files = append(files, file)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	uploadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan string)
+	errs := make(chan error, segmentUploads)
+	var wg sync.WaitGroup
+	for i := 0; i < segmentUploads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				rel, _ := filepath.Rel(dir, file)
+				if err := v.uploadFile(uploadCtx, file, path.Join(prefix, filepath.ToSlash(rel))); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+	for _, file := range files {
+		select {
+		case jobs <- file:
+		case <-uploadCtx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (v *VideoController) uploadFile(ctx context.Context, file, key string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	contentType := "video/mp2t"
+	switch path.Ext(key) {
+	case ".m3u8":
+		contentType = hlsContentType
+	case ".mp4":
+		contentType = "video/mp4"
+	case ".vtt":
+		contentType = vttContentType
+	case ".jpg":
+		contentType = "image/jpeg"
+	}
+	return v.bucket.PutObject(ctx, key, f, contentType)
+}
+
+// MasterPlaylist serves the HLS master playlist. Its rendition URIs are
+// relative, so players resolve them to RenditionPlaylist.
+func (v *VideoController) MasterPlaylist(c *gin.Context) {
+	video, ok := v.findHLS(c)
+	if !ok {
+		return
+	}
+	playlist, ok := v.readObject(c, hlsKey(video.Filename, media.MasterPlaylist), "playlist")
+	if !ok {
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, hlsContentType, playlist)
+}
+
+// RenditionPlaylist serves the media playlist of one rendition, with each
+// segment replaced by a presigned URL.
+func (v *VideoController) RenditionPlaylist(c *gin.Context) {
+	rendition := c.Param("rendition")
+	if !media.IsRendition(rendition) {
+		apperrors.Abort(c, apperrors.NotFound("rendition"))
+		return
+	}
+	video, ok := v.findHLS(c)
+	if !ok {
+		return
+	}
+	playlist, ok := v.readObject(c, hlsKey(video.Filename, rendition, media.MediaPlaylist), "playlist")
+	if !ok {
+		return
+	}
+
+	lines := strings.Split(string(playlist), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		url, err := v.bucket.PresignGetObject(c.Request.Context(), hlsKey(video.Filename, rendition, path.Base(line)), v.settings.Media.StreamURLTTL)
+		if err != nil {
+			apperrors.Abort(c, apperrors.Internal(err))
+			return
+		}
+		lines[i] = url
+	}
+	// Players must not keep the playlist longer than its URLs work
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(int(v.settings.Media.StreamURLTTL.Seconds())/2))
+	c.Data(http.StatusOK, hlsContentType, []byte(strings.Join(lines, "\n")))
+}
+
+// findHLS loads the :id video, which must be ready and transcoded.
+func (v *VideoController) findHLS(c *gin.Context) (*models.Video, bool) {
+	video, ok := v.find(c)
+	if !ok {
+		return nil, false
+	}
+	if !video.HLS {
+		apperrors.Abort(c, apperrors.NotFound("playlist"))
+		return nil, false
+	}
+	return video, true
+}
+
+// readObject reads key, aborting with 404 for resource when it does not
+// exist, e.g. for a rendition above the source resolution.
+func (v *VideoController) readObject(c *gin.Context, key, resource string) ([]byte, bool) {
+	body, err := v.bucket.GetObject(c.Request.Context(), key)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		apperrors.Abort(c, apperrors.NotFound(resource).Wrap(err))
+		return nil, false
+	}
+	if err != nil {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return nil, false
+	}
+	defer body.Close()
+	playlist, err := io.ReadAll(body)
+	if err != nil {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return nil, false
+	}
+	return playlist, true
+}
+
+// stream tells players where to load video from: the HLS master playlist,
+// or the .mp4 of videos processed before HLS.
+func (v *VideoController) stream(c *gin.Context, video *models.Video) (models.StreamDTO, error) {
+	if !video.HLS {
+		return v.progressiveStream(c, video)
+	}
+	return models.StreamDTO{URL: v.videoURL(c, video, "hls", media.MasterPlaylist), Format: "hls"}, nil
+}
+
+// progressiveStream points at the .mp4, which processing writes for every
+// video, HLS or not.
+func (v *VideoController) progressiveStream(c *gin.Context, video *models.Video) (models.StreamDTO, error) {
+	url, err := v.presignedURL(c.Request.Context(), video.Filename+".mp4")
+	return models.StreamDTO{URL: url, Format: "mp4"}, err
+}
+
+// videoURL is the public URL of a route below the v2 video.
+func (v *VideoController) videoURL(c *gin.Context, video *models.Video, elem ...string) string {
+	return v.publicURL(c) + path.Join(append([]string{"/api/v2/videos", strconv.FormatUint(uint64(video.ID), 10)}, elem...)...)
+}
+
+// publicURL is PUBLIC_API_URL, or else the scheme and host the request
+// came in on.
+func (v *VideoController) publicURL(c *gin.Context) string {
+	if v.settings.Media.PublicURL != "" {
+		return strings.TrimSuffix(v.settings.Media.PublicURL, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
@@ -0,0 +1,80 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"video-service/apperrors"
+	"video-service/models"
+)
+
+func TestStreamIsTheMasterPlaylist(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	want := "http://example.com" + videoPath(video.ID, "hls", "master.m3u8")
+	stream := decode[models.StreamDTO](t, e.get(videoPath(video.ID, "stream"), ""), http.StatusOK)
+	if stream.Format != "hls" || stream.URL != want {
+		t.Fatalf("stream = %+v", stream)
+	}
+	// v1 players cannot play HLS, so v1 keeps returning the .mp4
+	stream = decode[models.StreamDTO](t, e.get("/api/videos/video-stream/"+video.Filename, ""), http.StatusOK)
+	if stream.Format != "mp4" || !strings.Contains(stream.URL, video.Filename+".mp4") {
+		t.Fatalf("v1 stream = %+v", stream)
+	}
+
+	// Videos processed before HLS keep streaming their .mp4
+	if err := e.db.Model(&models.Video{}).Where("id = ?", video.ID).Update("hls", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	stream = decode[models.StreamDTO](t, e.get(videoPath(video.ID, "stream"), ""), http.StatusOK)
+	if stream.Format != "mp4" || !strings.Contains(stream.URL, video.Filename+".mp4") {
+		t.Fatalf("stream = %+v", stream)
+	}
+	problem(t, e.get(videoPath(video.ID, "hls", "master.m3u8"), ""), http.StatusNotFound, apperrors.CodeNotFound)
+}
+
+func TestHLSPlaylists(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+
+	// A 720p source gets the rungs up to 720p
+	rec := e.get(videoPath(video.ID, "hls", "master.m3u8"), "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/vnd.apple.mpegurl" {
+		t.Fatalf("master: %d %v", rec.Code, rec.Header())
+	}
+	master := rec.Body.String()
+	for _, rendition := range []string{"240p", "360p", "480p", "720p"} {
+		if !strings.Contains(master, rendition+"/index.m3u8") {
+			t.Errorf("master playlist without %s:\n%s", rendition, master)
+		}
+	}
+	if strings.Contains(master, "1080p") {
+		t.Errorf("master playlist with a rung above the source:\n%s", master)
+	}
+
+	// Segments become presigned URLs
+	rec = e.get(videoPath(video.ID, "hls", "720p", "index.m3u8"), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rendition: %d %s", rec.Code, rec.Body)
+	}
+	if playlist := rec.Body.String(); strings.Contains(playlist, "\nsegment_000.ts") ||
+		!strings.Contains(playlist, "https://memory.invalid/"+video.Filename+"%2Fhls%2F720p%2Fsegment_000.ts") {
+		t.Fatalf("rendition playlist:\n%s", playlist)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Cache-Control"), "private") {
+		t.Errorf("Cache-Control = %q", rec.Header().Get("Cache-Control"))
+	}
+	problem(t, e.get(videoPath(video.ID, "hls", "1080p", "index.m3u8"), ""), http.StatusNotFound, apperrors.CodeNotFound)
+	// The OpenAPI document lists the rungs
+	problem(t, e.get(videoPath(video.ID, "hls", "4k", "index.m3u8"), ""), http.StatusBadRequest, apperrors.CodeValidationFailed)
+}
+
+func TestHLSForSmallSources(t *testing.T) {
+	e := newEnv(t)
+	t.Setenv("FAKE_SIZE", "320x179")
+	video := e.upload("Cats", "")
+	// Below the lowest rung the source keeps its own size
+	if master := e.get(videoPath(video.ID, "hls", "master.m3u8"), "").Body.String(); !strings.Contains(master, "240p/index.m3u8") || strings.Contains(master, "360p") {
+		t.Fatalf("master playlist:\n%s", master)
+	}
+}
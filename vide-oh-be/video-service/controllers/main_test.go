@@ -0,0 +1,261 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"video-service/apperrors"
+	"video-service/config"
+	"video-service/controllers"
+	"video-service/media"
+	"video-service/models"
+	"video-service/openapi"
+	"video-service/repository"
+	"video-service/router"
+	"video-service/storage"
+	"video-service/utils"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The tests drive the router the Lambda serves, with the OpenAPI document
+// enforced on every request and response, against SQLite, the in-memory
+// bucket and the stand-in for ffmpeg in testdata.
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	openapi.SetMode(openapi.ModeEnforce)
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		panic(err)
+	}
+	media.Binary = filepath.Join(testdata, "ffmpeg")
+	media.ProbeBinary = filepath.Join(testdata, "ffprobe")
+	os.Exit(m.Run())
+}
+
+const (
+	owner = "ana@mail.com"
+	other = "bob@mail.com"
+	admin = "admin@mail.com"
+)
+
+var (
+	ownerToken = token(owner, "RegisteredUser")
+	otherToken = token(other, "RegisteredUser")
+	adminToken = token(admin, "Administrator")
+)
+
+// token signs claims with a throwaway key; without an authorizer the
+// handlers only read them.
+func token(email, role string) string {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, utils.JWTClaim{Email: email, Role: role}).SignedString([]byte("test"))
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+type env struct {
+	t      *testing.T
+	db     *gorm.DB
+	bucket *storage.Memory
+	events *recorder
+	// settings are those of videos
+	settings controllers.Settings
+	videos   *controllers.VideoController
+	router   *gin.Engine
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "videos.db")), &gorm.Config{
+		TranslateError: true,
+		NowFunc:        func() time.Time { return time.Now().Truncate(time.Microsecond) },
+		Logger:         logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Video{}, &models.UploadSession{}, &models.VideoEdit{}, &models.VideoReport{}); err != nil {
+		t.Fatal(err)
+	}
+	e := &env{t: t, db: db, bucket: storage.NewMemory(), events: &recorder{}}
+	e.settings = controllers.Settings{
+		S3: config.S3{
+			PresignTTL:       15 * time.Minute,
+			MaxUploadSize:    20 << 20,
+			UploadSessionTTL: 24 * time.Hour,
+		},
+		Media: config.Media{
+			MaxVideoDuration: 10 * time.Minute,
+			MaxVideoWidth:    3840,
+			MaxVideoHeight:   2160,
+			// The tests process uploads themselves, like the S3 event would
+			Processing:   config.ProcessingEvents,
+			StreamURLTTL: 4 * time.Hour,
+		},
+		Search:     config.Search{Language: "english"},
+		Moderation: config.Moderation{DeletedRetention: 720 * time.Hour, ReportHideThreshold: 3},
+	}
+	e.videos = controllers.NewVideoController(repository.New(db), e.bucket, e.events, e.settings)
+	e.router = router.New(nil, e.videos)
+	return e
+}
+
+// do sends a request as the user of token, if any. headers are name,
+// value pairs.
+func (e *env) do(method, path, token string, contentType string, body io.Reader, headers ...string) *httptest.ResponseRecorder {
+	e.t.Helper()
+	req := httptest.NewRequest(method, path, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (e *env) get(path, token string, headers ...string) *httptest.ResponseRecorder {
+	e.t.Helper()
+	return e.do(http.MethodGet, path, token, "", nil, headers...)
+}
+
+// send sends value as the JSON body.
+func (e *env) send(method, path, token string, value any, headers ...string) *httptest.ResponseRecorder {
+	e.t.Helper()
+	body, err := json.Marshal(value)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return e.do(method, path, token, "application/json", bytes.NewReader(body), headers...)
+}
+
+// mp4 starts like an MP4 file, which is all the upload sniffs.
+var mp4 = []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2mp41")
+
+// form builds a multipart body with content as the file field, uploaded
+// as filename, and the other fields as name, value pairs.
+func form(t *testing.T, field, filename string, content []byte, fields ...string) (string, io.Reader) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	for i := 0; i+1 < len(fields); i += 2 {
+		w.WriteField(fields[i], fields[i+1])
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return w.FormDataContentType(), &body
+}
+
+// upload creates a video of the owner through the v2 API and processes it,
+// and returns it as it is served once ready.
+func (e *env) upload(title, description string) models.VideoSearchResultDTO {
+	e.t.Helper()
+	contentType, body := form(e.t, "file", "cats.mp4", mp4, "title", title, "description", description)
+	rec := e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body)
+	created := decode[models.VideoSearchResultDTO](e.t, rec, http.StatusCreated)
+	e.process(created.Filename)
+	return decode[models.VideoSearchResultDTO](e.t, e.get(videoPath(created.ID), ""), http.StatusOK)
+}
+
+// process handles the S3 event of the stored upload.
+func (e *env) process(filename string) {
+	e.t.Helper()
+	if err := e.videos.ProcessObject(context.Background(), "originals/"+filename); err != nil {
+		e.t.Fatalf("processing %s: %v", filename, err)
+	}
+}
+
+func (e *env) video(id uint) models.Video {
+	e.t.Helper()
+	var video models.Video
+	if err := e.db.Unscoped().First(&video, id).Error; err != nil {
+		e.t.Fatal(err)
+	}
+	return video
+}
+
+func videoPath(id uint, rest ...string) string {
+	path := "/api/v2/videos/" + strconv.FormatUint(uint64(id), 10)
+	for _, segment := range rest {
+		path += "/" + segment
+	}
+	return path
+}
+
+// decode checks the status of rec and decodes its JSON body.
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder, status int) T {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, status, rec.Body)
+	}
+	var value T
+	if err := json.Unmarshal(rec.Body.Bytes(), &value); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	return value
+}
+
+// problem checks that rec is a problem document with status and code.
+func problem(t *testing.T, rec *httptest.ResponseRecorder, status int, code apperrors.Code) apperrors.Problem {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != apperrors.ContentType {
+		t.Fatalf("Content-Type = %q, want %s: %s", ct, apperrors.ContentType, rec.Body)
+	}
+	p := decode[apperrors.Problem](t, rec, status)
+	if p.Status != status || p.Code != code || p.Type != apperrors.TypeURI(code) {
+		t.Fatalf("problem = %+v, want %d %s", p, status, code)
+	}
+	return p
+}
+
+// recorder is an eventbus.Publisher that keeps the events.
+type recorder struct {
+	mu     sync.Mutex
+	events []event
+}
+
+type event struct {
+	detailType string
+	detail     any
+}
+
+func (r *recorder) Publish(ctx context.Context, detailType string, detail any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event{detailType, detail})
+	return nil
+}
+
+func (r *recorder) published() []event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]event(nil), r.events...)
+}
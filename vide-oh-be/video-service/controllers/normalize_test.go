@@ -0,0 +1,67 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"video-service/apperrors"
+	"video-service/models"
+)
+
+// webm starts like a WebM file: the EBML magic and the DocType.
+var webm = []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\xf7\x81\x01\x42\x82\x84webm")
+
+func TestUploadOtherContainers(t *testing.T) {
+	e := newEnv(t)
+	t.Setenv("FAKE_PROBE", "webm")
+	contentType, body := form(t, "file", "clip.bin", webm, "title", "Clip")
+	created := decode[models.VideoSearchResultDTO](t, e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body), http.StatusCreated)
+	if original, ok := e.bucket.Object("originals/" + created.Filename); !ok || original.ContentType != "video/webm" {
+		t.Fatalf("original = %+v, %v", original, ok)
+	}
+
+	e.process(created.Filename)
+	if normalized, ok := e.bucket.Object(created.Filename + ".mp4"); !ok || normalized.ContentType != "video/mp4" {
+		t.Fatalf("normalized = %+v, %v", normalized, ok)
+	}
+	if video := e.video(created.ID); video.Status != models.StatusReady || video.VideoCodec != "vp9" {
+		t.Fatalf("video = %+v", video)
+	}
+}
+
+func TestDirectUploadChecksTheContainer(t *testing.T) {
+	e := newEnv(t)
+	t.Setenv("FAKE_PROBE", "avi")
+	_, sessionPath := e.createUpload(len(mp4))
+	parts := decode[struct {
+		Parts []models.UploadPartURL `json:"parts"`
+	}](t, e.get(sessionPath+"/parts", ownerToken), http.StatusOK).Parts
+	etag, err := e.putPart(parts[0].URL, mp4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problem(t, e.send(http.MethodPost, sessionPath+"/complete", ownerToken, completion(etag)), http.StatusUnsupportedMediaType, apperrors.CodeUnsupportedMediaType)
+}
+
+func TestKeepOriginal(t *testing.T) {
+	e := newEnv(t)
+	contentType, body := form(t, "file", "cats.mp4", mp4, "title", "Cats", "keepOriginal", "true")
+	kept := decode[models.VideoSearchResultDTO](t, e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body), http.StatusCreated)
+	e.process(kept.Filename)
+	if _, ok := e.bucket.Object("originals/" + kept.Filename); !ok {
+		t.Fatal("the original was deleted")
+	}
+
+	original := decode[map[string]any](t, e.get(videoPath(kept.ID, "original"), ownerToken), http.StatusOK)
+	if url, _ := original["url"].(string); !strings.Contains(url, "originals%2F"+kept.Filename) {
+		t.Fatalf("original = %v", original)
+	}
+	problem(t, e.get(videoPath(kept.ID, "original"), otherToken), http.StatusForbidden, apperrors.CodeForbidden)
+	if rec := e.get(videoPath(kept.ID, "original"), adminToken); rec.Code != http.StatusOK {
+		t.Fatalf("administrator: status %d", rec.Code)
+	}
+
+	// Without keepOriginal there is nothing to download
+	video := e.upload("Dogs", "")
+	problem(t, e.get(videoPath(video.ID, "original"), ownerToken), http.StatusNotFound, apperrors.CodeNotFound)
+}
@@ -0,0 +1,127 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"video-service/apperrors"
+	"video-service/media"
+	"video-service/models"
+)
+
+func TestUploadMetadata(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	want := models.VideoMetadata{
+		Duration: 12.48, Width: 1280, Height: 720, VideoCodec: "h264", AudioCodec: "aac",
+		Bitrate: 2500000, FrameRate: 29.97,
+	}
+	if video.VideoMetadata != want {
+		t.Fatalf("metadata = %+v, want %+v", video.VideoMetadata, want)
+	}
+}
+
+func TestUploadMetadataOfRotatedVideos(t *testing.T) {
+	e := newEnv(t)
+	t.Setenv("FAKE_PROBE", "rotated")
+	// A phone video: stored landscape, displayed portrait
+	video := e.upload("Cats", "")
+	if video.Rotation != 90 || video.Width != 1080 || video.Height != 1920 {
+		t.Fatalf("metadata = %+v", video.VideoMetadata)
+	}
+}
+
+func TestUploadRejections(t *testing.T) {
+	tests := []struct {
+		probe, size string
+		code        apperrors.Code
+	}{
+		{"junk", "", apperrors.CodeInvalidVideo},
+		{"image", "", apperrors.CodeInvalidVideo},
+		{"long", "", apperrors.CodeVideoTooLong},
+		{"", "7680x4320", apperrors.CodeResolutionTooHigh},
+		{"", "2160x4096", apperrors.CodeResolutionTooHigh},
+	}
+	for _, tt := range tests {
+		t.Run(tt.probe+tt.size, func(t *testing.T) {
+			e := newEnv(t)
+			t.Setenv("FAKE_PROBE", tt.probe)
+			t.Setenv("FAKE_SIZE", tt.size)
+			contentType, body := form(t, "file", "cats.mp4", mp4, "title", "Cats")
+			p := problem(t, e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body), http.StatusUnprocessableEntity, tt.code)
+
+			// The video is failed with the same message
+			var video models.Video
+			if err := e.db.Last(&video).Error; err != nil {
+				t.Fatal(err)
+			}
+			if video.Status != models.StatusFailed || video.FailureReason != p.Detail {
+				t.Fatalf("video = %+v", video)
+			}
+		})
+	}
+}
+
+func TestUploadAcceptsPortraitVideos(t *testing.T) {
+	e := newEnv(t)
+	t.Setenv("FAKE_SIZE", "2160x3840")
+	if video := e.upload("Cats", ""); video.Width != 2160 || video.Height != 3840 {
+		t.Fatalf("metadata = %+v", video.VideoMetadata)
+	}
+}
+
+func TestUploadLeavesUnreachableFilesToProcessing(t *testing.T) {
+	e := newEnv(t)
+	t.Setenv("FAKE_PROBE", "unreachable")
+	contentType, body := form(t, "file", "cats.mp4", mp4, "title", "Cats")
+	created := decode[models.VideoSearchResultDTO](t, e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body), http.StatusCreated)
+	if created.Status != models.StatusUploaded || created.Duration != 0 {
+		t.Fatalf("created = %+v", created)
+	}
+
+	// Processing repeats the check
+	t.Setenv("FAKE_PROBE", "long")
+	e.process(created.Filename)
+	if video := e.video(created.ID); video.Status != models.StatusFailed || video.FailureReason == "" {
+		t.Fatalf("video = %+v", video)
+	}
+}
+
+// A missing ffprobe says nothing about the file, so the upload stands and
+// processing is retried.
+func TestUploadWithoutFFprobe(t *testing.T) {
+	e := newEnv(t)
+	probe := media.ProbeBinary
+	media.ProbeBinary = filepath.Join(t.TempDir(), "missing")
+	t.Cleanup(func() { media.ProbeBinary = probe })
+
+	contentType, body := form(t, "file", "cats.mp4", mp4, "title", "Cats")
+	created := decode[models.VideoSearchResultDTO](t, e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body), http.StatusCreated)
+	if err := e.videos.ProcessObject(context.Background(), "originals/"+created.Filename); err == nil {
+		t.Fatal("processing succeeded without ffprobe")
+	}
+	if status := e.video(created.ID).Status; status != models.StatusUploaded {
+		t.Fatalf("status = %s", status)
+	}
+
+	media.ProbeBinary = probe
+	e.process(created.Filename)
+	if status := e.video(created.ID).Status; status != models.StatusReady {
+		t.Fatalf("status after the retry = %s", status)
+	}
+}
+
+func TestDirectUploadIsProbed(t *testing.T) {
+	e := newEnv(t)
+	t.Setenv("FAKE_PROBE", "long")
+	_, sessionPath := e.createUpload(len(mp4))
+	parts := decode[struct {
+		Parts []models.UploadPartURL `json:"parts"`
+	}](t, e.get(sessionPath+"/parts", ownerToken), http.StatusOK).Parts
+	etag, err := e.putPart(parts[0].URL, mp4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problem(t, e.send(http.MethodPost, sessionPath+"/complete", ownerToken, completion(etag)), http.StatusUnprocessableEntity, apperrors.CodeVideoTooLong)
+}
@@ -0,0 +1,420 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"video-service/apperrors"
+	"video-service/config"
+	"video-service/logging"
+	"video-service/media"
+	"video-service/metrics"
+	"video-service/models"
+	"video-service/tracing"
+	"video-service/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// Video processing: an upload creates the video as uploaded and stores the
+// file under originals/. The S3 ObjectCreated event, or the upload itself
+// with VIDEO_PROCESSING=inline, then probes the file, converts it to the
+// canonical .mp4, extracts the thumbnail, transcodes the HLS renditions and
+// marks the video ready or failed.
+
+// Videos still uploaded or processing this long after their last change
+// are failed by the scheduled cleanup; their event was lost or the Lambda
+// processing them hit its 15 minute limit.
+const processingTimeout = 20 * time.Minute
+
+// Long-polling the status: the longest wait, below the 29s API Gateway
+// timeout, and how often the database is checked meanwhile.
+const (
+	maxStatusWait      = 20 * time.Second
+	statusPollInterval = time.Second
+)
+
+// Upload requests give ffprobe this long, well within the API Gateway
+// timeout, before leaving the check to processing.
+const uploadProbeTimeout = 10 * time.Second
+
+// errUnknownObject fails the S3 event, so Lambda retries it. That covers
+// an event that arrives before the completed upload has committed its row.
+var errUnknownObject = errors.New("no video for the uploaded object")
+
+// ProcessObject processes the video stored under key. It is called for
+// every S3 ObjectCreated record; repeated deliveries are ignored.
+func (v *VideoController) ProcessObject(ctx context.Context, key string) error {
+	// Processing writes the .mp4, thumbnail and renditions to the same
+	// bucket
+	filename, ok := strings.CutPrefix(key, originalsPrefix)
+	if !ok {
+		return nil
+	}
+	video, err := v.repos.Videos.ByFilename(ctx, filename)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s", errUnknownObject, key)
+	}
+	if err != nil {
+		return err
+	}
+	return v.process(ctx, video)
+}
+
+func (v *VideoController) process(ctx context.Context, video *models.Video) error {
+	logger := logging.FromContext(ctx).With("video_id", video.ID)
+	ok, err := v.repos.Videos.Transition(ctx, video.ID, models.StatusUploaded, models.StatusProcessing, "")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		logger.Info("video is not waiting for processing, skipping")
+		return nil
+	}
+
+	if err := v.convert(ctx, video); err != nil {
+		// Rejected videos are already failed; this is an infrastructure
+		// error, which must not leave the video stuck in processing
+		v.release(context.WithoutCancel(ctx), video.ID, err)
+		return err
+	}
+	return nil
+}
+
+// convert runs the processing steps of a video in processing. A video that
+// is rejected or cannot be converted is failed and nil returned; errors are
+// left to process.
+func (v *VideoController) convert(ctx context.Context, video *models.Video) error {
+	logger := logging.FromContext(ctx).With("video_id", video.ID)
+	start := time.Now()
+
+	// ffmpeg reads the objects through presigned URLs, valid for as long as
+	// processing may take
+	original, err := v.bucket.PresignGetObject(ctx, originalKey(video.Filename), processingTimeout)
+	if err != nil {
+		return err
+	}
+	source, err := v.inspect(ctx, original)
+	var rejected *apperrors.Error
+	if errors.As(err, &rejected) {
+		return v.fail(ctx, video.ID, models.StatusProcessing, rejected.Detail)
+	}
+	if err != nil {
+		return fmt.Errorf("probing: %w", err)
+	}
+	if err := v.repos.Videos.SetMetadata(ctx, video.ID, toVideoMetadata(source)); err != nil {
+		return err
+	}
+
+	if err := v.normalize(ctx, video, original, source); err != nil {
+		logger.Error("normalization failed", "error", err)
+		return v.fail(ctx, video.ID, models.StatusProcessing, "the video could not be converted to MP4")
+	}
+	input, err := v.bucket.PresignGetObject(ctx, video.Filename+".mp4", processingTimeout)
+	if err != nil {
+		return err
+	}
+
+	if err := v.generateThumbnail(ctx, input, video.Filename, 0); err != nil {
+		metrics.ThumbnailFailures.Inc()
+		return v.fail(ctx, video.ID, models.StatusProcessing, "the thumbnail could not be extracted; the file may not be a valid video")
+	}
+	// Players do without a storyboard, so the video is served anyway
+	if err := v.generateStoryboard(ctx, video, input, source); err != nil {
+		logger.Error("storyboard generation failed", "error", err)
+	}
+	if err := v.transcode(ctx, video, input, source); err != nil {
+		logger.Error("transcoding failed", "error", err)
+		return v.fail(ctx, video.ID, models.StatusProcessing, "the video could not be transcoded")
+	}
+	if err := v.repos.Videos.SetHLS(ctx, video.ID, true); err != nil {
+		return err
+	}
+
+	ok, err := v.repos.Videos.Transition(ctx, video.ID, models.StatusProcessing, models.StatusReady, "")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// The cleanup failed it meanwhile
+		return nil
+	}
+	logger.Info("video ready")
+	if !video.KeepOriginal {
+		if err := v.bucket.DeleteObject(ctx, originalKey(video.Filename)); err != nil {
+			logger.Error("failed to delete the original", "error", err)
+		}
+	}
+	metrics.ProcessedVideos.Inc(models.StatusReady)
+	metrics.ProcessingDuration.Observe(time.Since(start).Seconds())
+	return nil
+}
+
+// release undoes the processing transition after cause interrupted it. S3
+// events are retried by Lambda, so the video goes back to uploaded for the
+// next attempt; inline processing has no retry and fails it.
+func (v *VideoController) release(ctx context.Context, id uint, cause error) {
+	logger := logging.FromContext(ctx).With("video_id", id)
+	logger.Error("processing interrupted", "error", cause)
+	if v.settings.Media.Processing == config.ProcessingInline {
+		if err := v.fail(ctx, id, models.StatusProcessing, "processing was interrupted; upload the video again"); err != nil {
+			logger.Error("failed to mark video as failed", "error", err)
+		}
+		return
+	}
+	if _, err := v.repos.Videos.Transition(ctx, id, models.StatusProcessing, models.StatusUploaded, ""); err != nil {
+		logger.Error("failed to return video to uploaded", "error", err)
+	}
+}
+
+// normalize writes the canonical <filename>.mp4 of video from input.
+func (v *VideoController) normalize(ctx context.Context, video *models.Video, input string, source *media.Source) error {
+	logger := logging.FromContext(ctx).With("video_id", video.ID)
+	file, err := os.CreateTemp("", video.Filename+"-*.mp4")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	defer func() {
+		if err := os.Remove(file.Name()); err != nil {
+			logger.Error("failed to delete the normalized file", "error", err)
+		}
+	}()
+
+	mode := "transcode"
+	if source.Remux() {
+		mode = "remux"
+	}
+	normalizeCtx, span := tracing.Start(ctx, "ffmpeg normalize", trace.WithAttributes(attribute.String("normalize.mode", mode)))
+	start := time.Now()
+	err = media.Normalize(normalizeCtx, input, file.Name(), source)
+	tracing.End(span, err)
+	if err != nil {
+		return err
+	}
+	metrics.NormalizeDuration.Observe(time.Since(start).Seconds(), mode)
+	logger.Info("video normalized", "format", source.Format, "video_codec", source.VideoCodec, "audio_codec", source.AudioCodec, "mode", mode)
+
+	return v.uploadFile(ctx, file.Name(), video.Filename+".mp4")
+}
+
+// inspect probes input and checks it against the configured limits.
+// Rejections are returned as *apperrors.Error; other errors mean input
+// could not be read.
+func (v *VideoController) inspect(ctx context.Context, input string) (*media.Source, error) {
+	probeCtx, span := tracing.Start(ctx, "ffprobe")
+	source, err := media.Probe(probeCtx, input)
+	tracing.End(span, err)
+	if errors.Is(err, media.ErrNotVideo) {
+		return nil, apperrors.ErrInvalidVideo.Wrap(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !source.Supported() {
+		return nil, apperrors.ErrUnsupportedMediaType
+	}
+	if source.Duration > v.settings.Media.MaxVideoDuration {
+		return nil, apperrors.VideoTooLong(v.settings.Media.MaxVideoDuration)
+	}
+	if source.LongSide() > v.settings.Media.MaxVideoWidth || source.ShortSide() > v.settings.Media.MaxVideoHeight {
+		return nil, apperrors.ResolutionTooHigh(v.settings.Media.MaxVideoWidth, v.settings.Media.MaxVideoHeight)
+	}
+	return source, nil
+}
+
+// checkUpload probes a stored upload and records its metadata, so a file
+// that is not a video or exceeds the limits is rejected by the upload
+// request itself. Uploads that cannot be probed yet are left to
+// processing. It aborts the request
+// and returns false on rejection.
+func (v *VideoController) checkUpload(c *gin.Context, video *models.Video) bool {
+	ctx := c.Request.Context()
+	input, err := v.presignedURL(ctx, originalKey(video.Filename))
+	var source *media.Source
+	if err == nil {
+		probeCtx, cancel := context.WithTimeout(ctx, uploadProbeTimeout)
+		source, err = v.inspect(probeCtx, input)
+		cancel()
+	}
+	var rejected *apperrors.Error
+	if !errors.As(err, &rejected) {
+		if err == nil {
+			video.VideoMetadata = toVideoMetadata(source)
+			err = v.repos.Videos.SetMetadata(ctx, video.ID, video.VideoMetadata)
+		}
+		if err != nil {
+			logging.FromContext(ctx).Warn("could not probe the upload", "video_id", video.ID, "error", err)
+		}
+		return true
+	}
+	metrics.Uploads.Inc("rejected")
+	if err := v.fail(ctx, video.ID, models.StatusUploaded, rejected.Detail); err != nil {
+		logging.FromContext(ctx).Error("failed to mark video as failed", "video_id", video.ID, "error", err)
+	}
+	apperrors.Abort(c, rejected)
+	return false
+}
+
+func toVideoMetadata(source *media.Source) models.VideoMetadata {
+	return models.VideoMetadata{
+		Duration:   source.Duration.Seconds(),
+		Width:      source.Width,
+		Height:     source.Height,
+		VideoCodec: source.VideoCodec,
+		AudioCodec: source.AudioCodec,
+		Bitrate:    source.Bitrate,
+		FrameRate:  source.FrameRate,
+		Rotation:   source.Rotation,
+	}
+}
+
+// processInline starts processing right away when no S3 event will.
+func (v *VideoController) processInline(ctx context.Context, video models.Video) {
+	if v.settings.Media.Processing != config.ProcessingInline {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := v.process(ctx, &video); err != nil {
+			logging.FromContext(ctx).Error("video processing failed", "video_id", video.ID, "error", err)
+		}
+	}()
+}
+
+// fail records reason on a video that is still in status from.
+func (v *VideoController) fail(ctx context.Context, id uint, from, reason string) error {
+	ok, err := v.repos.Videos.Transition(ctx, id, from, models.StatusFailed, reason)
+	if err != nil {
+		return err
+	}
+	if ok {
+		logging.FromContext(ctx).Warn("video processing failed", "video_id", id, "reason", reason)
+		metrics.ProcessedVideos.Inc(models.StatusFailed)
+	}
+	return nil
+}
+
+// failUnfinished fails the videos that stayed uploaded or processing past
+// processingTimeout, and returns how many there were.
+func (v *VideoController) failUnfinished(ctx context.Context, now time.Time) (int, error) {
+	unfinished, err := v.repos.Videos.Unfinished(ctx, now.Add(-processingTimeout))
+	if err != nil {
+		return 0, err
+	}
+	for _, video := range unfinished {
+		if err := v.fail(ctx, video.ID, video.Status, "processing timed out"); err != nil {
+			return 0, err
+		}
+	}
+	return len(unfinished), nil
+}
+
+// GetVideoStatus reports the processing state to the owner, administrators
+// and support. With ?wait=<seconds> the response is held until the status
+// changes, for up to 20 seconds; finished videos answer right away.
+func (v *VideoController) GetVideoStatus(c *gin.Context) {
+	id, ok := videoID(c)
+	if !ok {
+		return
+	}
+	wait, err := queryInt(c, "wait", 0)
+	if err != nil || wait < 0 {
+		apperrors.Abort(c, apperrors.InvalidRequest("wait must be a number of seconds"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	video, err := v.repos.Videos.ByID(ctx, id)
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "video"))
+		return
+	}
+	_, claims := utils.GetTokenClaims(c)
+	if claims.Role == "RegisteredUser" && claims.Email != video.OwnerEmail {
+		apperrors.Abort(c, apperrors.Forbidden("you can only follow your own videos"))
+		return
+	}
+
+	if !video.Done() && wait > 0 {
+		timeout := min(time.Duration(wait)*time.Second, maxStatusWait)
+		if video, err = v.waitForStatus(ctx, video, timeout); err != nil {
+			apperrors.Abort(c, apperrors.FromDB(err, "video"))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, toVideoStatusDTO(video))
+}
+
+// GetOriginal presigns the uploaded file of a video uploaded with
+// keepOriginal, for the owner, administrators and support.
+func (v *VideoController) GetOriginal(c *gin.Context) {
+	id, ok := videoID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	video, err := v.repos.Videos.ByID(ctx, id)
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "video"))
+		return
+	}
+	_, claims := utils.GetTokenClaims(c)
+	if claims.Role == "RegisteredUser" && claims.Email != video.OwnerEmail {
+		apperrors.Abort(c, apperrors.Forbidden("you can only download the originals of your own videos"))
+		return
+	}
+	if !video.KeepOriginal {
+		apperrors.Abort(c, apperrors.NotFound("original"))
+		return
+	}
+
+	url, err := v.presignedURL(ctx, originalKey(video.Filename))
+	if err != nil {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": url, "expiresAt": time.Now().Add(v.settings.S3.PresignTTL)})
+}
+
+// waitForStatus polls until the status of video changes or timeout passes,
+// and returns the latest copy.
+func (v *VideoController) waitForStatus(ctx context.Context, video *models.Video, timeout time.Duration) (*models.Video, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(statusPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return video, nil
+		case <-ticker.C:
+			current, err := v.repos.Videos.ByID(ctx, uint64(video.ID))
+			if err != nil {
+				return nil, err
+			}
+			if current.Status != video.Status {
+				return current, nil
+			}
+		}
+	}
+}
+
+func toVideoStatusDTO(video *models.Video) models.VideoStatusDTO {
+	return models.VideoStatusDTO{
+		ID:            video.ID,
+		Status:        video.Status,
+		FailureReason: video.FailureReason,
+		KeepOriginal:  video.KeepOriginal,
+		UpdatedAt:     video.UpdatedAt,
+	}
+}
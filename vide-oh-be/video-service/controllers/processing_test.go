@@ -0,0 +1,133 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+	"video-service/apperrors"
+	"video-service/config"
+	"video-service/controllers"
+	"video-service/models"
+	"video-service/repository"
+	"video-service/storage"
+)
+
+// created uploads a video of the owner without processing it.
+func (e *env) created() models.VideoSearchResultDTO {
+	e.t.Helper()
+	contentType, body := form(e.t, "file", "cats.mp4", mp4, "title", "Cats")
+	return decode[models.VideoSearchResultDTO](e.t, e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body), http.StatusCreated)
+}
+
+func TestVideoStatus(t *testing.T) {
+	e := newEnv(t)
+	video := e.created()
+	path := videoPath(video.ID, "status")
+
+	status := decode[models.VideoStatusDTO](t, e.get(path, ownerToken), http.StatusOK)
+	if status.Status != models.StatusUploaded {
+		t.Fatalf("status = %+v", status)
+	}
+	problem(t, e.get(path, otherToken), http.StatusForbidden, apperrors.CodeForbidden)
+	problem(t, e.get(path+"?wait=x", ownerToken), http.StatusBadRequest, apperrors.CodeValidationFailed)
+
+	e.process(video.Filename)
+	// Finished videos answer right away, whatever the wait
+	start := time.Now()
+	status = decode[models.VideoStatusDTO](t, e.get(path+"?wait=20", adminToken), http.StatusOK)
+	if status.Status != models.StatusReady || time.Since(start) > 5*time.Second {
+		t.Fatalf("status = %+v after %s", status, time.Since(start))
+	}
+}
+
+func TestProcessingFailure(t *testing.T) {
+	e := newEnv(t)
+	t.Setenv("FAKE_FFMPEG", "fail")
+	video := e.created()
+	e.process(video.Filename)
+
+	status := decode[models.VideoStatusDTO](t, e.get(videoPath(video.ID, "status"), ownerToken), http.StatusOK)
+	if status.Status != models.StatusFailed || status.FailureReason == "" {
+		t.Fatalf("status = %+v", status)
+	}
+	problem(t, e.get(videoPath(video.ID), ""), http.StatusNotFound, apperrors.CodeNotFound)
+}
+
+func TestProcessObject(t *testing.T) {
+	e := newEnv(t)
+	video := e.created()
+	e.process(video.Filename)
+	updated := e.video(video.ID).UpdatedAt
+
+	// Repeated deliveries of the event are ignored
+	e.process(video.Filename)
+	if got := e.video(video.ID); got.Status != models.StatusReady || !got.UpdatedAt.Equal(updated) {
+		t.Fatalf("video = %+v", got)
+	}
+	// Only uploads are processed, not what processing writes
+	if err := e.videos.ProcessObject(context.Background(), video.Filename+".mp4"); err != nil {
+		t.Fatal(err)
+	}
+	// An event before the row commits is retried
+	if err := e.videos.ProcessObject(context.Background(), "originals/unknown"); err == nil {
+		t.Fatal("no error for an object without a video")
+	}
+}
+
+func TestCleanupFailsUnfinishedVideos(t *testing.T) {
+	e := newEnv(t)
+	stuck := e.created()
+	recent := e.created()
+	if err := e.db.Model(&models.Video{}).Where("id = ?", stuck.ID).UpdateColumn("updated_at", time.Now().Add(-time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.videos.CleanupUploads(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.video(stuck.ID); got.Status != models.StatusFailed || got.FailureReason != "processing timed out" {
+		t.Fatalf("stuck video = %+v", got)
+	}
+	if got := e.video(recent.ID); got.Status != models.StatusUploaded {
+		t.Fatalf("recent video = %+v", got)
+	}
+}
+
+// unreachable fails every presign, like S3 being down.
+type unreachable struct{ storage.Bucket }
+
+func (unreachable) PresignGetObject(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "", errors.New("S3 is unreachable")
+}
+
+func TestInterruptedProcessing(t *testing.T) {
+	e := newEnv(t)
+	video := e.created()
+	broken := controllers.NewVideoController(repository.New(e.db), unreachable{e.bucket}, e.events, e.settings)
+
+	// The S3 event is retried, so the video goes back for the next attempt
+	if err := broken.ProcessObject(context.Background(), "originals/"+video.Filename); err == nil {
+		t.Fatal("the interrupted processing succeeded")
+	}
+	if status := e.video(video.ID).Status; status != models.StatusUploaded {
+		t.Fatalf("status = %s", status)
+	}
+	e.process(video.Filename)
+	if status := e.video(video.ID).Status; status != models.StatusReady {
+		t.Fatalf("status after the retry = %s", status)
+	}
+
+	// Inline processing is not retried and fails the video
+	inline := e.settings
+	inline.Media.Processing = config.ProcessingInline
+	broken = controllers.NewVideoController(repository.New(e.db), unreachable{e.bucket}, e.events, inline)
+	video = e.created()
+	if err := broken.ProcessObject(context.Background(), "originals/"+video.Filename); err == nil {
+		t.Fatal("the interrupted processing succeeded")
+	}
+	if stored := e.video(video.ID); stored.Status != models.StatusFailed || stored.FailureReason == "" {
+		t.Fatalf("status %s, reason %q", stored.Status, stored.FailureReason)
+	}
+}
@@ -0,0 +1,323 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"video-service/apperrors"
+	"video-service/etag"
+	"video-service/logging"
+	"video-service/metrics"
+	"video-service/models"
+	"video-service/repository"
+	"video-service/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportRequest struct {
+	Category string `json:"category" binding:"required"`
+	Details  string `json:"details"`
+}
+
+type ReportResolution struct {
+	Status string `json:"status" binding:"required,oneof=dismissed actioned"`
+}
+
+// CreateReport files the user's report of a video. A user reports a video
+// once; a second report answers 409.
+func (v *VideoController) CreateReport(c *gin.Context) {
+	id, ok := videoID(c)
+	if !ok {
+		return
+	}
+	var request ReportRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperrors.Abort(c, apperrors.Invalid(err))
+		return
+	}
+	request.Details = strings.TrimSpace(request.Details)
+	if _, ok := models.ReportWeights[request.Category]; !ok {
+		apperrors.Abort(c, apperrors.InvalidRequest("category must be one of "+strings.Join(reportCategories(), ", ")))
+		return
+	}
+	if utf8.RuneCountInString(request.Details) > models.MaxReportDetailsLength {
+		apperrors.Abort(c, apperrors.InvalidRequest("details may be at most "+strconv.Itoa(models.MaxReportDetailsLength)+" characters"))
+		return
+	}
+
+	_, claims := utils.GetTokenClaims(c)
+	created, err := v.report(c.Request.Context(), id, claims, request.Category, request.Details)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	if !created {
+		apperrors.Abort(c, apperrors.Conflict("you already reported this video"))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListVideoReports returns the reports of a video, newest first, optionally
+// only those with ?status=. Administrator only.
+func (v *VideoController) ListVideoReports(c *gin.Context) {
+	_, claims := utils.GetTokenClaims(c)
+	if claims.Role != "Administrator" {
+		apperrors.Abort(c, apperrors.Forbidden("administrator role required"))
+		return
+	}
+	id, ok := videoID(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", models.ReportOpen, models.ReportDismissed, models.ReportActioned:
+	default:
+		apperrors.Abort(c, apperrors.InvalidRequest("status must be one of open, dismissed, actioned"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	video, err := v.repos.Videos.ByID(ctx, id)
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "video"))
+		return
+	}
+	reports, err := v.repos.Reports.ByVideo(ctx, video.ID)
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "video report"))
+		return
+	}
+	results := make([]models.VideoReportDTO, 0, len(reports))
+	for _, report := range reports {
+		if status == "" || report.Status == status {
+			results = append(results, toVideoReportDTO(report))
+		}
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// ResolveReports dismisses or actions every open report of a video and
+// returns the video. If-Match is optional. Administrator only.
+func (v *VideoController) ResolveReports(c *gin.Context) {
+	_, claims := utils.GetTokenClaims(c)
+	if claims.Role != "Administrator" {
+		apperrors.Abort(c, apperrors.Forbidden("administrator role required"))
+		return
+	}
+	id, ok := videoID(c)
+	if !ok {
+		return
+	}
+	var resolution ReportResolution
+	if err := c.ShouldBindJSON(&resolution); err != nil {
+		apperrors.Abort(c, apperrors.Invalid(err))
+		return
+	}
+	video, err := v.resolveReports(c.Request.Context(), id, claims, c.GetHeader("If-Match"), resolution.Status)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	v.writeVideo(c, http.StatusOK, video)
+}
+
+// DeleteReports dismisses the open reports of a video. If-Match is
+// optional.
+func (v *VideoController) DeleteReports(c *gin.Context) {
+	_, claims := utils.GetTokenClaims(c)
+	if claims.Role != "Administrator" {
+		apperrors.Abort(c, apperrors.Forbidden("administrator role required"))
+		return
+	}
+	id, ok := videoID(c)
+	if !ok {
+		return
+	}
+	video, err := v.resolveReports(c.Request.Context(), id, claims, c.GetHeader("If-Match"), models.ReportDismissed)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	v.writeVideo(c, http.StatusOK, video)
+}
+
+// ResolveReport dismisses or actions a single open report, e.g. an abusive
+// one, and returns it. Administrator only.
+func (v *VideoController) ResolveReport(c *gin.Context) {
+	_, claims := utils.GetTokenClaims(c)
+	if claims.Role != "Administrator" {
+		apperrors.Abort(c, apperrors.Forbidden("administrator role required"))
+		return
+	}
+	id, ok := videoID(c)
+	if !ok {
+		return
+	}
+	reportID, err := strconv.ParseUint(c.Param("reportId"), 10, 64)
+	if err != nil || reportID == 0 {
+		apperrors.Abort(c, apperrors.InvalidRequest("report id must be a positive integer"))
+		return
+	}
+	var resolution ReportResolution
+	if err := c.ShouldBindJSON(&resolution); err != nil {
+		apperrors.Abort(c, apperrors.Invalid(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	var report *models.VideoReport
+	err = v.repos.UnitOfWork.Do(ctx, func(tx repository.Repositories) error {
+		video, err := tx.Videos.ForUpdate().ByID(ctx, id)
+		if err != nil {
+			return apperrors.FromDB(err, "video")
+		}
+		if report, err = tx.Reports.ByID(ctx, video.ID, reportID); err != nil {
+			return apperrors.FromDB(err, "video report")
+		}
+		if report.Status != models.ReportOpen {
+			return apperrors.Conflict("the report is already " + report.Status)
+		}
+		resolve(report, resolution.Status, claims.Email)
+		if err := tx.Reports.Save(ctx, report); err != nil {
+			return apperrors.FromDB(err, "video report")
+		}
+		return v.moderate(ctx, tx, video)
+	})
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	metrics.ResolvedReports.Inc(report.Status)
+	logging.FromContext(ctx).Info("video report resolved", "video_id", report.VideoID, "report_id", report.ID, "status", report.Status)
+
+	c.JSON(http.StatusOK, toVideoReportDTO(*report))
+}
+
+// report files a report of the ready video by the user in claims. It
+// returns false when the user had already reported it.
+func (v *VideoController) report(ctx context.Context, id uint64, claims utils.JWTClaim, category, details string) (bool, error) {
+	created := false
+	err := v.repos.UnitOfWork.Do(ctx, func(tx repository.Repositories) error {
+		video, err := tx.Videos.ForUpdate().ByID(ctx, id)
+		if err != nil {
+			return apperrors.FromDB(err, "video")
+		}
+		if !video.Ready() {
+			return apperrors.NotFound("video")
+		}
+		if claims.Email == video.OwnerEmail {
+			return apperrors.Forbidden("you cannot report your own video")
+		}
+		reported, err := tx.Reports.Reported(ctx, video.ID, claims.Email)
+		if err != nil || reported {
+			return err
+		}
+		report := &models.VideoReport{
+			VideoID:       video.ID,
+			ReporterEmail: claims.Email,
+			Category:      category,
+			Details:       details,
+			Status:        models.ReportOpen,
+		}
+		if err := tx.Reports.Create(ctx, report); err != nil {
+			return apperrors.FromDB(err, "video report")
+		}
+		created = true
+		return v.moderate(ctx, tx, video)
+	})
+	if err != nil || !created {
+		return false, err
+	}
+	metrics.VideoReports.Inc(category)
+	logging.FromContext(ctx).Info("video reported", "video_id", id, "category", category)
+	return true, nil
+}
+
+// resolveReports moves the open reports of a video to status.
+func (v *VideoController) resolveReports(ctx context.Context, id uint64, claims utils.JWTClaim, ifMatch, status string) (*models.Video, error) {
+	var video *models.Video
+	var resolved int64
+	err := v.repos.UnitOfWork.Do(ctx, func(tx repository.Repositories) error {
+		var err error
+		if video, err = tx.Videos.ForUpdate().ByID(ctx, id); err != nil {
+			return apperrors.FromDB(err, "video")
+		}
+		if err := etag.Check(ifMatch, etag.Of(video.ID, video.UpdatedAt), false); err != nil {
+			return err
+		}
+		if resolved, err = tx.Reports.ResolveOpen(ctx, video.ID, status, claims.Email); err != nil {
+			return apperrors.FromDB(err, "video report")
+		}
+		return v.moderate(ctx, tx, video)
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.ResolvedReports.Add(float64(resolved), status)
+	logging.FromContext(ctx).Info("video reports resolved", "video_id", video.ID, "status", status, "reports", resolved)
+	return video, nil
+}
+
+// moderate derives Reported and Hidden from the reports of a video and
+// saves it when they changed. Any actioned report keeps the video hidden,
+// open ones hide it once their weight reaches the threshold.
+func (v *VideoController) moderate(ctx context.Context, tx repository.Repositories, video *models.Video) error {
+	reports, err := tx.Reports.ByVideo(ctx, video.ID)
+	if err != nil {
+		return apperrors.FromDB(err, "video report")
+	}
+	tally := models.Tally(reports)
+	reported := tally.Open > 0
+	hidden := tally.Actioned > 0 || tally.Score >= v.settings.Moderation.ReportHideThreshold
+	if reported == video.Reported && hidden == video.Hidden {
+		return nil
+	}
+	if hidden != video.Hidden {
+		logger := logging.FromContext(ctx).With("video_id", video.ID, "score", tally.Score, "actioned", tally.Actioned)
+		if hidden {
+			metrics.HiddenVideos.Inc()
+			logger.Warn("video hidden by its reports")
+		} else {
+			logger.Info("video no longer hidden")
+		}
+	}
+	video.Reported, video.Hidden = reported, hidden
+	if err := tx.Videos.Save(ctx, video); err != nil {
+		return apperrors.FromDB(err, "video")
+	}
+	return nil
+}
+
+func resolve(report *models.VideoReport, status, by string) {
+	now := time.Now()
+	report.Status, report.ResolvedBy, report.ResolvedAt = status, by, &now
+}
+
+func reportCategories() []string {
+	categories := make([]string, 0, len(models.ReportWeights))
+	for category := range models.ReportWeights {
+		categories = append(categories, category)
+	}
+	slices.Sort(categories)
+	return categories
+}
+
+func toVideoReportDTO(report models.VideoReport) models.VideoReportDTO {
+	return models.VideoReportDTO{
+		ID:            report.ID,
+		ReportedAt:    report.CreatedAt,
+		ReporterEmail: report.ReporterEmail,
+		Category:      report.Category,
+		Details:       report.Details,
+		Status:        report.Status,
+		ResolvedBy:    report.ResolvedBy,
+		ResolvedAt:    report.ResolvedAt,
+	}
+}
@@ -0,0 +1,147 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"video-service/apperrors"
+	"video-service/models"
+)
+
+func (e *env) report(id uint, token, category string) int {
+	e.t.Helper()
+	return e.send(http.MethodPost, videoPath(id, "reports"), token, map[string]string{"category": category}).Code
+}
+
+func (e *env) listed(id uint) bool {
+	e.t.Helper()
+	for _, video := range decode[[]models.VideoSearchResultDTO](e.t, e.get("/api/v2/videos", ""), http.StatusOK) {
+		if video.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCreateReport(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+
+	if code := e.report(video.ID, otherToken, "spam"); code != http.StatusNoContent {
+		t.Fatalf("status = %d", code)
+	}
+	problem(t, e.send(http.MethodPost, videoPath(video.ID, "reports"), otherToken, map[string]string{"category": "misleading"}),
+		http.StatusConflict, apperrors.CodeConflict)
+	problem(t, e.send(http.MethodPost, videoPath(video.ID, "reports"), ownerToken, map[string]string{"category": "spam"}),
+		http.StatusForbidden, apperrors.CodeForbidden)
+	problem(t, e.send(http.MethodPost, videoPath(video.ID+1, "reports"), otherToken, map[string]string{"category": "spam"}),
+		http.StatusNotFound, apperrors.CodeNotFound)
+
+	stored := e.video(video.ID)
+	if !stored.Reported || stored.Hidden {
+		t.Fatalf("reported %v, hidden %v", stored.Reported, stored.Hidden)
+	}
+	reports := decode[[]models.VideoReportDTO](t, e.get(videoPath(video.ID, "reports"), adminToken), http.StatusOK)
+	if len(reports) != 1 || reports[0].ReporterEmail != other || reports[0].Category != "spam" || reports[0].Status != models.ReportOpen {
+		t.Fatalf("reports = %+v", reports)
+	}
+	problem(t, e.get(videoPath(video.ID, "reports"), ownerToken), http.StatusForbidden, apperrors.CodeForbidden)
+
+	reported := decode[[]models.VideoSearchResultDTO](t, e.get("/api/v2/videos/reported", adminToken), http.StatusOK)
+	if len(reported) != 1 || reported[0].ID != video.ID {
+		t.Fatalf("reported videos = %+v", reported)
+	}
+}
+
+func TestReportsHideVideos(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+
+	// The threshold is 3; hate weighs 3 on its own
+	if code := e.report(video.ID, otherToken, "hate"); code != http.StatusNoContent {
+		t.Fatalf("status = %d", code)
+	}
+	if !e.video(video.ID).Hidden || e.listed(video.ID) {
+		t.Fatal("the video was not hidden")
+	}
+
+	rec := e.get(videoPath(video.ID), "")
+	tag := rec.Header().Get("ETag")
+	decode[models.VideoSearchResultDTO](t, rec, http.StatusOK)
+
+	// Dismissing honours If-Match
+	problem(t, e.send(http.MethodPost, videoPath(video.ID, "reports/resolve"), adminToken, map[string]string{"status": "dismissed"}, "If-Match", `"stale"`),
+		http.StatusPreconditionFailed, apperrors.CodePreconditionFailed)
+	problem(t, e.send(http.MethodPost, videoPath(video.ID, "reports/resolve"), otherToken, map[string]string{"status": "dismissed"}),
+		http.StatusForbidden, apperrors.CodeForbidden)
+
+	rec = e.send(http.MethodPost, videoPath(video.ID, "reports/resolve"), adminToken, map[string]string{"status": "dismissed"}, "If-Match", tag)
+	resolved := decode[models.VideoSearchResultDTO](t, rec, http.StatusOK)
+	if resolved.Hidden || resolved.Reported {
+		t.Fatalf("resolved = %+v", resolved)
+	}
+	if newTag := rec.Header().Get("ETag"); newTag == "" || newTag == tag {
+		t.Fatalf("ETag %q after resolving, %q before", newTag, tag)
+	}
+	if !e.listed(video.ID) {
+		t.Fatal("the video is still hidden")
+	}
+
+	reports := decode[[]models.VideoReportDTO](t, e.get(videoPath(video.ID, "reports")+"?status=dismissed", adminToken), http.StatusOK)
+	if len(reports) != 1 || reports[0].ResolvedBy != admin || reports[0].ResolvedAt == nil {
+		t.Fatalf("reports = %+v", reports)
+	}
+}
+
+func TestResolveReport(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	e.report(video.ID, otherToken, "spam")
+	report := decode[[]models.VideoReportDTO](t, e.get(videoPath(video.ID, "reports"), adminToken), http.StatusOK)[0]
+	reportPath := videoPath(video.ID, "reports", strconv.FormatUint(uint64(report.ID), 10))
+
+	// A single actioned report takes the video down
+	resolved := decode[models.VideoReportDTO](t, e.send(http.MethodPatch, reportPath, adminToken, map[string]string{"status": "actioned"}), http.StatusOK)
+	if resolved.Status != models.ReportActioned || resolved.ResolvedBy != admin {
+		t.Fatalf("report = %+v", resolved)
+	}
+	if stored := e.video(video.ID); !stored.Hidden || stored.Reported {
+		t.Fatalf("hidden %v, reported %v", stored.Hidden, stored.Reported)
+	}
+	problem(t, e.send(http.MethodPatch, reportPath, adminToken, map[string]string{"status": "dismissed"}), http.StatusConflict, apperrors.CodeConflict)
+	problem(t, e.send(http.MethodPatch, videoPath(video.ID, "reports", "99"), adminToken, map[string]string{"status": "dismissed"}),
+		http.StatusNotFound, apperrors.CodeNotFound)
+}
+
+func TestDeleteReports(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	e.report(video.ID, otherToken, "spam")
+
+	resolved := decode[models.VideoSearchResultDTO](t, e.do(http.MethodDelete, videoPath(video.ID, "reports"), adminToken, "", nil), http.StatusOK)
+	if resolved.Reported {
+		t.Fatal("the video is still reported")
+	}
+	reports := decode[[]models.VideoReportDTO](t, e.get(videoPath(video.ID, "reports")+"?status=open", adminToken), http.StatusOK)
+	if len(reports) != 0 {
+		t.Fatalf("open reports = %+v", reports)
+	}
+}
+
+func TestReportVideoV1(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	path := "/api/videos/report-video/" + strconv.FormatUint(uint64(video.ID), 10)
+
+	// Reporting again is not an error in v1
+	for i := 0; i < 2; i++ {
+		if rec := e.get(path, otherToken); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+	}
+	reports := decode[[]models.VideoReportDTO](t, e.get(videoPath(video.ID, "reports"), adminToken), http.StatusOK)
+	if len(reports) != 1 || reports[0].Category != "other" {
+		t.Fatalf("reports = %+v", reports)
+	}
+	problem(t, e.get("/api/videos/report-video/x", otherToken), http.StatusBadRequest, apperrors.CodeValidationFailed)
+}
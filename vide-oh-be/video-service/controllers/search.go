@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"video-service/apperrors"
+	"video-service/logging"
+	"video-service/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+var highlighter = strings.NewReplacer(models.HighlightStart, "<mark>", models.HighlightStop, "</mark>")
+
+// ListVideos searches the ready videos, a page at a time. The next page,
+// if any, is linked in the Link header with rel="next".
+func (v *VideoController) ListVideos(c *gin.Context) {
+	search, err := v.videoSearch(c)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	limit := search.Limit
+	// One more tells whether there is a next page
+	search.Limit++
+	hits, err := v.repos.Videos.Search(c.Request.Context(), search)
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "video"))
+		return
+	}
+	if len(hits) > limit {
+		hits = hits[:limit]
+		cursor, err := encodeCursor(hits[limit-1].Cursor(search.Sort))
+		if err != nil {
+			apperrors.Abort(c, apperrors.Internal(err))
+			return
+		}
+		query := c.Request.URL.Query()
+		query.Set("cursor", cursor)
+		c.Header("Link", "<"+v.publicURL(c)+c.Request.URL.Path+"?"+query.Encode()+`>; rel="next"`)
+	}
+
+	results := make([]models.VideoSearchResultDTO, 0, len(hits))
+	for _, hit := range hits {
+		result, err := v.videoDTO(c, hit.Video)
+		if err != nil {
+			apperrors.Abort(c, apperrors.Internal(err))
+			return
+		}
+		if hit.TitleHeadline != "" || hit.DescriptionHeadline != "" {
+			result.Highlight = &models.HighlightDTO{
+				Title:       highlight(hit.TitleHeadline),
+				Description: highlight(hit.DescriptionHeadline),
+			}
+		}
+		results = append(results, result)
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// videoSearch reads the search from the query string.
+func (v *VideoController) videoSearch(c *gin.Context) (models.VideoSearch, error) {
+	search := models.VideoSearch{
+		Text:     strings.TrimSpace(c.Query("query")),
+		Language: v.settings.Search.Language,
+		Uploader: c.Query("uploader"),
+		Sort:     c.Query("sort"),
+		Limit:    defaultSearchLimit,
+	}
+	switch search.Sort {
+	case "":
+		search.Sort = models.SortDate
+		if search.Text != "" {
+			search.Sort = models.SortRelevance
+		}
+	case models.SortRelevance, models.SortDate, models.SortPopularity:
+	default:
+		return search, apperrors.InvalidRequest("sort must be one of relevance, date, popularity")
+	}
+
+	var err error
+	if search.UploadedAfter, err = queryTime(c, "uploadedAfter"); err != nil {
+		return search, err
+	}
+	if search.UploadedBefore, err = queryTime(c, "uploadedBefore"); err != nil {
+		return search, err
+	}
+	if search.MinDuration, err = queryDuration(c, "minDuration"); err != nil {
+		return search, err
+	}
+	if search.MaxDuration, err = queryDuration(c, "maxDuration"); err != nil {
+		return search, err
+	}
+	if search.MaxDuration > 0 && search.MinDuration > search.MaxDuration {
+		return search, apperrors.InvalidRequest("minDuration must not be above maxDuration")
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			return search, apperrors.InvalidRequest("limit must be between 1 and " + strconv.Itoa(maxSearchLimit))
+		}
+		search.Limit = n
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil || after.Sort != search.Sort {
+			return search, apperrors.InvalidRequest("cursor is not a next page of this search")
+		}
+		search.After = &after
+	}
+	return search, nil
+}
+
+// queryTime reads an RFC 3339 time, or a date meaning its start in UTC.
+func queryTime(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, apperrors.InvalidRequest(name + " must be an RFC 3339 time or a date")
+}
+
+// queryDuration reads a number of seconds.
+func queryDuration(c *gin.Context, name string) (float64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0, apperrors.InvalidRequest(name + " must be a number of seconds")
+	}
+	return seconds, nil
+}
+
+// Cursors are opaque to clients: base64url JSON of the sort key.
+func encodeCursor(cursor models.SearchCursor) (string, error) {
+	b, err := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b), err
+}
+
+func decodeCursor(s string) (models.SearchCursor, error) {
+	var cursor models.SearchCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(b, &cursor)
+	return cursor, err
+}
+
+// highlight escapes a headline for HTML and marks its matches.
+func highlight(headline string) string {
+	return highlighter.Replace(html.EscapeString(headline))
+}
+
+// countView counts a stream URL handed out towards the popularity of the
+// video; a failure only loses the view.
+func (v *VideoController) countView(c *gin.Context, video *models.Video) {
+	if err := v.repos.Videos.IncrementViews(c.Request.Context(), video.ID); err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to count view", "video_id", video.ID, "error", err)
+	}
+}
@@ -0,0 +1,141 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"video-service/apperrors"
+	"video-service/models"
+)
+
+func titles(videos []models.VideoSearchResultDTO) []string {
+	out := make([]string, len(videos))
+	for i, video := range videos {
+		out[i] = video.Title
+	}
+	return out
+}
+
+func TestListVideos(t *testing.T) {
+	e := newEnv(t)
+	e.upload("Cats playing", "")
+	e.upload("Dogs", "They chase the cat")
+	e.upload("Birds", "")
+	e.upload("100% cats", `Under_score and back\slash`)
+	// Videos still being processed are not listed
+	contentType, body := form(t, "file", "cats.mp4", mp4, "title", "Cats pending")
+	decode[models.VideoSearchResultDTO](t, e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body), http.StatusCreated)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"100% cats", "Birds", "Dogs", "Cats playing"}},
+		{"?query=cat", []string{"100% cats", "Dogs", "Cats playing"}},
+		// LIKE wildcards match themselves
+		{"?query=%25", []string{"100% cats"}},
+		{"?query=c%25s", []string{}},
+		{"?query=_", []string{"100% cats"}},
+		{"?query=d_gs", []string{}},
+		{"?query=" + url.QueryEscape(`k\s`), []string{"100% cats"}},
+		{"?query=CATS+playing", []string{"Cats playing"}},
+		{"?query=fish", []string{}},
+		{"?uploader=" + url.QueryEscape(owner) + "&sort=date", []string{"100% cats", "Birds", "Dogs", "Cats playing"}},
+		{"?uploader=" + url.QueryEscape(other), []string{}},
+		{"?minDuration=60", []string{}},
+		{"?maxDuration=60&limit=2", []string{"100% cats", "Birds"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := e.get("/api/v2/videos"+tt.query, "")
+			// Empty results are [], never null
+			if rec.Code == http.StatusOK && len(tt.want) == 0 && strings.TrimSpace(rec.Body.String()) != "[]" {
+				t.Fatalf("body = %s", rec.Body)
+			}
+			if got := titles(decode[[]models.VideoSearchResultDTO](t, rec, http.StatusOK)); !slices.Equal(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListVideosPages(t *testing.T) {
+	e := newEnv(t)
+	for _, title := range []string{"One", "Two", "Three"} {
+		e.upload(title, "")
+	}
+
+	var got []string
+	next := "/api/v2/videos?limit=2"
+	for pages := 0; next != ""; pages++ {
+		if pages == 3 {
+			t.Fatal("the pages do not end")
+		}
+		rec := e.get(next, "")
+		got = append(got, titles(decode[[]models.VideoSearchResultDTO](t, rec, http.StatusOK))...)
+		next = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			target, ok := strings.CutSuffix(link, `>; rel="next"`)
+			if !ok {
+				t.Fatalf("Link = %q", link)
+			}
+			u, err := url.Parse(strings.TrimPrefix(target, "<"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			next = u.RequestURI()
+		}
+	}
+	if want := []string{"Three", "Two", "One"}; !slices.Equal(got, want) {
+		t.Fatalf("pages hold %q, want %q", got, want)
+	}
+}
+
+func TestListVideosRejectsBadQueries(t *testing.T) {
+	e := newEnv(t)
+	e.upload("One", "")
+	e.upload("Two", "")
+	rec := e.get("/api/v2/videos?limit=1&sort=date", "")
+	cursor, err := url.Parse(strings.TrimSuffix(strings.TrimPrefix(rec.Header().Get("Link"), "<"), `>; rel="next"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		code  apperrors.Code
+	}{
+		// A cursor only continues the search it came from
+		{"?sort=popularity&cursor=" + cursor.Query().Get("cursor"), apperrors.CodeInvalidRequest},
+		{"?cursor=not-a-cursor", apperrors.CodeInvalidRequest},
+		{"?minDuration=60&maxDuration=10", apperrors.CodeInvalidRequest},
+		{"?uploadedAfter=yesterday", apperrors.CodeInvalidRequest},
+		{"?sort=views", apperrors.CodeValidationFailed},
+		{"?limit=0", apperrors.CodeValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			problem(t, e.get("/api/v2/videos"+tt.query, ""), http.StatusBadRequest, tt.code)
+		})
+	}
+}
+
+func TestSearchVideosV1(t *testing.T) {
+	e := newEnv(t)
+	e.upload("Cats playing", "")
+	e.upload("Dogs", "")
+
+	rec := e.get("/api/videos/search-videos?query=dog", "")
+	if got := titles(decode[[]models.VideoSearchResultDTO](t, rec, http.StatusOK)); !slices.Equal(got, []string{"Dogs"}) {
+		t.Fatalf("got %q", got)
+	}
+	if rec.Header().Get("Deprecation") == "" || !strings.Contains(rec.Header().Get("Link"), `rel="successor-version"`) {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+	// v1 answers null rather than [] when nothing matches
+	if rec := e.get("/api/videos/search-videos?query=fish", ""); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "null" {
+		t.Fatalf("no match: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,11 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Ping(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{"message": "pong"})
+}
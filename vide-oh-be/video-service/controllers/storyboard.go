@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"video-service/apperrors"
+	"video-service/logging"
+	"video-service/media"
+	"video-service/metrics"
+	"video-service/models"
+	"video-service/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Storyboards: processing writes sprite sheets and a WebVTT thumbnails
+// track under <filename>/storyboard/ in the bucket. Like the HLS
+// playlists, the API serves the track and presigns the sheets its cues
+// point into.
+
+const vttContentType = "text/vtt; charset=utf-8"
+
+func storyboardKey(filename string, elem ...string) string {
+	return path.Join(append([]string{filename, "storyboard"}, elem...)...)
+}
+
+// generateStoryboard writes the storyboard of video, read from input, to
+// the bucket and records its sheets.
+func (v *VideoController) generateStoryboard(ctx context.Context, video *models.Video, input string, source *media.Source) error {
+	logger := logging.FromContext(ctx).With("video_id", video.ID)
+
+	dir, err := os.MkdirTemp("", video.Filename+"-storyboard-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Error("failed to delete storyboard files", "error", err)
+		}
+	}()
+
+	storyboardCtx, span := tracing.Start(ctx, "ffmpeg storyboard")
+	start := time.Now()
+	board, err := media.GenerateStoryboard(storyboardCtx, input, dir, source)
+	tracing.End(span, err)
+	if err != nil {
+		return err
+	}
+	metrics.StoryboardDuration.Observe(time.Since(start).Seconds())
+	if err := os.WriteFile(filepath.Join(dir, media.StoryboardTrack), board.WebVTT(), 0o644); err != nil {
+		return err
+	}
+	if err := v.uploadDir(ctx, dir, storyboardKey(video.Filename)); err != nil {
+		return err
+	}
+	logger.Info("storyboard generated", "sheets", len(board.Sheets), "thumbnails", board.Count, "interval_seconds", board.Interval.Seconds())
+	return v.repos.Videos.SetStoryboard(ctx, video.ID, len(board.Sheets))
+}
+
+// StoryboardTrack serves the WebVTT thumbnails track with each sprite
+// sheet replaced by a presigned URL; the #xywh fragments are kept.
+func (v *VideoController) StoryboardTrack(c *gin.Context) {
+	video, ok := v.find(c)
+	if !ok {
+		return
+	}
+	if video.StoryboardSheets == 0 {
+		apperrors.Abort(c, apperrors.NotFound("storyboard"))
+		return
+	}
+	track, ok := v.readObject(c, storyboardKey(video.Filename, media.StoryboardTrack), "storyboard")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	sheets := map[string]string{}
+	lines := strings.Split(string(track), "\n")
+	for i, line := range lines {
+		sheet, fragment, ok := strings.Cut(strings.TrimSpace(line), "#xywh=")
+		if !ok {
+			continue
+		}
+		url, seen := sheets[sheet]
+		if !seen {
+			var err error
+			url, err = v.bucket.PresignGetObject(ctx, storyboardKey(video.Filename, path.Base(sheet)), v.settings.Media.StreamURLTTL)
+			if err != nil {
+				apperrors.Abort(c, apperrors.Internal(err))
+				return
+			}
+			sheets[sheet] = url
+		}
+		lines[i] = url + "#xywh=" + fragment
+	}
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(int(v.settings.Media.StreamURLTTL.Seconds())/2))
+	c.Data(http.StatusOK, vttContentType, []byte(strings.Join(lines, "\n")))
+}
+
+func (v *VideoController) storyboardDTO(c *gin.Context, video *models.Video) (*models.StoryboardDTO, error) {
+	if video.StoryboardSheets == 0 {
+		return nil, nil
+	}
+	sprites := make([]string, video.StoryboardSheets)
+	for i := range sprites {
+		url, err := v.presignedURL(c.Request.Context(), storyboardKey(video.Filename, media.StoryboardSheet(i)))
+		if err != nil {
+			return nil, err
+		}
+		sprites[i] = url
+	}
+	return &models.StoryboardDTO{Track: v.videoURL(c, video, media.StoryboardTrack), Sprites: sprites}, nil
+}
@@ -0,0 +1,47 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"video-service/apperrors"
+	"video-service/models"
+)
+
+func TestStoryboard(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+
+	// 12.48s at one thumbnail every 2s fits on one sheet
+	if video.Storyboard == nil || len(video.Storyboard.Sprites) != 1 ||
+		video.Storyboard.Track != "http://example.com"+videoPath(video.ID, "storyboard.vtt") {
+		t.Fatalf("storyboard = %+v", video.Storyboard)
+	}
+	if sheet, ok := e.bucket.Object(video.Filename + "/storyboard/sheet_001.jpg"); !ok || sheet.ContentType != "image/jpeg" {
+		t.Fatalf("sheet = %+v, %v", sheet, ok)
+	}
+
+	rec := e.get(videoPath(video.ID, "storyboard.vtt"), "")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/vtt") {
+		t.Fatalf("track: %d %v", rec.Code, rec.Header())
+	}
+	track := rec.Body.String()
+	if !strings.HasPrefix(track, "WEBVTT") || strings.Count(track, "#xywh=") != 7 {
+		t.Fatalf("track:\n%s", track)
+	}
+	// The cues point into the presigned sheet, 160x90 tiles for a 16:9 source
+	if !strings.Contains(track, "00:00:02.000 --> 00:00:04.000\nhttps://memory.invalid/"+video.Filename+"%2Fstoryboard%2Fsheet_001.jpg") ||
+		!strings.Contains(track, "#xywh=160,0,160,90") {
+		t.Fatalf("track:\n%s", track)
+	}
+}
+
+func TestStoryboardFailureKeepsTheVideo(t *testing.T) {
+	e := newEnv(t)
+	t.Setenv("FAKE_STORYBOARD", "fail")
+	video := e.upload("Cats", "")
+	if video.Status != models.StatusReady || video.Storyboard != nil {
+		t.Fatalf("video = %+v", video)
+	}
+	problem(t, e.get(videoPath(video.ID, "storyboard.vtt"), ""), http.StatusNotFound, apperrors.CodeNotFound)
+}
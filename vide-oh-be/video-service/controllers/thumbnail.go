@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"time"
+	"video-service/apperrors"
+	"video-service/logging"
+	"video-service/models"
+	"video-service/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxThumbnailSize bounds custom thumbnail uploads.
+const maxThumbnailSize = 5 << 20
+
+var thumbnailTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/webp": true,
+}
+
+type PosterFrameRequest struct {
+	// Seconds from the start of the video
+	Time *float64 `json:"time" binding:"required,min=0"`
+}
+
+// SetPosterFrame replaces the thumbnail with the frame at the requested
+// time. The previous thumbnail time goes to the edit history.
+func (v *VideoController) SetPosterFrame(c *gin.Context) {
+	video, ok := v.findEditable(c)
+	if !ok {
+		return
+	}
+	var request PosterFrameRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperrors.Abort(c, apperrors.InvalidRequest(`"time" must be a number of seconds`).Wrap(err))
+		return
+	}
+	ctx := c.Request.Context()
+	thumbnail, err := v.renderPosterFrame(ctx, video, *request.Time)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	defer removeThumbnail(ctx, thumbnail)
+	v.thumbnailChanged(c, video, thumbnail, request.Time)
+}
+
+// UploadThumbnail replaces the thumbnail with the multipart "image",
+// cropped like generated ones, and records the change like SetPosterFrame.
+func (v *VideoController) UploadThumbnail(c *gin.Context) {
+	video, ok := v.findEditable(c)
+	if !ok {
+		return
+	}
+	file, err := c.FormFile("image")
+	if err != nil {
+		apperrors.Abort(c, apperrors.InvalidRequest(`multipart field "image" is required`).Wrap(err))
+		return
+	}
+	if file.Size > maxThumbnailSize {
+		apperrors.Abort(c, apperrors.ThumbnailTooLarge(maxThumbnailSize))
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return
+	}
+	defer src.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return
+	}
+	if !thumbnailTypes[http.DetectContentType(head[:n])] {
+		apperrors.Abort(c, apperrors.ErrUnsupportedImage)
+		return
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return
+	}
+
+	// ffmpeg scales and crops the image, which also rejects files that
+	// only look like images
+	ctx := c.Request.Context()
+	image, err := os.CreateTemp("", video.Filename+"-upload-*")
+	if err != nil {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return
+	}
+	defer func() {
+		if err := os.Remove(image.Name()); err != nil {
+			logging.FromContext(ctx).Error("failed to delete uploaded thumbnail", "error", err)
+		}
+	}()
+	_, err = io.Copy(image, src)
+	if closeErr := image.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return
+	}
+	thumbnail, err := v.renderThumbnail(ctx, image.Name(), video.Filename, 0)
+	if err != nil {
+		apperrors.Abort(c, apperrors.ErrUnsupportedImage.Wrap(err))
+		return
+	}
+	defer removeThumbnail(ctx, thumbnail)
+	v.thumbnailChanged(c, video, thumbnail, nil)
+}
+
+// renderPosterFrame renders the frame at seconds of a ready video as its
+// next thumbnail. The caller stores the file and removes it.
+func (v *VideoController) renderPosterFrame(ctx context.Context, video *models.Video, seconds float64) (string, error) {
+	if seconds >= video.Duration {
+		return "", apperrors.InvalidRequest("the poster frame must be before the end of the video")
+	}
+	input, err := v.presignedURL(ctx, video.Filename+".mp4")
+	if err != nil {
+		return "", apperrors.Internal(err)
+	}
+	thumbnail, err := v.renderThumbnail(ctx, input, video.Filename, time.Duration(seconds*float64(time.Second)))
+	if errors.Is(err, errNoFrame) {
+		return "", apperrors.InvalidRequest("there is no frame at that time").Wrap(err)
+	}
+	if err != nil {
+		return "", apperrors.Internal(err)
+	}
+	return thumbnail, nil
+}
+
+// findEditable loads a ready :id video that the user making the request
+// may edit: their own, or any video for an administrator, as in
+// UpdateVideo.
+func (v *VideoController) findEditable(c *gin.Context) (*models.Video, bool) {
+	video, ok := v.find(c)
+	if !ok {
+		return nil, false
+	}
+	_, claims := utils.GetTokenClaims(c)
+	owner := claims.Role == "RegisteredUser" && claims.Email == video.OwnerEmail
+	if !owner && claims.Role != "Administrator" {
+		apperrors.Abort(c, apperrors.Forbidden("only the owner or an administrator can change the thumbnail"))
+		return nil, false
+	}
+	return video, true
+}
+
+// thumbnailChanged stores the rendered thumbnail of video under a new key
+// and records it, with its time, nil for an uploaded picture, in an edit.
+// It responds with the video and its new ETag.
+func (v *VideoController) thumbnailChanged(c *gin.Context, video *models.Video, thumbnail string, at *float64) {
+	ctx := c.Request.Context()
+	_, claims := utils.GetTokenClaims(c)
+	key, err := v.storeNewThumbnail(ctx, thumbnail, video.Filename)
+	if err != nil {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return
+	}
+	updated, err := v.editVideo(ctx, uint64(video.ID), claims, func(*models.Video) error { return nil }, func(video *models.Video) {
+		video.ThumbnailTime = at
+		video.Thumbnail = key
+	})
+	if err != nil {
+		v.discardThumbnail(ctx, key)
+		apperrors.Abort(c, updateError(err))
+		return
+	}
+	logging.FromContext(ctx).Info("thumbnail changed", "video_id", video.ID, "key", key)
+	v.writeVideo(c, http.StatusOK, updated)
+}
+
+// storeNewThumbnail uploads a rendered thumbnail of the video stored as
+// filename under a key of its own and returns the key. The earlier
+// thumbnails stay for the edit history until the video is purged.
+func (v *VideoController) storeNewThumbnail(ctx context.Context, thumbnail, filename string) (string, error) {
+	key := path.Join(filename, "thumbnails", strconv.FormatInt(time.Now().UnixNano(), 10)+".png")
+	return key, v.storeThumbnail(ctx, thumbnail, key)
+}
+
+// discardThumbnail deletes a thumbnail stored for an edit that failed.
+func (v *VideoController) discardThumbnail(ctx context.Context, key string) {
+	if err := v.bucket.DeleteObject(ctx, key); err != nil {
+		logging.FromContext(ctx).Error("failed to delete unused thumbnail", "key", key, "error", err)
+	}
+}
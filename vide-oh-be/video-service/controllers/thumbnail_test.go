@@ -0,0 +1,67 @@
+package controllers_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"video-service/apperrors"
+)
+
+// png starts like a PNG image.
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+// thumbnail returns the current thumbnail of a video.
+func (e *env) thumbnail(id uint) string {
+	e.t.Helper()
+	video := e.video(id)
+	object, ok := e.bucket.Object(video.ThumbnailKey())
+	if !ok {
+		e.t.Fatal("no thumbnail")
+	}
+	return string(bytes.TrimSpace(object.Body))
+}
+
+func TestSetPosterFrame(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	path := videoPath(video.ID, "thumbnail")
+	tag := e.get(videoPath(video.ID), "").Header().Get("ETag")
+
+	rec := e.send(http.MethodPost, path, ownerToken, map[string]any{"time": 5})
+	decode[map[string]any](t, rec, http.StatusOK)
+	if got := e.thumbnail(video.ID); got != "png at 5.000" {
+		t.Fatalf("thumbnail = %q", got)
+	}
+	// The ETag moves on with the thumbnail
+	if newTag := rec.Header().Get("ETag"); newTag == "" || newTag == tag {
+		t.Fatalf("ETag %q, %q before", newTag, tag)
+	}
+
+	problem(t, e.send(http.MethodPost, path, ownerToken, map[string]any{"time": 12.48}), http.StatusBadRequest, apperrors.CodeInvalidRequest)
+	// Between the last frame and the end
+	problem(t, e.send(http.MethodPost, path, ownerToken, map[string]any{"time": 12.4}), http.StatusBadRequest, apperrors.CodeInvalidRequest)
+	problem(t, e.send(http.MethodPost, path, otherToken, map[string]any{"time": 1}), http.StatusForbidden, apperrors.CodeForbidden)
+	if got := e.thumbnail(video.ID); got != "png at 5.000" {
+		t.Fatalf("a rejected request changed the thumbnail to %q", got)
+	}
+}
+
+func TestUploadThumbnail(t *testing.T) {
+	e := newEnv(t)
+	video := e.upload("Cats", "")
+	e.send(http.MethodPost, videoPath(video.ID, "thumbnail"), ownerToken, map[string]any{"time": 5})
+	path := videoPath(video.ID, "thumbnail")
+
+	contentType, body := form(t, "image", "cover.png", png)
+	decode[map[string]any](t, e.do(http.MethodPut, path, ownerToken, contentType, body), http.StatusOK)
+	if got := e.thumbnail(video.ID); got != "png" {
+		t.Fatalf("thumbnail = %q", got)
+	}
+
+	contentType, body = form(t, "image", "cover.png", []byte("plain text"))
+	problem(t, e.do(http.MethodPut, path, ownerToken, contentType, body), http.StatusUnsupportedMediaType, apperrors.CodeUnsupportedMediaType)
+	contentType, body = form(t, "image", "cover.png", append(png, make([]byte, 5<<20)...))
+	problem(t, e.do(http.MethodPut, path, ownerToken, contentType, body), http.StatusRequestEntityTooLarge, apperrors.CodeTooLarge)
+	contentType, body = form(t, "image", "cover.png", png)
+	problem(t, e.do(http.MethodPut, path, otherToken, contentType, body), http.StatusForbidden, apperrors.CodeForbidden)
+}
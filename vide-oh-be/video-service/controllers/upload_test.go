@@ -0,0 +1,115 @@
+package controllers_test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"video-service/apperrors"
+	"video-service/models"
+)
+
+func TestUploadVideoV1(t *testing.T) {
+	e := newEnv(t)
+	contentType, body := form(t, "file", "cats.mp4", mp4)
+	rec := e.do(http.MethodPost, "/api/videos/upload-video?title=Cats&description=Two+cats", ownerToken, contentType, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Deprecation") == "" {
+		t.Error("v1 response without a Deprecation header")
+	}
+	filename, ok := strings.CutSuffix(strings.TrimPrefix(rec.Body.String(), "'"), ".mp4' uploaded!")
+	if !ok {
+		t.Fatalf("body = %q", rec.Body)
+	}
+
+	original, ok := e.bucket.Object("originals/" + filename)
+	if !ok || !bytes.Equal(original.Body, mp4) || original.ContentType != "video/mp4" {
+		t.Fatalf("original = %+v, %v", original, ok)
+	}
+
+	e.process(filename)
+	if normalized, ok := e.bucket.Object(filename + ".mp4"); !ok || normalized.ContentType != "video/mp4" {
+		t.Fatalf("video = %+v, %v", normalized, ok)
+	}
+	if _, ok := e.bucket.Object("originals/" + filename); ok {
+		t.Fatal("the original was kept")
+	}
+	if thumbnail, ok := e.bucket.Object(filename + ".png"); !ok || thumbnail.ContentType != "image/png" {
+		t.Fatalf("thumbnail = %+v, %v", thumbnail, ok)
+	}
+	var video models.Video
+	if err := e.db.Where("filename = ?", filename).First(&video).Error; err != nil {
+		t.Fatal(err)
+	}
+	if video.Title != "Cats" || video.Description != "Two cats" || video.OwnerEmail != owner || video.Reported || video.Status != models.StatusReady {
+		t.Fatalf("video = %+v", video)
+	}
+}
+
+func TestUploadVideoV1Rejections(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		token   string
+		content []byte
+		status  int
+		code    apperrors.Code
+	}{
+		{"not a video", "?title=Cats", ownerToken, []byte("plain text, whatever the name says"), http.StatusUnsupportedMediaType, apperrors.CodeUnsupportedMediaType},
+		{"administrator", "?title=Cats", adminToken, mp4, http.StatusForbidden, apperrors.CodeForbidden},
+		{"title too long", "?title=" + strings.Repeat("x", models.MaxTitleLength+1), ownerToken, mp4, http.StatusBadRequest, apperrors.CodeInvalidRequest},
+		{"description too long", "?title=Cats&description=" + strings.Repeat("x", models.MaxDescriptionLength+1), ownerToken, mp4, http.StatusBadRequest, apperrors.CodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+			contentType, body := form(t, "file", "cats.mp4", tt.content)
+			problem(t, e.do(http.MethodPost, "/api/videos/upload-video"+tt.query, tt.token, contentType, body), tt.status, tt.code)
+			if keys := e.bucket.Keys(); len(keys) != 0 {
+				t.Fatalf("stored %v", keys)
+			}
+		})
+	}
+}
+
+func TestCreateVideo(t *testing.T) {
+	e := newEnv(t)
+	contentType, body := form(t, "file", "cats.mp4", mp4, "title", "Cats")
+	rec := e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body)
+	created := decode[models.VideoSearchResultDTO](t, rec, http.StatusCreated)
+	if want := videoPath(created.ID); rec.Header().Get("Location") != want {
+		t.Errorf("Location = %q, want %q", rec.Header().Get("Location"), want)
+	}
+	if created.Title != "Cats" || created.OwnerEmail != owner || created.Status != models.StatusUploaded || rec.Header().Get("ETag") == "" {
+		t.Fatalf("created = %+v, ETag %q", created, rec.Header().Get("ETag"))
+	}
+	if _, ok := e.bucket.Object("originals/" + created.Filename); !ok {
+		t.Fatal("the upload was not stored")
+	}
+	// Until it is processed only the owner sees the video, through its status
+	problem(t, e.get(videoPath(created.ID), ""), http.StatusNotFound, apperrors.CodeNotFound)
+
+	e.process(created.Filename)
+	rec = e.get(videoPath(created.ID), "")
+	if video := decode[models.VideoSearchResultDTO](t, rec, http.StatusOK); video.Status != models.StatusReady {
+		t.Fatalf("video = %+v", video)
+	}
+	tag := rec.Header().Get("ETag")
+	if rec := e.get(videoPath(created.ID), "", "If-None-Match", tag); rec.Code != http.StatusNotModified {
+		t.Fatalf("If-None-Match: status %d", rec.Code)
+	}
+	problem(t, e.get(videoPath(created.ID+1), ""), http.StatusNotFound, apperrors.CodeNotFound)
+}
+
+func TestCreateVideoRejections(t *testing.T) {
+	e := newEnv(t)
+	contentType, body := form(t, "file", "cats.mp4", []byte("plain text, whatever the name says"), "title", "Notes")
+	problem(t, e.do(http.MethodPost, "/api/v2/videos", ownerToken, contentType, body), http.StatusUnsupportedMediaType, apperrors.CodeUnsupportedMediaType)
+	contentType, body = form(t, "file", "cats.mp4", mp4, "title", "Cats")
+	problem(t, e.do(http.MethodPost, "/api/v2/videos", adminToken, contentType, body), http.StatusForbidden, apperrors.CodeForbidden)
+	if keys := e.bucket.Keys(); len(keys) != 0 {
+		t.Fatalf("stored %v", keys)
+	}
+}
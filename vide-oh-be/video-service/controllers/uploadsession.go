@@ -0,0 +1,390 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"video-service/apperrors"
+	"video-service/logging"
+	"video-service/metrics"
+	"video-service/models"
+	"video-service/repository"
+	"video-service/storage"
+	"video-service/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Direct uploads: the client opens a session, PUTs the parts of the file
+// to presigned S3 URLs and completes the session with the part ETags. The
+// file never passes through API Gateway or Lambda.
+
+// Parts are at least 8 MiB, and larger when the file would otherwise need
+// more than storage.MaxParts of them.
+const minPartSize = 8 << 20
+
+// Part URLs handed out per request when the client does not ask for a
+// count, and at most.
+const (
+	defaultPartURLs = 100
+	maxPartURLs     = 1000
+)
+
+type UploadRequest struct {
+	Filename    string `json:"filename" binding:"required"`
+	Size        int64  `json:"size" binding:"required,min=1"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Keep the uploaded file after processing has converted it
+	KeepOriginal bool `json:"keepOriginal"`
+}
+
+type CompleteUploadRequest struct {
+	Parts []CompletedPart `json:"parts" binding:"required,min=1,dive"`
+}
+
+type CompletedPart struct {
+	PartNumber int32  `json:"partNumber" binding:"required,min=1"`
+	ETag       string `json:"etag" binding:"required"`
+}
+
+// CreateUpload opens a session for a file of the given size.
+func (v *VideoController) CreateUpload(c *gin.Context) {
+	_, claims := utils.GetTokenClaims(c)
+	if claims.Role != "RegisteredUser" {
+		apperrors.Abort(c, apperrors.Forbidden("only registered users can upload videos"))
+		return
+	}
+
+	var request UploadRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperrors.Abort(c, apperrors.Invalid(err))
+		return
+	}
+	if err := validateText(&request.Title, &request.Description); err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	if request.Size > v.settings.S3.MaxUploadSize {
+		apperrors.Abort(c, apperrors.TooLarge(v.settings.S3.MaxUploadSize))
+		return
+	}
+
+	ctx := c.Request.Context()
+	filenameNoExt := newFilename()
+	// The container is only known once the parts are in; completing the
+	// upload checks it
+	uploadID, err := v.bucket.CreateMultipartUpload(ctx, originalKey(filenameNoExt), "application/octet-stream")
+	if err != nil {
+		apperrors.Abort(c, apperrors.ErrUploadFailed.Wrap(err))
+		return
+	}
+	upload := &models.UploadSession{
+		OwnerEmail:   claims.Email,
+		Filename:     filenameNoExt,
+		UploadID:     uploadID,
+		Title:        request.Title,
+		Description:  request.Description,
+		Size:         request.Size,
+		PartSize:     partSize(request.Size),
+		ExpiresAt:    time.Now().Add(v.settings.S3.UploadSessionTTL),
+		KeepOriginal: request.KeepOriginal,
+	}
+	if err := v.repos.Uploads.Create(ctx, upload); err != nil {
+		v.abortUpload(ctx, filenameNoExt, uploadID)
+		apperrors.Abort(c, apperrors.FromDB(err, "upload session"))
+		return
+	}
+	logging.FromContext(ctx).Info("upload session created", "upload_session_id", upload.ID, "size_bytes", upload.Size, "parts", upload.PartCount())
+
+	c.Header("Location", strings.TrimSuffix(c.FullPath(), "/")+"/"+strconv.FormatUint(uint64(upload.ID), 10))
+	c.JSON(http.StatusCreated, toUploadSessionDTO(upload))
+}
+
+// GetUploadParts presigns the PUT URLs of count parts starting at first.
+func (v *VideoController) GetUploadParts(c *gin.Context) {
+	upload, ok := v.findUpload(c)
+	if !ok {
+		return
+	}
+
+	first, err := queryInt(c, "first", 1)
+	if err != nil || first < 1 || first > int64(upload.PartCount()) {
+		apperrors.Abort(c, apperrors.InvalidRequest("first must be a part number between 1 and "+strconv.Itoa(int(upload.PartCount()))))
+		return
+	}
+	count, err := queryInt(c, "count", defaultPartURLs)
+	if err != nil || count < 1 || count > maxPartURLs {
+		apperrors.Abort(c, apperrors.InvalidRequest("count must be between 1 and "+strconv.Itoa(maxPartURLs)))
+		return
+	}
+	last := first + count - 1
+	if last > int64(upload.PartCount()) {
+		last = int64(upload.PartCount())
+	}
+
+	parts := make([]models.UploadPartURL, 0, last-first+1)
+	for number := int32(first); number <= int32(last); number++ {
+		url, err := v.bucket.PresignUploadPart(c.Request.Context(), originalKey(upload.Filename), upload.UploadID, number, upload.PartLength(number), v.settings.S3.PresignTTL)
+		if err != nil {
+			apperrors.Abort(c, apperrors.Internal(err))
+			return
+		}
+		parts = append(parts, models.UploadPartURL{PartNumber: number, URL: url})
+	}
+	c.JSON(http.StatusOK, gin.H{"parts": parts, "expiresAt": time.Now().Add(v.settings.S3.PresignTTL)})
+}
+
+// CompleteUpload assembles the parts and creates the video, which is
+// processed like a form upload.
+func (v *VideoController) CompleteUpload(c *gin.Context) {
+	start := time.Now()
+	upload, ok := v.findUpload(c)
+	if !ok {
+		return
+	}
+
+	var request CompleteUploadRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperrors.Abort(c, apperrors.Invalid(err))
+		return
+	}
+	if len(request.Parts) != int(upload.PartCount()) {
+		apperrors.Abort(c, apperrors.InvalidRequest("expected "+strconv.Itoa(int(upload.PartCount()))+" parts"))
+		return
+	}
+	parts := make([]storage.Part, len(request.Parts))
+	for i, part := range request.Parts {
+		if part.PartNumber != int32(i+1) {
+			apperrors.Abort(c, apperrors.InvalidRequest("parts must be listed in order, starting at 1"))
+			return
+		}
+		parts[i] = storage.Part{Number: part.PartNumber, ETag: part.ETag}
+	}
+
+	ctx := c.Request.Context()
+	logger := logging.FromContext(ctx).With("upload_session_id", upload.ID)
+	key := originalKey(upload.Filename)
+	size, err := v.uploadedSize(ctx, key, upload.UploadID, parts)
+	if err != nil {
+		metrics.Uploads.Inc("failed")
+		abortCompletion(c, err)
+		return
+	}
+	if size > v.settings.S3.MaxUploadSize {
+		metrics.Uploads.Inc("rejected")
+		v.abortUpload(ctx, upload.Filename, upload.UploadID)
+		if err := v.repos.Uploads.Delete(ctx, upload.ID); err != nil {
+			logger.Error("failed to delete upload session", "error", err)
+		}
+		apperrors.Abort(c, apperrors.TooLarge(v.settings.S3.MaxUploadSize))
+		return
+	}
+	if size != upload.Size {
+		metrics.Uploads.Inc("rejected")
+		apperrors.Abort(c, apperrors.InvalidRequest(fmt.Sprintf("the parts add up to %d bytes, not the declared %d", size, upload.Size)))
+		return
+	}
+	video := &models.Video{
+		Title:        upload.Title,
+		Description:  upload.Description,
+		OwnerEmail:   upload.OwnerEmail,
+		Filename:     upload.Filename,
+		Status:       models.StatusUploaded,
+		KeepOriginal: upload.KeepOriginal,
+	}
+	// S3 is completed last, so a failure leaves the session open for
+	// another attempt. An S3 event that arrives before the commit is
+	// retried.
+	err = v.repos.UnitOfWork.Do(ctx, func(tx repository.Repositories) error {
+		if err := tx.Videos.Create(ctx, video); err != nil {
+			return apperrors.FromDB(err, "video")
+		}
+		if err := tx.Uploads.Delete(ctx, upload.ID); err != nil {
+			return apperrors.FromDB(err, "upload session")
+		}
+		return v.bucket.CompleteMultipartUpload(ctx, key, upload.UploadID, parts)
+	})
+	if err != nil {
+		metrics.Uploads.Inc("failed")
+		abortCompletion(c, err)
+		return
+	}
+	if !v.checkUpload(c, video) {
+		return
+	}
+	logger.Info("video uploaded", "video_id", video.ID, "key", key, "size_bytes", upload.Size)
+	metrics.Uploads.Inc("success")
+	metrics.UploadDuration.Observe(time.Since(start).Seconds())
+	metrics.UploadSize.Observe(float64(upload.Size))
+
+	v.processInline(ctx, *video)
+
+	c.Header("Location", strings.TrimSuffix(strings.TrimSuffix(c.FullPath(), "/uploads/:id/complete"), "/")+"/"+strconv.FormatUint(uint64(video.ID), 10))
+	v.writeVideo(c, http.StatusCreated, video)
+}
+
+// uploadedSize adds up the sizes S3 recorded for parts. Completing the
+// upload assembles exactly these bytes, whatever size was declared.
+func (v *VideoController) uploadedSize(ctx context.Context, key, uploadID string, parts []storage.Part) (int64, error) {
+	uploaded, err := v.bucket.ListParts(ctx, key, uploadID)
+	if err != nil {
+		return 0, err
+	}
+	sizes := make(map[int32]int64, len(uploaded))
+	for _, part := range uploaded {
+		sizes[part.Number] = part.Size
+	}
+	var total int64
+	for _, part := range parts {
+		size, ok := sizes[part.Number]
+		if !ok {
+			return 0, fmt.Errorf("%w: part %d was not uploaded", storage.ErrInvalidParts, part.Number)
+		}
+		total += size
+	}
+	return total, nil
+}
+
+func abortCompletion(c *gin.Context, err error) {
+	var appErr *apperrors.Error
+	switch {
+	case errors.Is(err, storage.ErrUploadNotFound):
+		apperrors.Abort(c, apperrors.ErrUploadExpired.Wrap(err))
+	case errors.Is(err, storage.ErrInvalidParts):
+		// The session stays open, so the client can upload the part again
+		apperrors.Abort(c, apperrors.InvalidRequest("a part is missing, too small or has a different ETag").Wrap(err))
+	case errors.As(err, &appErr):
+		apperrors.Abort(c, appErr)
+	default:
+		apperrors.Abort(c, apperrors.ErrUploadFailed.Wrap(err))
+	}
+}
+
+// AbortUpload drops the session and the parts uploaded so far.
+func (v *VideoController) AbortUpload(c *gin.Context) {
+	upload, ok := v.findUpload(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if err := v.bucket.AbortMultipartUpload(ctx, originalKey(upload.Filename), upload.UploadID); err != nil && !errors.Is(err, storage.ErrUploadNotFound) {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return
+	}
+	if err := v.repos.Uploads.Delete(ctx, upload.ID); err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "upload session"))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CleanupUploads aborts the uploads of expired sessions, and uploads the
+// bucket still holds without a session, e.g. after a failed insert. It
+// also fails videos whose processing never finished. It runs on a
+// schedule.
+func (v *VideoController) CleanupUploads(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
+	now := time.Now()
+
+	expired, err := v.repos.Uploads.ExpiredBefore(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, upload := range expired {
+		if err := v.bucket.AbortMultipartUpload(ctx, originalKey(upload.Filename), upload.UploadID); err != nil && !errors.Is(err, storage.ErrUploadNotFound) {
+			return err
+		}
+		if err := v.repos.Uploads.Delete(ctx, upload.ID); err != nil {
+			return err
+		}
+		metrics.AbortedUploads.Inc("expired")
+	}
+
+	// A live session is younger than its TTL, so anything older is orphaned
+	pending, err := v.bucket.ListMultipartUploads(ctx)
+	if err != nil {
+		return err
+	}
+	orphaned := 0
+	for _, upload := range pending {
+		if upload.Initiated.After(now.Add(-v.settings.S3.UploadSessionTTL)) {
+			continue
+		}
+		if err := v.bucket.AbortMultipartUpload(ctx, upload.Key, upload.UploadID); err != nil && !errors.Is(err, storage.ErrUploadNotFound) {
+			return err
+		}
+		metrics.AbortedUploads.Inc("orphaned")
+		orphaned++
+	}
+
+	timedOut, err := v.failUnfinished(ctx, now)
+	if err != nil {
+		return err
+	}
+	logger.Info("upload cleanup finished", "expired", len(expired), "orphaned", orphaned, "timed_out", timedOut)
+	return nil
+}
+
+// findUpload loads the :id session of the caller. It aborts the request
+// and returns false when there is none, or it expired.
+func (v *VideoController) findUpload(c *gin.Context) (*models.UploadSession, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		apperrors.Abort(c, apperrors.InvalidRequest("upload session id must be a positive integer"))
+		return nil, false
+	}
+	upload, err := v.repos.Uploads.ByID(c.Request.Context(), id)
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "upload session"))
+		return nil, false
+	}
+	_, claims := utils.GetTokenClaims(c)
+	if claims.Email != upload.OwnerEmail {
+		apperrors.Abort(c, apperrors.Forbidden("you can only use your own upload sessions"))
+		return nil, false
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		apperrors.Abort(c, apperrors.ErrUploadExpired)
+		return nil, false
+	}
+	return upload, true
+}
+
+// abortUpload is best effort; the cleanup catches what it misses.
+func (v *VideoController) abortUpload(ctx context.Context, filenameNoExt, uploadID string) {
+	if err := v.bucket.AbortMultipartUpload(ctx, originalKey(filenameNoExt), uploadID); err != nil {
+		logging.FromContext(ctx).Error("failed to abort multipart upload", "error", err)
+	}
+}
+
+func partSize(size int64) int64 {
+	partSize := int64(minPartSize)
+	if needed := (size + storage.MaxParts - 1) / storage.MaxParts; needed > partSize {
+		// Round up to whole MiB
+		partSize = (needed + 1<<20 - 1) &^ (1<<20 - 1)
+	}
+	return partSize
+}
+
+func queryInt(c *gin.Context, name string, fallback int64) (int64, error) {
+	raw, ok := c.GetQuery(name)
+	if !ok {
+		return fallback, nil
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
+
+func toUploadSessionDTO(upload *models.UploadSession) models.UploadSessionDTO {
+	return models.UploadSessionDTO{
+		ID:        upload.ID,
+		Size:      upload.Size,
+		PartSize:  upload.PartSize,
+		PartCount: upload.PartCount(),
+		ExpiresAt: upload.ExpiresAt,
+	}
+}
@@ -0,0 +1,192 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"video-service/apperrors"
+	"video-service/models"
+)
+
+// partSize is the smallest part the upload sessions hand out.
+const partSize = 8 << 20
+
+func (e *env) createUpload(size int) (models.UploadSessionDTO, string) {
+	e.t.Helper()
+	session := decode[models.UploadSessionDTO](e.t, e.send(http.MethodPost, "/api/v2/videos/uploads", ownerToken, map[string]any{
+		"filename": "cats.mp4", "size": size, "title": "Cats",
+	}), http.StatusCreated)
+	return session, "/api/v2/videos/uploads/" + strconv.FormatUint(uint64(session.ID), 10)
+}
+
+func TestDirectUpload(t *testing.T) {
+	e := newEnv(t)
+	file := bytes.Repeat([]byte{7}, partSize+1000)
+	copy(file, mp4)
+
+	session, sessionPath := e.createUpload(len(file))
+	if session.PartCount != 2 || session.PartSize != partSize || session.Size != int64(len(file)) {
+		t.Fatalf("session = %+v", session)
+	}
+
+	// Someone else cannot use the session
+	problem(t, e.get(sessionPath+"/parts", otherToken), http.StatusForbidden, apperrors.CodeForbidden)
+	problem(t, e.get(sessionPath+"/parts?first=3", ownerToken), http.StatusBadRequest, apperrors.CodeInvalidRequest)
+
+	parts := decode[struct {
+		Parts []models.UploadPartURL `json:"parts"`
+	}](t, e.get(sessionPath+"/parts", ownerToken), http.StatusOK).Parts
+	if len(parts) != 2 {
+		t.Fatalf("parts = %+v", parts)
+	}
+	etags := []string{}
+	for i, chunk := range [][]byte{file[:partSize], file[partSize:]} {
+		etag, err := e.putPart(parts[i].URL, chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		etags = append(etags, etag)
+	}
+
+	// A wrong ETag keeps the session open for another attempt
+	problem(t, e.send(http.MethodPost, sessionPath+"/complete", ownerToken, completion(etags[0], `"wrong"`)),
+		http.StatusBadRequest, apperrors.CodeInvalidRequest)
+	problem(t, e.send(http.MethodPost, sessionPath+"/complete", ownerToken, completion(etags[0])),
+		http.StatusBadRequest, apperrors.CodeInvalidRequest)
+
+	rec := e.send(http.MethodPost, sessionPath+"/complete", ownerToken, completion(etags...))
+	video := decode[models.VideoSearchResultDTO](t, rec, http.StatusCreated)
+	if rec.Header().Get("Location") != videoPath(video.ID) || rec.Header().Get("ETag") == "" {
+		t.Fatalf("Location %q, ETag %q", rec.Header().Get("Location"), rec.Header().Get("ETag"))
+	}
+	if video.Title != "Cats" || video.OwnerEmail != owner || video.Status != models.StatusUploaded {
+		t.Fatalf("video = %+v", video)
+	}
+	original, ok := e.bucket.Object("originals/" + video.Filename)
+	if !ok || !bytes.Equal(original.Body, file) {
+		t.Fatal("the video was not assembled from the parts")
+	}
+	// The session is gone
+	problem(t, e.get(sessionPath+"/parts", ownerToken), http.StatusNotFound, apperrors.CodeNotFound)
+
+	e.process(video.Filename)
+	if _, ok := e.bucket.Object(video.Filename + ".png"); !ok || e.video(video.ID).Status != models.StatusReady {
+		t.Fatal("the video was not processed")
+	}
+}
+
+func TestDirectUploadLimits(t *testing.T) {
+	e := newEnv(t)
+	problem(t, e.send(http.MethodPost, "/api/v2/videos/uploads", ownerToken, map[string]any{
+		"filename": "huge.mp4", "size": 21 << 20, "title": "Huge",
+	}), http.StatusRequestEntityTooLarge, apperrors.CodeTooLarge)
+	problem(t, e.send(http.MethodPost, "/api/v2/videos/uploads", adminToken, map[string]any{
+		"filename": "cats.mp4", "size": 100, "title": "Cats",
+	}), http.StatusForbidden, apperrors.CodeForbidden)
+	if uploads, _ := e.bucket.ListMultipartUploads(context.Background()); len(uploads) != 0 {
+		t.Fatalf("multipart uploads = %v", uploads)
+	}
+}
+
+// The part URLs are signed for their size, and completing checks what S3
+// stored in case a client got parts past that.
+func TestDirectUploadSizes(t *testing.T) {
+	e := newEnv(t)
+	file := bytes.Repeat([]byte{7}, partSize+1000)
+	copy(file, mp4)
+	_, sessionPath := e.createUpload(len(file))
+	parts := decode[struct {
+		Parts []models.UploadPartURL `json:"parts"`
+	}](t, e.get(sessionPath+"/parts?count=1", ownerToken), http.StatusOK).Parts
+	if _, err := e.putPart(parts[0].URL, file); err == nil {
+		t.Fatal("a part of another size was stored")
+	}
+	first, err := e.putPart(parts[0].URL, file[:partSize])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second part without a signed URL
+	extra := strings.Replace(parts[0].URL, "partNumber=1", "partNumber=2", 1)
+
+	second, err := e.putPart(extra, make([]byte, 2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := problem(t, e.send(http.MethodPost, sessionPath+"/complete", ownerToken, completion(first, second)),
+		http.StatusBadRequest, apperrors.CodeInvalidRequest); !strings.Contains(p.Detail, "declared") {
+		t.Fatalf("detail = %q", p.Detail)
+	}
+
+	second, err = e.putPart(extra, make([]byte, 21<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	problem(t, e.send(http.MethodPost, sessionPath+"/complete", ownerToken, completion(first, second)),
+		http.StatusRequestEntityTooLarge, apperrors.CodeTooLarge)
+	problem(t, e.get(sessionPath+"/parts", ownerToken), http.StatusNotFound, apperrors.CodeNotFound)
+	if uploads, _ := e.bucket.ListMultipartUploads(context.Background()); len(uploads) != 0 {
+		t.Fatalf("multipart uploads = %v", uploads)
+	}
+}
+
+func TestAbortUpload(t *testing.T) {
+	e := newEnv(t)
+	_, sessionPath := e.createUpload(100)
+
+	if rec := e.do(http.MethodDelete, sessionPath, ownerToken, "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	uploads, err := e.bucket.ListMultipartUploads(context.Background())
+	if err != nil || len(uploads) != 0 {
+		t.Fatalf("multipart uploads left: %v %v", uploads, err)
+	}
+	problem(t, e.do(http.MethodDelete, sessionPath, ownerToken, "", nil), http.StatusNotFound, apperrors.CodeNotFound)
+}
+
+func TestCleanupUploads(t *testing.T) {
+	e := newEnv(t)
+	expired, expiredPath := e.createUpload(100)
+	_, livePath := e.createUpload(100)
+	if err := e.db.Model(&models.UploadSession{}).Where("id = ?", expired.ID).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	problem(t, e.get(expiredPath+"/parts", ownerToken), http.StatusGone, apperrors.CodeUploadExpired)
+
+	if err := e.videos.CleanupUploads(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	problem(t, e.get(expiredPath+"/parts", ownerToken), http.StatusNotFound, apperrors.CodeNotFound)
+	if rec := e.get(livePath+"/parts", ownerToken); rec.Code != http.StatusOK {
+		t.Fatalf("the live session: status %d", rec.Code)
+	}
+	if uploads, _ := e.bucket.ListMultipartUploads(context.Background()); len(uploads) != 1 {
+		t.Fatalf("multipart uploads = %v", uploads)
+	}
+}
+
+// putPart stands in for the client's PUT to a presigned part URL.
+func (e *env) putPart(partURL string, body []byte) (string, error) {
+	e.t.Helper()
+	u, err := url.Parse(partURL)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	number, err := strconv.Atoi(u.Query().Get("partNumber"))
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return e.bucket.UploadPart(strings.TrimPrefix(u.Path, "/"), u.Query().Get("uploadId"), int32(number), body)
+}
+
+func completion(etags ...string) map[string]any {
+	parts := make([]map[string]any, len(etags))
+	for i, etag := range etags {
+		parts[i] = map[string]any{"partNumber": i + 1, "etag": etag}
+	}
+	return map[string]any{"parts": parts}
+}
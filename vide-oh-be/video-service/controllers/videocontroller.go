@@ -1,315 +1,422 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"math"
 	"math/rand"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
-	"video-service/database"
+	"video-service/apperrors"
+	"video-service/config"
+	"video-service/eventbus"
+	"video-service/logging"
+	"video-service/media"
+	"video-service/metrics"
 	"video-service/models"
+	"video-service/repository"
+	"video-service/storage"
+	"video-service/tracing"
 	"video-service/utils"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	s3Client      *s3.Client
-	bucketName    = "vide-oh-videos"
-	presignClient *s3.PresignClient
-)
+type VideoController struct {
+	repos    repository.Repositories
+	bucket   storage.Bucket
+	events   eventbus.Publisher
+	settings Settings
+}
 
-// S3 client
-func init() {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("eu-central-1"))
-	if err != nil {
-		log.Fatalf("unable to load SDK config, %v", err)
-	}
-	s3Client = s3.NewFromConfig(cfg)
-	presignClient = s3.NewPresignClient(s3Client)
+// Settings are the parts of the configuration the handlers use.
+type Settings struct {
+	S3         config.S3
+	Media      config.Media
+	Search     config.Search
+	Moderation config.Moderation
 }
 
-func StreamVideo(c *gin.Context) {
-	videoFilename := c.Param("name") + ".mp4"
+// NewVideoController takes the bucket and event bus as interfaces so tests
+// can pass storage.Memory and eventbus.Log.
+func NewVideoController(repos repository.Repositories, bucket storage.Bucket, events eventbus.Publisher, settings Settings) *VideoController {
+	return &VideoController{repos: repos, bucket: bucket, events: events, settings: settings}
+}
 
-	// Generate a pre-signed URL for the video object
-	req := &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(videoFilename),
-	}
+func (v *VideoController) presignedURL(ctx context.Context, key string) (string, error) {
+	return v.bucket.PresignGetObject(ctx, key, v.settings.S3.PresignTTL)
+}
 
-	presignedURL, err := presignClient.PresignGetObject(context.TODO(), req, func(p *s3.PresignOptions) {
-		p.Expires = 15 * time.Minute // URL valid for 15 minutes
-	})
+// StreamVideo always returns the .mp4 of a ready video, since v1 players
+// cannot play HLS; only v2 hands out the master playlist.
+func (v *VideoController) StreamVideo(c *gin.Context) {
+	video, err := v.repos.Videos.ByFilename(c.Request.Context(), c.Param("name"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate pre-signed URL"})
-		c.Abort()
+		apperrors.Abort(c, apperrors.FromDB(err, "video"))
+		return
+	}
+	if !video.Ready() {
+		apperrors.Abort(c, apperrors.NotFound("video"))
 		return
 	}
 
-	// Return the pre-signed URL
-	c.JSON(http.StatusOK, gin.H{"url": presignedURL.URL})
+	stream, err := v.progressiveStream(c, video)
+	if err != nil {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return
+	}
+	v.countView(c, video)
+	c.JSON(http.StatusOK, stream)
 }
 
-func ReportVideo(context *gin.Context) {
-	videoId := context.Param("id")
-	var video models.Video
-
-	if err := database.Instance.First(&video, videoId).Error; err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		context.Abort()
+// ReportVideo files the user's report with the "other" category. Unlike
+// v2 it answers 200 when the user already reported the video.
+func (v *VideoController) ReportVideo(context *gin.Context) {
+	videoId, err := strconv.ParseUint(context.Param("id"), 10, 64)
+	if err != nil {
+		apperrors.Abort(context, apperrors.InvalidRequest("video id must be a positive integer"))
 		return
 	}
 
-	video.Reported = true
-
-	database.Instance.Save(&video)
+	_, claims := utils.GetTokenClaims(context)
+	if _, err := v.report(context.Request.Context(), videoId, claims, "other", ""); err != nil {
+		apperrors.Abort(context, err)
+		return
+	}
 
 	context.Status(http.StatusOK)
 }
 
-func GetAllReportedVideos(c *gin.Context) {
+func (v *VideoController) GetAllReportedVideos(c *gin.Context) {
 	_, claims := utils.GetTokenClaims(c)
 	if claims.Role != "Administrator" {
-		c.JSON(401, gin.H{"error": "unauthorized role"})
-		c.Abort()
+		apperrors.Abort(c, apperrors.Forbidden("administrator role required"))
 		return
 	}
 
-	var videos []models.Video
-	database.Instance.Where("reported = ?", true).Find(&videos)
+	videos, err := v.repos.Videos.Reported(c.Request.Context())
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "video"))
+		return
+	}
 
 	var videoSearchResults []models.VideoSearchResultDTO
 
 	// Generate pre-signed URLs for thumbnails and map the original videos to DTO
 	for _, video := range videos {
-		req := &s3.GetObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(video.Filename + ".png"),
-		}
-		presignedURL, err := presignClient.PresignGetObject(context.TODO(), req, func(p *s3.PresignOptions) {
-			p.Expires = 15 * time.Minute
-		})
+		result, err := v.videoDTO(c, video)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate pre-signed URL"})
-			c.Abort()
+			apperrors.Abort(c, apperrors.Internal(err))
 			return
 		}
 
-		videoSearchResults = append(videoSearchResults, toVideoSearchResultDTO(video, presignedURL.URL))
+		videoSearchResults = append(videoSearchResults, result)
 	}
 
 	c.JSON(http.StatusOK, videoSearchResults)
 }
 
-func UploadVideo(c *gin.Context) {
+func (v *VideoController) UploadVideo(c *gin.Context) {
+	title, description := c.Query("title"), c.Query("description")
+	if err := validateText(&title, &description); err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	video := v.upload(c, title, description, false)
+	if video == nil {
+		return
+	}
+	c.String(http.StatusOK, fmt.Sprintf("'%s' uploaded!", video.Filename+".mp4"))
+}
+
+// upload creates the video row and stores the multipart "file" under
+// originals/; processing converts it to the .mp4 and extracts the
+// thumbnail. It aborts the request and returns nil on failure.
+func (v *VideoController) upload(c *gin.Context, title, description string, keepOriginal bool) *models.Video {
+	start := time.Now()
 	_, claims := utils.GetTokenClaims(c)
 	if claims.Role != "RegisteredUser" {
-		c.JSON(401, gin.H{"error": "unauthorized role"})
-		c.Abort()
-		return
+		metrics.Uploads.Inc("rejected")
+		apperrors.Abort(c, apperrors.Forbidden("only registered users can upload videos"))
+		return nil
 	}
 
 	// single file
 	file, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to retrieve file"})
-		return
+		metrics.Uploads.Inc("rejected")
+		apperrors.Abort(c, apperrors.InvalidRequest(`multipart field "file" is required`).Wrap(err))
+		return nil
 	}
 
-	if filepath.Ext(file.Filename) != ".mp4" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid extension"})
-		c.Abort()
-		return
+	// The content decides, not the extension
+	container, err := sniff(file)
+	if err != nil {
+		metrics.Uploads.Inc("failed")
+		apperrors.Abort(c, apperrors.ErrUploadFailed.Wrap(err))
+		return nil
+	}
+	if container == "" {
+		metrics.Uploads.Inc("rejected")
+		apperrors.Abort(c, apperrors.ErrUnsupportedMediaType)
+		return nil
 	}
 
-	// Generate random filename
-	rand.Seed(time.Now().UnixNano())
-	rndNum := rand.Intn(math.MaxInt32-0) + 0
-	filenameNoExt := strconv.Itoa(rndNum)
-	videoFilename := filenameNoExt + ".mp4"
+	filenameNoExt := newFilename()
+	key := originalKey(filenameNoExt)
 
-	// Save video file to temporary Lambda storage
-	err = c.SaveUploadedFile(file, "/tmp/"+videoFilename)
-	if err != nil {
-		fmt.Println(err.Error())
+	ctx := c.Request.Context()
+	logger := logging.FromContext(ctx)
+
+	// The row comes first, so the S3 event always finds it
+	video := &models.Video{
+		Title:        title,
+		Description:  description,
+		OwnerEmail:   claims.Email,
+		Filename:     filenameNoExt,
+		Status:       models.StatusUploaded,
+		KeepOriginal: keepOriginal,
+	}
+	if err := v.repos.Videos.Create(ctx, video); err != nil {
+		metrics.Uploads.Inc("failed")
+		apperrors.Abort(c, apperrors.FromDB(err, "video"))
+		return nil
 	}
 
-	// Generate thumbnail from the video file
-	err = generateVideoThumbnailFromFile(filenameNoExt)
-	if err != nil {
-		log.Println("Failed to generate thumbnail:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate thumbnail"})
-		c.Abort()
-		return
+	if err := v.store(ctx, file, key, media.ContentTypes[container]); err != nil {
+		metrics.Uploads.Inc("failed")
+		if err := v.fail(ctx, video.ID, models.StatusUploaded, "the file could not be stored"); err != nil {
+			logger.Error("failed to mark video as failed", "video_id", video.ID, "error", err)
+		}
+		apperrors.Abort(c, apperrors.ErrUploadFailed.Wrap(err))
+		return nil
+	}
+	if !v.checkUpload(c, video) {
+		return nil
 	}
+	logger.Info("video uploaded", "video_id", video.ID, "key", key, "container", container, "size_bytes", file.Size)
+	metrics.Uploads.Inc("success")
+	metrics.UploadDuration.Observe(time.Since(start).Seconds())
+	metrics.UploadSize.Observe(float64(file.Size))
+
+	v.processInline(ctx, *video)
+	return video
+}
 
-	// Open the video file to upload to S3
+// sniff names the container of the uploaded file, or returns "" when it
+// is not accepted.
+func sniff(file *multipart.FileHeader) (string, error) {
 	src, err := file.Open()
 	if err != nil {
-		log.Println("Failed to reopen video file for upload:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open video file"})
-		return
+		return "", err
 	}
 	defer src.Close()
+	head := make([]byte, media.SniffLen)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return media.Sniff(head[:n]), nil
+}
 
-	// Upload video file to S3
-	err = uploadToS3(src, videoFilename, "video/mp4")
+// store streams the uploaded file to the bucket. Gin keeps small files in
+// memory and larger ones in a temporary file.
+func (v *VideoController) store(ctx context.Context, file *multipart.FileHeader, key, contentType string) error {
+	src, err := file.Open()
 	if err != nil {
-		log.Println("Failed to upload video to S3:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload video to S3"})
-		return
+		return err
 	}
+	defer src.Close()
+	return v.bucket.PutObject(ctx, key, src, contentType)
+}
 
-	// Save video metadata to DB
-	video := &models.Video{
-		Title:       c.Query("title"),
-		Description: c.Query("description"),
-		OwnerEmail:  claims.Email,
-		Filename:    filenameNoExt,
-	}
-	database.Instance.Save(&video)
+// Uploads are kept under originals/ until processing has written the
+// normalized <filename>.mp4 next to the thumbnail.
+const originalsPrefix = "originals/"
 
-	c.String(http.StatusOK, fmt.Sprintf("'%s' uploaded!", videoFilename))
+func originalKey(filenameNoExt string) string {
+	return originalsPrefix + filenameNoExt
 }
 
-func uploadToS3(file multipart.File, filename, contentType string) error {
-	// Read file content
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(file)
-
-	// Upload to S3
-	_, err := s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket:      aws.String(bucketName),
-		Key:         aws.String(filename),
-		Body:        bytes.NewReader(buf.Bytes()),
-		ContentType: aws.String(contentType),
-		ACL:         types.ObjectCannedACLPrivate,
-	})
-	return err
+// newFilename returns a random object name, without the extension.
+func newFilename() string {
+	rand.Seed(time.Now().UnixNano())
+	rndNum := rand.Intn(math.MaxInt32-0) + 0
+	return strconv.Itoa(rndNum)
 }
 
-func generateVideoThumbnailFromFile(filenameNoExt string) error {
-	// Use /tmp for temporary file storage in Lambda
-	videoPath := fmt.Sprintf("/tmp/%s.mp4", filenameNoExt)
-	outputFilePath := fmt.Sprintf("/tmp/%s.png", filenameNoExt)
+// errNoFrame is returned by generateThumbnail when input has no frame at
+// the requested time.
+var errNoFrame = errors.New("no frame at that time")
 
-	// Generate the thumbnail using ffmpeg
-	cmd := fmt.Sprintf(`./ffmpeg -i "%s" -an -q 0 -vf "scale='if(gt(iw\,ih)\,-1\,200)':'if(gt(iw\,ih)\,200\,-1)',crop=200:200:exact=1" -vframes 1 "%s"`, videoPath, outputFilePath)
-	shellName := "bash"
-	ffCmd := exec.Command(shellName, "-c", cmd)
-	output, err := ffCmd.CombinedOutput()
+// generateThumbnail grabs the frame at at of input, a local path or a URL
+// ffmpeg can read, and stores it as <filenameNoExt>.png.
+func (v *VideoController) generateThumbnail(ctx context.Context, input, filenameNoExt string, at time.Duration) error {
+	thumbnail, err := v.renderThumbnail(ctx, input, filenameNoExt, at)
 	if err != nil {
-		log.Println(fmt.Sprint(err) + ": " + string(output))
 		return err
 	}
-	log.Println(string(output))
+	defer removeThumbnail(ctx, thumbnail)
+	return v.storeThumbnail(ctx, thumbnail, filenameNoExt+".png")
+}
+
+// renderThumbnail writes the frame at at of input to a temporary PNG file
+// and returns its path; the caller removes it with removeThumbnail.
+func (v *VideoController) renderThumbnail(ctx context.Context, input, filenameNoExt string, at time.Duration) (string, error) {
+	logger := logging.FromContext(ctx)
 
-	// Upload thumbnail to S3
-	thumbnailFile, err := os.Open(outputFilePath)
+	// Use /tmp for temporary file storage in Lambda
+	outputFile, err := os.CreateTemp("", filenameNoExt+"-*.png")
 	if err != nil {
-		log.Println("Failed to open thumbnail file:", err)
-		return err
+		return "", err
 	}
-	defer thumbnailFile.Close()
-	err = uploadToS3(thumbnailFile, fmt.Sprintf("%s.png", filenameNoExt), "image/png")
+	outputFile.Close()
+	outputFilePath := outputFile.Name()
+
+	// Generate the thumbnail using ffmpeg; -ss before -i seeks the input
+	// rather than decoding up to at
+	args := []string{"-y"}
+	if at > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(at.Seconds(), 'f', 3, 64))
+	}
+	filter := `scale='if(gt(iw\,ih)\,-1\,200)':'if(gt(iw\,ih)\,200\,-1)',crop=200:200:exact=1`
+	args = append(args, "-i", input, "-an", "-q", "0", "-vf", filter, "-vframes", "1", outputFilePath)
+	ffmpegCtx, span := tracing.Start(ctx, "ffmpeg thumbnail")
+	ffmpegStart := time.Now()
+	ffCmd := exec.CommandContext(ffmpegCtx, media.Binary, args...)
+	output, err := ffCmd.CombinedOutput()
+	tracing.End(span, err)
+	metrics.ThumbnailDuration.Observe(time.Since(ffmpegStart).Seconds())
 	if err != nil {
-		return err
+		logger.Error("ffmpeg failed", "error", err, "output", string(output))
+		removeThumbnail(ctx, outputFilePath)
+		return "", err
 	}
+	logger.Debug("ffmpeg finished", "output", string(output))
 
-	// Clean up the temporary files
-	if err := os.Remove(videoPath); err != nil {
-		log.Println("Failed to delete video file:", err)
-		return err
+	// ffmpeg succeeds without output when seeking past the last frame
+	if info, err := os.Stat(outputFilePath); err != nil || info.Size() == 0 {
+		removeThumbnail(ctx, outputFilePath)
+		if err == nil {
+			err = errNoFrame
+		}
+		return "", err
 	}
-	if err := os.Remove(outputFilePath); err != nil {
-		log.Println("Failed to delete thumbnail file:", err)
+	return outputFilePath, nil
+}
+
+// storeThumbnail uploads a rendered thumbnail as key.
+func (v *VideoController) storeThumbnail(ctx context.Context, thumbnail, key string) error {
+	thumbnailFile, err := os.Open(thumbnail)
+	if err != nil {
 		return err
 	}
-
-	return nil
+	defer thumbnailFile.Close()
+	return v.bucket.PutObject(ctx, key, thumbnailFile, "image/png")
 }
 
-func DeleteVideo(context *gin.Context) {
-	videoId := context.Param("id")
-	var video models.Video
+func removeThumbnail(ctx context.Context, thumbnail string) {
+	if err := os.Remove(thumbnail); err != nil {
+		logging.FromContext(ctx).Error("failed to delete thumbnail file", "error", err)
+	}
+}
 
-	if err := database.Instance.First(&video, videoId).Error; err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		context.Abort()
+func (v *VideoController) DeleteVideo(context *gin.Context) {
+	videoId, err := strconv.ParseUint(context.Param("id"), 10, 64)
+	if err != nil {
+		apperrors.Abort(context, apperrors.InvalidRequest("video id must be a positive integer"))
 		return
 	}
 
 	_, claims := utils.GetTokenClaims(context)
-	if claims.Role == "RegisteredUser" && claims.Email != video.OwnerEmail {
-		context.JSON(401, gin.H{"error": "you are not authorized to delete this video"})
-		context.Abort()
+	if err := v.delete(context.Request.Context(), videoId, claims, "", ""); err != nil {
+		apperrors.Abort(context, err)
 		return
 	}
 
-	database.Instance.Delete(&models.Video{}, videoId)
-
 	context.Status(http.StatusOK)
 }
 
+// videoDTO presigns the thumbnail and storyboard links of video.
+func (v *VideoController) videoDTO(c *gin.Context, video models.Video) (models.VideoSearchResultDTO, error) {
+	thumbnailURL, err := v.presignedURL(c.Request.Context(), video.ThumbnailKey())
+	if err != nil {
+		return models.VideoSearchResultDTO{}, err
+	}
+	result := toVideoSearchResultDTO(video, thumbnailURL)
+	result.Storyboard, err = v.storyboardDTO(c, &video)
+	return result, err
+}
+
 func toVideoSearchResultDTO(video models.Video, thumbnailURL string) models.VideoSearchResultDTO {
 	return models.VideoSearchResultDTO{
-		ID:           video.ID,
-		Title:        video.Title,
-		Filename:     video.Filename,
-		Description:  video.Description,
-		OwnerEmail:   video.OwnerEmail,
-		Reported:     video.Reported,
-		ThumbnailURL: thumbnailURL,
+		ID:            video.ID,
+		Title:         video.Title,
+		Filename:      video.Filename,
+		Description:   video.Description,
+		OwnerEmail:    video.OwnerEmail,
+		Reported:      video.Reported,
+		Hidden:        video.Hidden,
+		ThumbnailURL:  thumbnailURL,
+		Status:        video.Status,
+		Views:         video.Views,
+		VideoMetadata: video.VideoMetadata,
 	}
 }
 
-func SearchVideos(c *gin.Context) {
-	var videos []models.Video
-	searchQuery := c.Query("query")
-
-	if searchQuery == "" {
-		database.Instance.Find(&videos)
-	} else {
-		searchQuery = "%" + strings.ToLower(searchQuery) + "%"
-		database.Instance.Where("lower(title) LIKE ?", searchQuery).
-			Or("lower(description) LIKE ?", searchQuery).
-			Or("owner_email LIKE ?", searchQuery).
-			Find(&videos)
+// SearchVideos returns every match at once, without highlights; v2 pages
+// through them.
+func (v *VideoController) SearchVideos(c *gin.Context) {
+	search := models.VideoSearch{
+		Text:     strings.TrimSpace(c.Query("query")),
+		Language: v.settings.Search.Language,
+		Sort:     models.SortDate,
+	}
+	if search.Text != "" {
+		search.Sort = models.SortRelevance
+	}
+	videos, err := v.repos.Videos.Search(c.Request.Context(), search)
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "video"))
+		return
 	}
 
 	var videoSearchResults []models.VideoSearchResultDTO
 
 	// Generate pre-signed URLs for thumbnails and map the original videos to DTO
 	for _, video := range videos {
-		req := &s3.GetObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(video.Filename + ".png"),
-		}
-		presignedURL, err := presignClient.PresignGetObject(context.TODO(), req, func(p *s3.PresignOptions) {
-			p.Expires = 15 * time.Minute
-		})
+		result, err := v.videoDTO(c, video.Video)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate pre-signed URL"})
-			c.Abort()
+			apperrors.Abort(c, apperrors.Internal(err))
 			return
 		}
 
-		videoSearchResults = append(videoSearchResults, toVideoSearchResultDTO(video, presignedURL.URL))
+		videoSearchResults = append(videoSearchResults, result)
 	}
 
 	c.JSON(http.StatusOK, videoSearchResults)
 }
+
+// update loads and locks the video, applies change and saves it in one
+// transaction. A change that returns an error rolls it back.
+func (v *VideoController) update(ctx context.Context, id uint64, change func(*models.Video) error) (*models.Video, error) {
+	var video *models.Video
+	err := v.repos.UnitOfWork.Do(ctx, func(tx repository.Repositories) error {
+		var err error
+		if video, err = tx.Videos.ForUpdate().ByID(ctx, id); err != nil {
+			return err
+		}
+		if err := change(video); err != nil {
+			return err
+		}
+		return tx.Videos.Save(ctx, video)
+	})
+	return video, err
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"video-service/apperrors"
+	"video-service/etag"
+	"video-service/models"
+	"video-service/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The /api/v2 handlers. Videos are addressed by ID, lists are never null
+// and single videos carry an ETag.
+
+func (v *VideoController) ListReportedVideos(c *gin.Context) {
+	_, claims := utils.GetTokenClaims(c)
+	if claims.Role != "Administrator" {
+		apperrors.Abort(c, apperrors.Forbidden("administrator role required"))
+		return
+	}
+
+	videos, err := v.repos.Videos.Reported(c.Request.Context())
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "video"))
+		return
+	}
+	v.writeVideos(c, videos)
+}
+
+func (v *VideoController) GetVideo(c *gin.Context) {
+	video, ok := v.find(c)
+	if !ok {
+		return
+	}
+	if etag.NotModified(c, etag.Of(video.ID, video.UpdatedAt)) {
+		return
+	}
+	v.writeVideo(c, http.StatusOK, video)
+}
+
+func (v *VideoController) GetStream(c *gin.Context) {
+	video, ok := v.find(c)
+	if !ok {
+		return
+	}
+	stream, err := v.stream(c, video)
+	if err != nil {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return
+	}
+	v.countView(c, video)
+	c.JSON(http.StatusOK, stream)
+}
+
+// CreateVideo takes the title, description and keepOriginal as form
+// fields next to the file.
+func (v *VideoController) CreateVideo(c *gin.Context) {
+	keepOriginal, err := strconv.ParseBool(c.DefaultPostForm("keepOriginal", "false"))
+	if err != nil {
+		apperrors.Abort(c, apperrors.InvalidRequest("keepOriginal must be true or false"))
+		return
+	}
+	title, description := c.PostForm("title"), c.PostForm("description")
+	if err := validateText(&title, &description); err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	video := v.upload(c, title, description, keepOriginal)
+	if video == nil {
+		return
+	}
+	c.Header("Location", strings.TrimSuffix(c.FullPath(), "/")+"/"+strconv.FormatUint(uint64(video.ID), 10))
+	v.writeVideo(c, http.StatusCreated, video)
+}
+
+// RemoveVideo honours If-Match when it is sent. The optional reason query
+// parameter goes into the mail to the owner when someone else deletes it.
+func (v *VideoController) RemoveVideo(c *gin.Context) {
+	id, ok := videoID(c)
+	if !ok {
+		return
+	}
+	_, claims := utils.GetTokenClaims(c)
+	if err := v.delete(c.Request.Context(), id, claims, c.GetHeader("If-Match"), c.Query("reason")); err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func videoID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		apperrors.Abort(c, apperrors.InvalidRequest("video id must be a positive integer"))
+		return 0, false
+	}
+	return id, true
+}
+
+// find loads the :id video for the public routes, which only see ready
+// videos.
+func (v *VideoController) find(c *gin.Context) (*models.Video, bool) {
+	id, ok := videoID(c)
+	if !ok {
+		return nil, false
+	}
+	video, err := v.repos.Videos.ByID(c.Request.Context(), id)
+	if err != nil {
+		apperrors.Abort(c, apperrors.FromDB(err, "video"))
+		return nil, false
+	}
+	if !video.Ready() {
+		apperrors.Abort(c, apperrors.NotFound("video"))
+		return nil, false
+	}
+	return video, true
+}
+
+func (v *VideoController) writeVideo(c *gin.Context, status int, video *models.Video) {
+	result, err := v.videoDTO(c, *video)
+	if err != nil {
+		apperrors.Abort(c, apperrors.Internal(err))
+		return
+	}
+	etag.Set(c, etag.Of(video.ID, video.UpdatedAt))
+	c.JSON(status, result)
+}
+
+func (v *VideoController) writeVideos(c *gin.Context, videos []models.Video) {
+	results := make([]models.VideoSearchResultDTO, 0, len(videos))
+	for _, video := range videos {
+		result, err := v.videoDTO(c, video)
+		if err != nil {
+			apperrors.Abort(c, apperrors.Internal(err))
+			return
+		}
+		results = append(results, result)
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// updateError keeps the typed errors of a change and maps the rest as
+// database errors.
+func updateError(err error) error {
+	var appErr *apperrors.Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return apperrors.FromDB(err, "video")
+}
@@ -1,8 +1,13 @@
 package database
 
 import (
+	"database/sql"
 	"log"
+	"time"
+	"video-service/config"
 	"video-service/models"
+	"video-service/repository"
+	"video-service/tracing"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -11,17 +16,72 @@ import (
 var Instance *gorm.DB
 var dbError error
 
-func Connect(connectionString string) {
-	Instance, dbError = gorm.Open(postgres.Open(connectionString), &gorm.Config{})
+// searchLanguage is the text search configuration the search index is
+// built for
+var searchLanguage string
+
+func Connect(credentials CredentialSource) {
+	connector, err := newConnector(credentials)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sqlDB := sql.OpenDB(connector)
+	// Recycle connections so long-lived Lambdas don't hold sessions
+	// authenticated with retired credentials forever
+	sqlDB.SetConnMaxLifetime(30 * time.Minute)
+
+	open(postgres.New(postgres.Config{Conn: sqlDB}))
+}
+
+// Open connects to the database selected by DB_DRIVER: SQLite in local
+// mode, Postgres with the configured credentials otherwise.
+func Open(cfg *config.Config) {
+	searchLanguage = cfg.Search.Language
+	if cfg.DatabaseDriver == config.DBDriverSQLite {
+		ConnectSQLite(cfg.DatabaseDSN)
+		return
+	}
+	credentials, err := NewCredentialSource(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	Connect(credentials)
+}
+
+func open(dialector gorm.Dialector) {
+	// TranslateError turns unique violations into gorm.ErrDuplicatedKey on
+	// both dialects. Postgres keeps microseconds, so timestamps are cut to
+	// that precision before saving and a row's ETag survives a reload.
+	Instance, dbError = gorm.Open(dialector, &gorm.Config{
+		TranslateError: true,
+		NowFunc:        func() time.Time { return time.Now().Truncate(time.Microsecond) },
+	})
 	if dbError != nil {
 		log.Fatal(dbError)
 		panic("Cannot connect to DB")
 	}
+	if err := Instance.Use(tracing.GormPlugin{}); err != nil {
+		log.Fatal(err)
+	}
 	log.Println("Connected to Database!")
 }
 
+// Migrate drops every table of the service, with its data, and creates
+// them again.
 func Migrate() {
-	Instance.Migrator().DropTable("videos")
-	Instance.AutoMigrate(&models.Video{})
+	// The edit history and the reports reference the videos
+	Instance.Migrator().DropTable("video_edits", "video_reports", "videos", "upload_sessions")
+	AutoMigrate()
+}
+
+// AutoMigrate creates or updates the tables without dropping existing data.
+func AutoMigrate() {
+	if err := Instance.AutoMigrate(&models.Video{}, &models.UploadSession{}, &models.VideoEdit{}, &models.VideoReport{}); err != nil {
+		log.Fatal(err)
+	}
+	if err := repository.CreateSearchIndex(Instance, searchLanguage); err != nil {
+		log.Println("Failed to create the search index:", err)
+	}
+	recordSchemaVersion()
 	log.Println("Database Migration Completed!")
 }
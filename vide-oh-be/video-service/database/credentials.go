@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+	"video-service/config"
+	"video-service/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	rdsauth "github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// CredentialSource supplies the DSN for every new connection. Invalidate is
+// called when Postgres rejects the credentials so the next DSN call fetches
+// fresh ones, e.g. after Secrets Manager rotated the password.
+type CredentialSource interface {
+	DSN(ctx context.Context) (string, error)
+	Invalidate()
+}
+
+// NewCredentialSource picks the source matching the configuration: a fixed
+// DSN in local mode, otherwise the RDS secret or IAM auth tokens.
+func NewCredentialSource(cfg *config.Config) (CredentialSource, error) {
+	if cfg.Local {
+		return StaticCredentials(cfg.DatabaseDSN), nil
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.TODO(), awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	tracing.AppendAWSMiddlewares(&awsCfg.APIOptions)
+
+	switch cfg.DB.AuthMode {
+	case config.DBAuthIAM:
+		return IAMCredentials(awsCfg, cfg.DB.Host, cfg.DB.Port, cfg.DB.User, cfg.DB.Name), nil
+	default:
+		return SecretCredentials(secretsmanager.NewFromConfig(awsCfg), cfg.DBSecretName, cfg.DB.SecretTTL), nil
+	}
+}
+
+type staticCredentials string
+
+func StaticCredentials(dsn string) CredentialSource {
+	return staticCredentials(dsn)
+}
+
+func (dsn staticCredentials) DSN(ctx context.Context) (string, error) {
+	return string(dsn), nil
+}
+
+func (dsn staticCredentials) Invalidate() {}
+
+type DBSecret struct {
+	Host     string `json:"host"`
+	Port     int16  `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	DBName   string `json:"dbname"`
+}
+
+func (secret DBSecret) DSN() string {
+	return fmt.Sprintf("postgresql://%s:%s@%s:%d/%s",
+		secret.Username,
+		url.QueryEscape(secret.Password),
+		secret.Host,
+		secret.Port,
+		secret.DBName,
+	)
+}
+
+type secretCredentials struct {
+	client     *secretsmanager.Client
+	secretName string
+	ttl        time.Duration
+
+	mu        sync.Mutex
+	dsn       string
+	fetchedAt time.Time
+}
+
+// SecretCredentials reads the RDS secret from Secrets Manager and caches it
+// for ttl, so rotated passwords are picked up without a cold start.
+func SecretCredentials(client *secretsmanager.Client, secretName string, ttl time.Duration) CredentialSource {
+	return &secretCredentials{client: client, secretName: secretName, ttl: ttl}
+}
+
+func (s *secretCredentials) DSN(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dsn != "" && time.Since(s.fetchedAt) < s.ttl {
+		return s.dsn, nil
+	}
+
+	result, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(s.secretName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve secret %s: %v", s.secretName, err)
+	}
+	var secret DBSecret
+	if err := json.Unmarshal([]byte(*result.SecretString), &secret); err != nil {
+		return "", fmt.Errorf("failed to unmarshal secret %s: %v", s.secretName, err)
+	}
+
+	s.dsn = secret.DSN()
+	s.fetchedAt = time.Now()
+	return s.dsn, nil
+}
+
+func (s *secretCredentials) Invalidate() {
+	s.mu.Lock()
+	s.dsn = ""
+	s.mu.Unlock()
+}
+
+// IAM auth tokens are valid for 15 minutes; refresh a little earlier
+const iamTokenTTL = 10 * time.Minute
+
+type iamCredentials struct {
+	awsCfg aws.Config
+	host   string
+	port   int
+	user   string
+	dbName string
+
+	mu        sync.Mutex
+	dsn       string
+	fetchedAt time.Time
+}
+
+// IAMCredentials authenticates with short-lived RDS IAM tokens instead of a
+// static password. This also works against RDS Proxy.
+func IAMCredentials(awsCfg aws.Config, host string, port int, user, dbName string) CredentialSource {
+	return &iamCredentials{awsCfg: awsCfg, host: host, port: port, user: user, dbName: dbName}
+}
+
+func (s *iamCredentials) DSN(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dsn != "" && time.Since(s.fetchedAt) < iamTokenTTL {
+		return s.dsn, nil
+	}
+
+	endpoint := s.host + ":" + strconv.Itoa(s.port)
+	token, err := rdsauth.BuildAuthToken(ctx, endpoint, s.awsCfg.Region, s.user, s.awsCfg.Credentials)
+	if err != nil {
+		return "", fmt.Errorf("failed to build IAM auth token: %v", err)
+	}
+
+	s.dsn = fmt.Sprintf("postgresql://%s:%s@%s:%d/%s?sslmode=require",
+		url.QueryEscape(s.user),
+		url.QueryEscape(token),
+		s.host,
+		s.port,
+		s.dbName,
+	)
+	s.fetchedAt = time.Now()
+	return s.dsn, nil
+}
+
+func (s *iamCredentials) Invalidate() {
+	s.mu.Lock()
+	s.dsn = ""
+	s.mu.Unlock()
+}
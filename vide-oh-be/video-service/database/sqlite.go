@@ -0,0 +1,35 @@
+//go:build sqlite
+
+package database
+
+import (
+	"log"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+)
+
+// ConnectSQLite opens the SQLite file at path, or an in-memory database
+// for ":memory:". SQLite is compiled in with -tags sqlite only, which keeps
+// the Lambda binaries small.
+func ConnectSQLite(path string) {
+	if path == ":memory:" {
+		// Every pooled connection would get its own empty database
+		path = "file::memory:?cache=shared"
+	}
+	open(sqlite.Open(path + pragmas(path)))
+	sqlDB, err := Instance.DB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// SQLite allows a single writer
+	sqlDB.SetMaxOpenConns(1)
+}
+
+func pragmas(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+}
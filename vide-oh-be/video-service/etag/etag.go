@@ -0,0 +1,63 @@
+// Package etag implements the conditional requests of the v2 API. A tag
+// is derived from the row ID and UpdatedAt, so every save changes it and
+// it can be checked inside the transaction that writes the row.
+package etag
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"video-service/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Of derives the tag at microsecond precision, the resolution Postgres
+// stores, so a value read back matches the one that was just written.
+func Of(id uint, updatedAt time.Time) string {
+	return `"` + strconv.FormatUint(uint64(id), 36) + "-" + strconv.FormatInt(updatedAt.Truncate(time.Microsecond).UnixNano(), 36) + `"`
+}
+
+// Set writes the ETag header of the response.
+func Set(context *gin.Context, tag string) {
+	context.Header("ETag", tag)
+}
+
+// NotModified answers 304 when If-None-Match lists tag. The caller stops
+// handling the request when it returns true.
+func NotModified(context *gin.Context, tag string) bool {
+	if !matches(context.GetHeader("If-None-Match"), tag) {
+		return false
+	}
+	Set(context, tag)
+	context.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+// Check compares the If-Match header with the current tag. A missing
+// header is an error only when required; "*" matches any version.
+func Check(ifMatch, current string, required bool) error {
+	if ifMatch == "" {
+		if required {
+			return apperrors.PreconditionRequired("If-Match header with the ETag of the resource is required")
+		}
+		return nil
+	}
+	if !matches(ifMatch, current) {
+		return apperrors.PreconditionFailed("the resource was changed since it was read; fetch it again")
+	}
+	return nil
+}
+
+// matches reports whether the comma separated header lists tag. Weak
+// validators compare equal to their strong form.
+func matches(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
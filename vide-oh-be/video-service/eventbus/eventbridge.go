@@ -0,0 +1,60 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"video-service/config"
+	"video-service/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+// EventBridge puts the events on a custom bus.
+type EventBridge struct {
+	client *eventbridge.Client
+	bus    string
+}
+
+// Open returns the publisher for the bus in the service configuration, or
+// Log when none is set.
+func Open(cfg *config.Config) (Publisher, error) {
+	if cfg.Events.BusName == "" {
+		return Log{}, nil
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.TODO(), awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	tracing.AppendAWSMiddlewares(&awsCfg.APIOptions)
+	return NewEventBridge(awsCfg, cfg.Events.BusName), nil
+}
+
+// NewEventBridge uses the endpoint resolution of the SDK, so
+// AWS_ENDPOINT_URL points it at e.g. LocalStack.
+func NewEventBridge(awsCfg aws.Config, bus string) *EventBridge {
+	return &EventBridge{client: eventbridge.NewFromConfig(awsCfg), bus: bus}
+}
+
+func (e *EventBridge) Publish(ctx context.Context, detailType string, detail any) error {
+	payload, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	out, err := e.client.PutEvents(ctx, &eventbridge.PutEventsInput{Entries: []types.PutEventsRequestEntry{{
+		Source:       aws.String(Source),
+		DetailType:   aws.String(detailType),
+		Detail:       aws.String(string(payload)),
+		EventBusName: aws.String(e.bus),
+	}}})
+	if err != nil {
+		return err
+	}
+	if out.FailedEntryCount > 0 && len(out.Entries) > 0 {
+		return fmt.Errorf("PutEvents: %s: %s", aws.ToString(out.Entries[0].ErrorCode), aws.ToString(out.Entries[0].ErrorMessage))
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Package eventbus publishes the domain events of the video service, e.g.
+// VideoDeleted, which other services consume to drop their own data about
+// a video.
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+	"video-service/logging"
+)
+
+// Source of every event published by the service.
+const Source = "vide-oh.videos"
+
+const TypeVideoDeleted = "VideoDeleted"
+
+// VideoDeleted is published once a video is deleted, by its owner or by
+// an administrator or support.
+type VideoDeleted struct {
+	VideoID    uint   `json:"videoId"`
+	OwnerEmail string `json:"ownerEmail"`
+	// Email and role of whoever deleted it
+	DeletedBy     string    `json:"deletedBy"`
+	DeletedByRole string    `json:"deletedByRole"`
+	DeletedAt     time.Time `json:"deletedAt"`
+}
+
+// Publisher sends an event with detail as its JSON payload.
+type Publisher interface {
+	Publish(ctx context.Context, detailType string, detail any) error
+}
+
+// Log only logs the events, for local mode and tests.
+type Log struct{}
+
+func (Log) Publish(ctx context.Context, detailType string, detail any) error {
+	body, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("event published", "detail_type", detailType, "detail", slog.StringValue(string(body)))
+	return nil
+}
@@ -0,0 +1,47 @@
+package health
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+const service = "video-service"
+
+// Version and Commit are set at build time:
+//
+//	go build -ldflags "-X video-service/health.Version=v1.2.0 -X video-service/health.Commit=abc1234"
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+type BuildInfo struct {
+	Service   string `json:"service"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Build returns the build information, falling back to the VCS revision
+// the Go toolchain embeds when Commit was not set.
+func Build() BuildInfo {
+	info := BuildInfo{
+		Service:   service,
+		Version:   Version,
+		Commit:    Commit,
+		GoVersion: runtime.Version(),
+	}
+	if info.Commit == "" {
+		if buildInfo, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range buildInfo.Settings {
+				if setting.Key == "vcs.revision" {
+					info.Commit = setting.Value
+				}
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
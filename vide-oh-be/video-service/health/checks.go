@@ -0,0 +1,58 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"video-service/config"
+	"video-service/database"
+	"video-service/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+const (
+	postgresTimeout       = 2 * time.Second
+	s3Timeout             = 3 * time.Second
+	secretsManagerTimeout = 3 * time.Second
+)
+
+// Init registers the readiness checks for the dependencies the
+// configuration uses. Secrets Manager is not used in local mode.
+func Init(cfg *config.Config) error {
+	Register(Check{Name: "postgres", Timeout: postgresTimeout, Run: database.Ping})
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.TODO(), awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	tracing.AppendAWSMiddlewares(&awsCfg.APIOptions)
+
+	s3Client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.UsePathStyle = cfg.S3.ForcePathStyle
+	})
+	Register(Check{
+		Name:    "s3",
+		Timeout: s3Timeout,
+		Run: func(ctx context.Context) error {
+			_, err := s3Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(cfg.S3.BucketName)})
+			return err
+		},
+	})
+
+	if !cfg.Local && cfg.DB.AuthMode == config.DBAuthSecret {
+		secrets := secretsmanager.NewFromConfig(awsCfg)
+		Register(Check{
+			Name:    "secretsmanager",
+			Timeout: secretsManagerTimeout,
+			Run: func(ctx context.Context) error {
+				_, err := secrets.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(cfg.DBSecretName)})
+				return err
+			},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware attaches a logger carrying the API Gateway request ID, the
+// correlation ID and the caller's email to every request, echoes the
+// correlation ID back and logs one line per request.
+func Middleware(base *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		ctx := c.Request.Context()
+
+		requestID := ""
+		if apiGwCtx, ok := core.GetAPIGatewayContextFromContext(ctx); ok {
+			requestID = apiGwCtx.RequestID
+		}
+		correlationID := c.GetHeader(CorrelationIDHeader)
+		if correlationID == "" {
+			correlationID = requestID
+		}
+		if correlationID == "" {
+			correlationID = newID()
+		}
+
+		logger := base.With("correlation_id", correlationID)
+		if requestID != "" {
+			logger = logger.With("request_id", requestID)
+		}
+		if email := emailFromToken(c.GetHeader("Authorization")); email != "" {
+			logger = logger.With("user_email", email)
+		}
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			logger = logger.With("trace_id", span.TraceID().String())
+		}
+
+		ctx = WithCorrelationID(ctx, correlationID)
+		ctx = WithLogger(ctx, logger)
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(CorrelationIDHeader, correlationID)
+
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		} else if c.Writer.Status() >= 400 {
+			level = slog.LevelWarn
+		}
+		logger.Log(ctx, level, "request handled",
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	}
+}
+
+// emailFromToken reads the email claim for logging only; the token is
+// verified by the authorizer, not here.
+func emailFromToken(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Email
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
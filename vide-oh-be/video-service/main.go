@@ -2,13 +2,27 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"net/url"
+	"log/slog"
 	"os"
 
+	"video-service/config"
 	"video-service/controllers"
 	"video-service/database"
+	"video-service/eventbus"
+	"video-service/health"
+	"video-service/logging"
+	"video-service/metrics"
+	"video-service/middleware"
+	"video-service/openapi"
+	"video-service/repository"
+	"video-service/router"
+	"video-service/storage"
+	"video-service/tracing"
 	"video-service/utils"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -19,79 +33,188 @@ import (
 
 var ginLambda *ginadapter.GinLambda
 
-func init() {
-	// Initialize Router
-	router := initRouter()
-
-	// Create the Lambda handler
-	ginLambda = ginadapter.New(router)
-}
+// cleanup runs on the scheduled EventBridge events, processObject on the
+// S3 ObjectCreated notifications.
+var (
+	cleanup       func(context.Context) error
+	processObject func(ctx context.Context, key string) error
+)
 
 func main() {
-	// Load env vars
-	secretName := os.Getenv("DB_SECRET_NAME")
-	if secretName == "" {
-		log.Fatal("DB_SECRET_NAME environment variable is not set")
+	local := flag.Bool("local", false, "serve the API over plain HTTP instead of running as a Lambda")
+	addr := flag.String("addr", ":8082", "listen address in -local mode")
+	dashboard := flag.Bool("dashboard", false, "print the CloudWatch dashboard for the service metrics and exit")
+	checkOpenAPI := flag.Bool("check-openapi", false, "compare the registered routes with openapi/openapi.json and exit")
+	cleanupUploads := flag.Bool("cleanup-uploads", false, "abort expired and orphaned multipart uploads and exit")
+	purgeVideos := flag.Bool("purge-videos", false, "remove videos deleted or failed longer than the retention period ago and exit")
+	migrate := flag.Bool("migrate", false, "create or update the tables and the search index, keeping the data, and exit")
+	flag.Parse()
+
+	if *dashboard {
+		printDashboard()
+		return
 	}
-	region := os.Getenv("REGION")
-	if secretName == "" {
-		log.Fatal("REGION environment variable is not set")
+	if *checkOpenAPI {
+		checkRoutes()
+		return
 	}
 
-	// Read AWS secret DB connection info
-	secret, err := utils.GetSecret(secretName, region)
+	// Load and validate configuration (env, CONFIG_FILE)
+	cfg, err := config.Load(*local)
 	if err != nil {
-		log.Fatalf("Failed to retrieve secret: %v", err)
+		log.Fatal(err)
+	}
+	slog.SetDefault(logging.New("video-service", cfg.LogLevel))
+	openapi.SetMode(cfg.OpenAPIValidation)
+	metrics.Init("video-service", *local)
+	if err := tracing.Init(context.Background(), "video-service", cfg.Tracing.Endpoint, cfg.Tracing.SamplePercent); err != nil {
+		log.Fatal(err)
 	}
-	connectionString := fmt.Sprintf("postgresql://%s:%s@%s:%d/%s",
-		secret.Username,
-		url.QueryEscape(secret.Password),
-		secret.Host,
-		secret.Port,
-		secret.DBName,
-	)
+
+	bucket, err := storage.Open(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bus, err := eventbus.Open(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	utils.SetMailServer(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.From)
 
 	// Initialize Database
-	database.Connect(connectionString)
-	// database.Migrate()
+	database.Open(cfg)
+	if *migrate {
+		database.AutoMigrate()
+		return
+	}
+	if err := health.Init(cfg); err != nil {
+		log.Fatal(err)
+	}
+	videos := controllers.NewVideoController(repository.New(database.Instance), bucket, bus, controllers.Settings{
+		S3:         cfg.S3,
+		Media:      cfg.Media,
+		Search:     cfg.Search,
+		Moderation: cfg.Moderation,
+	})
+
+	if *cleanupUploads {
+		if err := videos.CleanupUploads(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *purgeVideos {
+		if err := videos.PurgeVideos(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *local {
+		runLocal(cfg, videos, *addr)
+		return
+	}
+
+	// Create the Lambda handler
+	ginLambda = ginadapter.New(router.New(nil, videos))
+	cleanup = videos.Cleanup
+	processObject = videos.ProcessObject
 
 	// Start the Lambda handler
 	lambda.Start(Handler)
 }
 
-func CORS() gin.HandlerFunc {
-	// TO allow CORS
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
+// runLocal serves the router over plain HTTP with the authorizer stubbed
+// in-process.
+func runLocal(cfg *config.Config, videos *controllers.VideoController, addr string) {
+	authorizer, err := middleware.LocalAuthorizer(cfg.JWTKey)
+	if err != nil {
+		log.Fatal(err)
 	}
+
+	database.AutoMigrate()
+
+	defer tracing.Shutdown(context.Background())
+
+	engine := router.New(authorizer, videos)
+	engine.GET("/metrics", metrics.Handler())
+	for _, problem := range openapi.CheckRoutes(engine.Routes(), "/api/videos", "/api/v2/videos") {
+		slog.Warn("route does not match the OpenAPI document", "problem", problem)
+	}
+
+	slog.Info("video service listening", "addr", addr)
+	if err := engine.Run(addr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// checkRoutes exits non-zero when the router and openapi/openapi.json
+// disagree.
+func checkRoutes() {
+	gin.SetMode(gin.ReleaseMode)
+	problems := openapi.CheckRoutes(router.New(nil, &controllers.VideoController{}).Routes(), "/api/videos", "/api/v2/videos")
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printDashboard() {
+	region := os.Getenv("REGION")
+	if region == "" {
+		region = "eu-central-1"
+	}
+	body, err := metrics.Dashboard("video-service", region)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(body))
 }
 
-func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+// Handler serves the API Gateway requests, the S3 notifications of
+// uploaded videos and the hourly EventBridge schedule that cleans up
+// abandoned uploads and purges deleted videos.
+func Handler(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	defer tracing.Flush(ctx)
+	defer metrics.Flush()
+
+	var event struct {
+		Source  string `json:"source"`
+		Records []struct {
+			EventSource string `json:"eventSource"`
+		} `json:"Records"`
+	}
+	if err := json.Unmarshal(raw, &event); err == nil {
+		switch {
+		case event.Source == "aws.events":
+			return nil, cleanup(ctx)
+		case len(event.Records) > 0 && event.Records[0].EventSource == "aws:s3":
+			var s3Event events.S3Event
+			if err := json.Unmarshal(raw, &s3Event); err != nil {
+				return nil, fmt.Errorf("failed to decode S3 event: %v", err)
+			}
+			return nil, processObjects(ctx, s3Event)
+		}
+	}
+
+	var req events.APIGatewayProxyRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return nil, fmt.Errorf("failed to decode request to APIGatewayProxyRequest: %v", err)
+	}
 	return ginLambda.ProxyWithContext(ctx, req)
 }
 
-func initRouter() *gin.Engine {
-	router := gin.Default()
-	router.Use(CORS())
-	router.MaxMultipartMemory = 10 * 1024 * 1024
-	api := router.Group("/api/videos")
-	{
-		api.GET("/video-stream/:name", controllers.StreamVideo)
-		api.GET("/report-video/:id", controllers.ReportVideo)
-		api.GET("/search-videos", controllers.SearchVideos)
-
-		// protected
-		api.GET("/ping")
-		api.GET("/all-reported-videos", controllers.GetAllReportedVideos)
-		api.POST("/upload-video", controllers.UploadVideo)
-		api.GET("/delete-video/:id", controllers.DeleteVideo)
-	}
-	return router
+// processObjects processes every uploaded video of the notification. Any
+// failure fails the invocation, so Lambda retries the event; videos that
+// were already processed are skipped then.
+func processObjects(ctx context.Context, event events.S3Event) error {
+	var errs []error
+	for _, record := range event.Records {
+		if err := processObject(ctx, record.S3.Object.URLDecodedKey); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,165 @@
+// Package media runs the ffmpeg and ffprobe binaries bundled with the
+// Lambda.
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The ffmpeg and ffprobe executables zipped next to bootstrap.
+var (
+	Binary      = "./ffmpeg"
+	ProbeBinary = "./ffprobe"
+)
+
+// Rendition is one rung of the HLS ladder. Height is the short side of the
+// picture; bitrates are in kbit/s.
+type Rendition struct {
+	Name         string
+	Height       int
+	VideoBitrate int
+	AudioBitrate int
+}
+
+var Ladder = []Rendition{
+	{Name: "240p", Height: 240, VideoBitrate: 400, AudioBitrate: 64},
+	{Name: "360p", Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+	{Name: "480p", Height: 480, VideoBitrate: 1400, AudioBitrate: 128},
+	{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+	{Name: "1080p", Height: 1080, VideoBitrate: 5000, AudioBitrate: 192},
+}
+
+// IsRendition reports whether name is a rung of the ladder.
+func IsRendition(name string) bool {
+	for _, rendition := range Ladder {
+		if rendition.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LadderFor returns the rungs up to the source resolution. A source below
+// the lowest rung gets that rung at its own size.
+func LadderFor(source *Source) []Rendition {
+	var renditions []Rendition
+	for _, rendition := range Ladder {
+		if rendition.Height <= source.ShortSide() {
+			renditions = append(renditions, rendition)
+		}
+	}
+	if len(renditions) == 0 {
+		lowest := Ladder[0]
+		lowest.Height = source.ShortSide() &^ 1
+		renditions = append(renditions, lowest)
+	}
+	return renditions
+}
+
+// HLS output layout inside the directory passed to TranscodeHLS.
+const (
+	MasterPlaylist  = "master.m3u8"
+	MediaPlaylist   = "index.m3u8"
+	SegmentDuration = 6
+)
+
+// TranscodeHLS writes the renditions of input to dir: the master playlist
+// and, per rendition, <name>/index.m3u8 with its H.264/AAC segments.
+func TranscodeHLS(ctx context.Context, input, dir string, source *Source, renditions []Rendition) error {
+	for _, rendition := range renditions {
+		if err := os.MkdirAll(filepath.Join(dir, rendition.Name), 0o755); err != nil {
+			return err
+		}
+	}
+
+	// One decode, split into a scaler per rendition
+	filter := fmt.Sprintf("[0:v]split=%d", len(renditions))
+	for i := range renditions {
+		filter += fmt.Sprintf("[s%d]", i)
+	}
+	for i, rendition := range renditions {
+		h := rendition.Height
+		filter += fmt.Sprintf(`;[s%d]scale='if(gt(iw\,ih)\,-2\,%d)':'if(gt(iw\,ih)\,%d\,-2)'[v%d]`, i, h, h, i)
+	}
+
+	args := []string{"-hide_banner", "-y", "-i", input, "-filter_complex", filter}
+	var streams []string
+	for i, rendition := range renditions {
+		bitrate := rendition.VideoBitrate
+		args = append(args,
+			"-map", fmt.Sprintf("[v%d]", i),
+			fmt.Sprintf("-c:v:%d", i), "libx264",
+			fmt.Sprintf("-b:v:%d", i), fmt.Sprintf("%dk", bitrate),
+			fmt.Sprintf("-maxrate:v:%d", i), fmt.Sprintf("%dk", bitrate*107/100),
+			fmt.Sprintf("-bufsize:v:%d", i), fmt.Sprintf("%dk", bitrate*3/2))
+		stream := fmt.Sprintf("v:%d", i)
+		if source.HasAudio() {
+			args = append(args,
+				"-map", "0:a:0",
+				fmt.Sprintf("-c:a:%d", i), "aac",
+				fmt.Sprintf("-b:a:%d", i), fmt.Sprintf("%dk", rendition.AudioBitrate),
+				fmt.Sprintf("-ac:a:%d", i), "2")
+			stream += fmt.Sprintf(",a:%d", i)
+		}
+		streams = append(streams, stream+",name:"+rendition.Name)
+	}
+	args = append(args,
+		"-preset", "veryfast", "-pix_fmt", "yuv420p",
+		// Keyframes on segment boundaries, so every rendition switches cleanly
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", SegmentDuration), "-sc_threshold", "0",
+		"-f", "hls", "-hls_time", strconv.Itoa(SegmentDuration), "-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+		"-hls_segment_filename", filepath.Join(dir, "%v", "segment_%03d.ts"),
+		"-master_pl_name", MasterPlaylist,
+		"-var_stream_map", strings.Join(streams, " "),
+		filepath.Join(dir, "%v", MediaPlaylist))
+
+	output, err := exec.CommandContext(ctx, Binary, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", err, lastLine(output))
+	}
+	return nil
+}
+
+// Normalize writes input to output as an MP4 with H.264 video, AAC audio
+// and the index up front (faststart), so players can start before the
+// download finishes. Streams already in those codecs are copied.
+func Normalize(ctx context.Context, input, output string, source *Source) error {
+	args := []string{"-hide_banner", "-y", "-i", input, "-map", "0:v:0", "-map", "0:a:0?"}
+	if source.CanCopyVideo() {
+		args = append(args, "-c:v", "copy")
+	} else {
+		args = append(args,
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "20", "-pix_fmt", "yuv420p",
+			// 4:2:0 needs even dimensions
+			"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2")
+	}
+	if source.CanCopyAudio() {
+		args = append(args, "-c:a", "copy")
+	} else {
+		args = append(args, "-c:a", "aac", "-b:a", "160k", "-ac", "2")
+	}
+	args = append(args, "-movflags", "+faststart", "-f", "mp4", output)
+
+	out, err := exec.CommandContext(ctx, Binary, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", err, lastLine(out))
+	}
+	return nil
+}
+
+// lastLine is usually where ffmpeg explains a failure.
+func lastLine(output []byte) string {
+	output = bytes.TrimSpace(output)
+	if i := bytes.LastIndexByte(output, '\n'); i >= 0 {
+		output = output[i+1:]
+	}
+	return string(output)
+}
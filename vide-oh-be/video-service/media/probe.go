@@ -0,0 +1,209 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotVideo is returned by Probe for input without a video stream
+// ffprobe can read, e.g. another kind of file renamed to .mp4.
+var ErrNotVideo = errors.New("no decodable video stream")
+
+// Source describes an input as ffprobe reports it. Width and Height are
+// the displayed size, i.e. after Rotation.
+type Source struct {
+	// As ffprobe names it, e.g. "matroska,webm"
+	Format      string
+	Duration    time.Duration
+	Width       int
+	Height      int
+	VideoCodec  string
+	PixelFormat string
+	// Empty without an audio stream
+	AudioCodec string
+	// Overall bitrate in bit/s
+	Bitrate   int64
+	FrameRate float64
+	// Clockwise, one of 0, 90, 180 and 270
+	Rotation int
+}
+
+// Supported reports whether the container is one of the accepted ones;
+// ffprobe reports MP4 and MOV, and WebM and MKV, alike.
+func (s *Source) Supported() bool {
+	return s.Format == "mov,mp4,m4a,3gp,3g2,mj2" || s.Format == "matroska,webm"
+}
+
+func (s *Source) HasAudio() bool {
+	return s.AudioCodec != ""
+}
+
+// CanCopyVideo reports whether the video stream can go into the
+// normalized MP4 as is; browsers only decode 8-bit 4:2:0 H.264.
+func (s *Source) CanCopyVideo() bool {
+	return s.VideoCodec == "h264" && s.PixelFormat == "yuv420p"
+}
+
+func (s *Source) CanCopyAudio() bool {
+	return s.AudioCodec == "aac"
+}
+
+// Remux reports whether Normalize only has to change the container.
+func (s *Source) Remux() bool {
+	return s.CanCopyVideo() && (s.CanCopyAudio() || !s.HasAudio())
+}
+
+// ShortSide is the resolution the HLS ladder compares against, so portrait
+// videos get the same rungs as landscape ones.
+func (s *Source) ShortSide() int {
+	return min(s.Width, s.Height)
+}
+
+func (s *Source) LongSide() int {
+	return max(s.Width, s.Height)
+}
+
+type probeStream struct {
+	CodecType    string `json:"codec_type"`
+	CodecName    string `json:"codec_name"`
+	PixFmt       string `json:"pix_fmt"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	AvgFrameRate string `json:"avg_frame_rate"`
+	RFrameRate   string `json:"r_frame_rate"`
+	Disposition  struct {
+		AttachedPic int `json:"attached_pic"`
+	} `json:"disposition"`
+	Tags struct {
+		Rotate string `json:"rotate"`
+	} `json:"tags"`
+	SideDataList []struct {
+		Rotation float64 `json:"rotation"`
+	} `json:"side_data_list"`
+}
+
+type probeOutput struct {
+	Streams []probeStream `json:"streams"`
+	Format  struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// Probe reads the metadata of input, a local path or a URL. It returns an
+// error wrapping ErrNotVideo when ffprobe read input and rejected it, and a
+// plain error otherwise, e.g. when S3 did not answer or ffprobe could not
+// be run.
+func Probe(ctx context.Context, input string) (*Source, error) {
+	cmd := exec.CommandContext(ctx, ProbeBinary, "-v", "error", "-print_format", "json", "-show_format", "-show_streams", input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		message := lastLine(stderr.Bytes())
+		// A missing binary, a killed process or an exit without a message
+		// say nothing about the input
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() <= 0 || message == "" || unreachable(message) {
+			return nil, fmt.Errorf("ffprobe: %w: %s", err, message)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotVideo, message)
+	}
+
+	var probe probeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("ffprobe output: %w", err)
+	}
+	source := &Source{}
+	var video *probeStream
+	for i, stream := range probe.Streams {
+		switch {
+		// Cover art of audio files is a video stream too
+		case stream.CodecType == "video" && video == nil && stream.Disposition.AttachedPic == 0:
+			video = &probe.Streams[i]
+		case stream.CodecType == "audio" && source.AudioCodec == "":
+			source.AudioCodec = stream.CodecName
+		}
+	}
+	if video == nil || video.CodecName == "" || video.Width <= 0 || video.Height <= 0 {
+		return nil, ErrNotVideo
+	}
+	seconds, _ := strconv.ParseFloat(probe.Format.Duration, 64)
+	if seconds <= 0 {
+		// Still images have a video stream but no duration
+		return nil, fmt.Errorf("%w: no duration", ErrNotVideo)
+	}
+
+	source.Duration = time.Duration(seconds * float64(time.Second))
+	source.Format = probe.Format.FormatName
+	source.VideoCodec = video.CodecName
+	source.PixelFormat = video.PixFmt
+	source.Bitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+	source.FrameRate = frameRate(video.AvgFrameRate)
+	if source.FrameRate == 0 {
+		source.FrameRate = frameRate(video.RFrameRate)
+	}
+	source.Rotation = rotation(video)
+	source.Width, source.Height = video.Width, video.Height
+	if source.Rotation == 90 || source.Rotation == 270 {
+		source.Width, source.Height = video.Height, video.Width
+	}
+	return source, nil
+}
+
+// unreachable tells failures to fetch the input from input ffprobe read
+// but could not make sense of.
+func unreachable(message string) bool {
+	for _, s := range []string{"Server returned", "Connection", "timed out", "Failed to resolve", "Network is unreachable"} {
+		if strings.Contains(message, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// frameRate parses rates like "30000/1001", rounded to two decimals.
+func frameRate(rate string) float64 {
+	num, den, ok := strings.Cut(rate, "/")
+	if !ok {
+		return 0
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0
+	}
+	return math.Round(n/d*100) / 100
+}
+
+// rotation reads the display matrix, which newer ffprobe versions report
+// counterclockwise, or else the rotate tag older ones use.
+func rotation(stream *probeStream) int {
+	var degrees float64
+	for _, sideData := range stream.SideDataList {
+		if sideData.Rotation != 0 {
+			degrees = -sideData.Rotation
+			break
+		}
+	}
+	if degrees == 0 && stream.Tags.Rotate != "" {
+		degrees, _ = strconv.ParseFloat(stream.Tags.Rotate, 64)
+	}
+	quarters := int(math.Round(degrees/90)) % 4
+	if quarters < 0 {
+		quarters += 4
+	}
+	return quarters * 90
+}
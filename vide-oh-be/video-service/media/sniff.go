@@ -0,0 +1,49 @@
+package media
+
+import "bytes"
+
+// Containers accepted for upload.
+const (
+	ContainerMP4  = "mp4"
+	ContainerMOV  = "mov"
+	ContainerWebM = "webm"
+	ContainerMKV  = "mkv"
+)
+
+var ContentTypes = map[string]string{
+	ContainerMP4:  "video/mp4",
+	ContainerMOV:  "video/quicktime",
+	ContainerWebM: "video/webm",
+	ContainerMKV:  "video/x-matroska",
+}
+
+// SniffLen is how much of the start of a file Sniff looks at.
+const SniffLen = 512
+
+var ebml = []byte{0x1a, 0x45, 0xdf, 0xa3}
+
+// Sniff names the container of a file from its first bytes, whatever its
+// extension, or returns "" when it is not one of the accepted ones.
+func Sniff(head []byte) string {
+	if len(head) < 12 {
+		return ""
+	}
+	switch string(head[4:8]) {
+	case "ftyp":
+		if string(head[8:12]) == "qt  " {
+			return ContainerMOV
+		}
+		return ContainerMP4
+	case "moov", "mdat", "wide", "free", "skip":
+		// QuickTime files from before the ftyp atom
+		return ContainerMOV
+	}
+	if bytes.HasPrefix(head, ebml) {
+		// The EBML header names the DocType
+		if bytes.Contains(head, []byte("webm")) {
+			return ContainerWebM
+		}
+		return ContainerMKV
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storyboard layout: thumbnails are at most 160 pixels on the long side,
+// 25 to a sprite sheet, taken every 2 seconds or often enough for at most
+// 100 of them.
+const (
+	StoryboardTrack    = "storyboard.vtt"
+	storyboardSize     = 160
+	storyboardColumns  = 5
+	storyboardRows     = 5
+	storyboardMaxCount = 100
+	storyboardMinStep  = 2 * time.Second
+)
+
+// ffmpeg numbers the sheets from 1
+const sheetPattern = "sheet_%03d.jpg"
+
+// StoryboardSheet names the i-th sprite sheet, counting from 0.
+func StoryboardSheet(i int) string {
+	return fmt.Sprintf(sheetPattern, i+1)
+}
+
+// Storyboard describes the sprite sheets written by GenerateStoryboard.
+type Storyboard struct {
+	Interval time.Duration
+	Duration time.Duration
+	// Size of one thumbnail
+	Width  int
+	Height int
+	// Thumbnails over all sheets
+	Count  int
+	Sheets []string
+}
+
+// GenerateStoryboard writes the sprite sheets of input to dir, named by
+// StoryboardSheet.
+func GenerateStoryboard(ctx context.Context, input, dir string, source *Source) (*Storyboard, error) {
+	step := time.Duration(math.Ceil(source.Duration.Seconds()/storyboardMaxCount)) * time.Second
+	interval := max(storyboardMinStep, step)
+	board := &Storyboard{Interval: interval, Duration: source.Duration, Width: storyboardSize, Height: storyboardSize}
+	if source.Width >= source.Height {
+		board.Height = max(2, storyboardSize*source.Height/source.Width) &^ 1
+	} else {
+		board.Width = max(2, storyboardSize*source.Width/source.Height) &^ 1
+	}
+
+	filter := fmt.Sprintf("fps=1/%d,scale=%d:%d,tile=%dx%d", int(interval.Seconds()), board.Width, board.Height, storyboardColumns, storyboardRows)
+	args := []string{"-hide_banner", "-y", "-i", input, "-an", "-vf", filter, "-q:v", "5", filepath.Join(dir, sheetPattern)}
+	output, err := exec.CommandContext(ctx, Binary, args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, lastLine(output))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if _, err := os.Stat(filepath.Join(dir, StoryboardSheet(i))); err != nil {
+			break
+		}
+		board.Sheets = append(board.Sheets, StoryboardSheet(i))
+	}
+	if len(board.Sheets) == 0 {
+		return nil, fmt.Errorf("ffmpeg wrote no sprite sheets: %s", lastLine(output))
+	}
+	expected := int((source.Duration + interval - 1) / interval)
+	board.Count = min(expected, len(board.Sheets)*storyboardColumns*storyboardRows)
+	return board, nil
+}
+
+// WebVTT is the thumbnails track of the storyboard: one cue per
+// thumbnail, pointing at its tile with a media fragment, e.g.
+// sheet_001.jpg#xywh=160,0,160,90.
+func (b *Storyboard) WebVTT() []byte {
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n")
+	perSheet := storyboardColumns * storyboardRows
+	for i := 0; i < b.Count; i++ {
+		start := time.Duration(i) * b.Interval
+		end := min(start+b.Interval, b.Duration)
+		tile := i % perSheet
+		x := tile % storyboardColumns * b.Width
+		y := tile / storyboardColumns * b.Height
+		fmt.Fprintf(&vtt, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n", timestamp(start), timestamp(end), b.Sheets[i/perSheet], x, y, b.Width, b.Height)
+	}
+	return []byte(vtt.String())
+}
+
+// timestamp formats d as a WebVTT timestamp, hh:mm:ss.ttt.
+func timestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
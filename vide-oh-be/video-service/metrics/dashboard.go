@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Dashboard returns a CloudWatch dashboard body with one graph per
+// registered metric: the 5 minute sum for counters and p50/p90/p99 for
+// histograms. Each graph uses a SEARCH expression, so label values that
+// appear later are plotted without regenerating the dashboard.
+func Dashboard(serviceName, region string) ([]byte, error) {
+	type widget struct {
+		Type       string                 `json:"type"`
+		X          int                    `json:"x"`
+		Y          int                    `json:"y"`
+		Width      int                    `json:"width"`
+		Height     int                    `json:"height"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+
+	var widgets []widget
+	for i, def := range definitions() {
+		var expressions [][]map[string]string
+		switch def.Kind {
+		case KindCounter:
+			expressions = append(expressions, searchExpression(def, serviceName, "Sum", "e1"))
+		case KindHistogram:
+			for j, stat := range []string{"p50", "p90", "p99"} {
+				expressions = append(expressions, searchExpression(def, serviceName, stat, fmt.Sprintf("e%d", j+1)))
+			}
+		}
+
+		widgets = append(widgets, widget{
+			Type:   "metric",
+			X:      (i % 2) * 12,
+			Y:      (i / 2) * 6,
+			Width:  12,
+			Height: 6,
+			Properties: map[string]interface{}{
+				"title":   def.Help,
+				"region":  region,
+				"view":    "timeSeries",
+				"stat":    "Sum",
+				"period":  300,
+				"metrics": expressions,
+				"yAxis":   map[string]interface{}{"left": map[string]interface{}{"label": def.Unit, "showUnits": false}},
+			},
+		})
+	}
+
+	return json.MarshalIndent(map[string]interface{}{"widgets": widgets}, "", "  ")
+}
+
+func searchExpression(def *Definition, serviceName, stat, id string) []map[string]string {
+	schema := strings.Join(append([]string{Namespace, "Service"}, def.Labels...), ",")
+	return []map[string]string{{
+		"expression": fmt.Sprintf(`SEARCH('{%s} Service="%s" MetricName="%s"', '%s', 300)`, schema, serviceName, def.Name, stat),
+		"id":         id,
+		"label":      "${LABEL} " + stat,
+	}}
+}
@@ -0,0 +1,57 @@
+package metrics
+
+// Uploads counts upload requests by outcome: success, rejected (wrong
+// role, missing file or extension) or failed. Direct uploads are counted
+// when they are completed.
+var Uploads = NewCounter("uploads_total", "Video uploads by outcome", "outcome")
+
+var UploadDuration = NewHistogram("upload_duration_seconds", "Duration of successful uploads", UnitSeconds,
+	[]float64{1, 2.5, 5, 10, 20, 30})
+
+var UploadSize = NewHistogram("upload_size_bytes", "Size of uploaded videos", UnitBytes,
+	[]float64{1 << 20, 10 << 20, 50 << 20, 100 << 20, 250 << 20, 500 << 20})
+
+var ThumbnailFailures = NewCounter("thumbnail_failures_total", "Thumbnail generation failures")
+
+var ThumbnailDuration = NewHistogram("thumbnail_duration_seconds", "Duration of the ffmpeg thumbnail step", UnitSeconds,
+	[]float64{0.25, 0.5, 1, 2.5, 5, 10})
+
+// AbortedUploads counts multipart uploads aborted by the cleanup, by
+// reason: expired (session timed out) or orphaned (no session).
+var AbortedUploads = NewCounter("aborted_uploads_total", "Multipart uploads aborted by the cleanup", "reason")
+
+// ProcessedVideos counts uploads that finished processing, by outcome:
+// ready or failed (including uploads stuck past the processing timeout).
+var ProcessedVideos = NewCounter("processed_videos_total", "Uploads that finished processing", "outcome")
+
+var ProcessingDuration = NewHistogram("processing_duration_seconds", "Duration of successful video processing", UnitSeconds,
+	[]float64{1, 2.5, 5, 10, 20, 30})
+
+var TranscodeDuration = NewHistogram("transcode_duration_seconds", "Duration of the ffmpeg HLS transcoding step", UnitSeconds,
+	[]float64{10, 30, 60, 120, 300, 600})
+
+// NormalizeDuration covers the conversion to the canonical MP4, by mode:
+// remux when the codecs could be copied, transcode otherwise.
+var NormalizeDuration = NewHistogram("normalize_duration_seconds", "Duration of the ffmpeg MP4 normalization step", UnitSeconds,
+	[]float64{1, 5, 30, 60, 300, 600}, "mode")
+
+var StoryboardDuration = NewHistogram("storyboard_duration_seconds", "Duration of the ffmpeg storyboard step", UnitSeconds,
+	[]float64{1, 5, 10, 30, 60, 120})
+
+// PublishedEvents counts the domain events sent to the event bus, by
+// outcome: published or failed.
+var PublishedEvents = NewCounter("published_events_total", "Domain events sent to the event bus", "outcome")
+
+// PurgedVideos counts the videos removed for good by the purge, by reason:
+// deleted or failed.
+var PurgedVideos = NewCounter("purged_videos_total", "Videos removed for good by the purge", "reason")
+
+// VideoReports counts the reports users file, by category.
+var VideoReports = NewCounter("video_reports_total", "Reports filed against videos", "category")
+
+// ResolvedReports counts the reports administrators resolve, by status:
+// dismissed or actioned.
+var ResolvedReports = NewCounter("resolved_reports_total", "Reports resolved by administrators", "status")
+
+// HiddenVideos counts the videos hidden from searches by their reports.
+var HiddenVideos = NewCounter("hidden_videos_total", "Videos hidden from searches by their reports")
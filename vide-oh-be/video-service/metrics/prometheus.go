@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+type promSeries struct {
+	labelValues []string
+	value       float64  // counters
+	buckets     []uint64 // histograms, cumulative per upper bound
+	count       uint64
+	sum         float64
+}
+
+type prometheusStore struct {
+	mu     sync.Mutex
+	series map[*Definition]map[string]*promSeries
+}
+
+func newPrometheusStore() *prometheusStore {
+	return &prometheusStore{series: make(map[*Definition]map[string]*promSeries)}
+}
+
+func (store *prometheusStore) record(def *Definition, labelValues []string, value float64) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	byKey, ok := store.series[def]
+	if !ok {
+		byKey = make(map[string]*promSeries)
+		store.series[def] = byKey
+	}
+	key := seriesKey(labelValues)
+	series, ok := byKey[key]
+	if !ok {
+		series = &promSeries{
+			labelValues: append([]string(nil), labelValues...),
+			buckets:     make([]uint64, len(def.Buckets)),
+		}
+		byKey[key] = series
+	}
+
+	switch def.Kind {
+	case KindCounter:
+		series.value += value
+	case KindHistogram:
+		for i, bound := range def.Buckets {
+			if value <= bound {
+				series.buckets[i]++
+			}
+		}
+		series.count++
+		series.sum += value
+	}
+}
+
+// Handler serves the local-mode metrics in the Prometheus text format.
+func Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteText(c.Writer)
+	}
+}
+
+// WriteText writes the local-mode metrics in the Prometheus text format.
+// It writes nothing on Lambda.
+func WriteText(w io.Writer) {
+	sinkMu.RLock()
+	store, ok := current.(*prometheusStore)
+	sinkMu.RUnlock()
+	if !ok {
+		return
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for _, def := range definitions() {
+		byKey := store.series[def]
+		fmt.Fprintf(w, "# HELP %s %s\n", def.Name, escapeHelp(def.Help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", def.Name, def.Kind)
+
+		keys := make([]string, 0, len(byKey))
+		for key := range byKey {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			series := byKey[key]
+			labels := formatLabels(def.Labels, series.labelValues)
+			switch def.Kind {
+			case KindCounter:
+				fmt.Fprintf(w, "%s%s %s\n", def.Name, labels, formatValue(series.value))
+			case KindHistogram:
+				bucketLabels := append(append([]string(nil), def.Labels...), "le")
+				for i, bound := range def.Buckets {
+					bucketValues := append(append([]string(nil), series.labelValues...), formatValue(bound))
+					fmt.Fprintf(w, "%s_bucket%s %d\n", def.Name, formatLabels(bucketLabels, bucketValues), series.buckets[i])
+				}
+				bucketValues := append(append([]string(nil), series.labelValues...), "+Inf")
+				fmt.Fprintf(w, "%s_bucket%s %d\n", def.Name, formatLabels(bucketLabels, bucketValues), series.count)
+				fmt.Fprintf(w, "%s_sum%s %s\n", def.Name, labels, formatValue(series.sum))
+				fmt.Fprintf(w, "%s_count%s %d\n", def.Name, labels, series.count)
+			}
+		}
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
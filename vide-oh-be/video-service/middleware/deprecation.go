@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecated marks every response of a superseded API version with the
+// Deprecation (RFC 9745) and Sunset (RFC 8594) headers and links the
+// version that replaces it.
+func Deprecated(since, sunset time.Time, successor string) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(since.Unix(), 10)
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+	link := "<" + successor + `>; rel="successor-version"`
+	return func(context *gin.Context) {
+		header := context.Writer.Header()
+		header.Set("Deprecation", deprecation)
+		header.Set("Sunset", sunsetDate)
+		header.Add("Link", link)
+		context.Next()
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Limits of the text an owner or administrator can give a video.
+const (
+	MaxTitleLength       = 100
+	MaxDescriptionLength = 5000
+)
+
+// VideoEdit keeps the title, description and thumbnail a video had before
+// an edit, and who made it.
+type VideoEdit struct {
+	gorm.Model
+	VideoID     uint   `gorm:"not null;index"`
+	EditorEmail string `gorm:"not null"`
+	EditorRole  string `gorm:"not null"`
+	Title       string `gorm:"not null"`
+	Description string `gorm:"not null"`
+	// Seconds into the video of the previous thumbnail; nil when it was an
+	// uploaded picture
+	ThumbnailTime *float64
+	// The previous Video.Thumbnail
+	Thumbnail string `gorm:"not null;default:''"`
+}
+
+type VideoEditDTO struct {
+	ID            uint      `json:"ID"`
+	EditedAt      time.Time `json:"editedAt"`
+	EditorEmail   string    `json:"editorEmail"`
+	EditorRole    string    `json:"editorRole"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	ThumbnailTime *float64  `json:"thumbnailTime,omitempty"`
+	// Presigned URL of the previous thumbnail
+	ThumbnailURL string `json:"thumbnailUrl"`
+}
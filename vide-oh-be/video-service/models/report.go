@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Report categories and the weight each adds to a video's report score.
+// The graver ones hide a video after fewer reports.
+var ReportWeights = map[string]int{
+	"spam":       1,
+	"misleading": 1,
+	"other":      1,
+	"copyright":  2,
+	"harassment": 2,
+	"hate":       3,
+	"violence":   3,
+	"sexual":     3,
+}
+
+// States of a report. Reports start open; an administrator dismisses them
+// when the video may stay, or marks them actioned when it is taken down.
+const (
+	ReportOpen      = "open"
+	ReportDismissed = "dismissed"
+	ReportActioned  = "actioned"
+)
+
+const MaxReportDetailsLength = 1000
+
+// VideoReport is one user's report of a video; a user reports a video at
+// most once.
+type VideoReport struct {
+	gorm.Model
+	VideoID       uint   `gorm:"not null;uniqueIndex:idx_video_reporter"`
+	ReporterEmail string `gorm:"not null;uniqueIndex:idx_video_reporter"`
+	Category      string `gorm:"not null"`
+	Details       string `gorm:"not null;default:''"`
+	Status        string `gorm:"not null;default:open;index"`
+	ResolvedBy    string `gorm:"not null;default:''"`
+	ResolvedAt    *time.Time
+}
+
+// ReportTally sums up the reports of a video.
+type ReportTally struct {
+	Open     int
+	Actioned int
+	// Weight of the open reports
+	Score int
+}
+
+func Tally(reports []VideoReport) ReportTally {
+	var t ReportTally
+	for _, report := range reports {
+		switch report.Status {
+		case ReportOpen:
+			t.Open++
+			t.Score += ReportWeights[report.Category]
+		case ReportActioned:
+			t.Actioned++
+		}
+	}
+	return t
+}
+
+type VideoReportDTO struct {
+	ID            uint       `json:"ID"`
+	ReportedAt    time.Time  `json:"reportedAt"`
+	ReporterEmail string     `json:"reporterEmail"`
+	Category      string     `json:"category"`
+	Details       string     `json:"details"`
+	Status        string     `json:"status"`
+	ResolvedBy    string     `json:"resolvedBy,omitempty"`
+	ResolvedAt    *time.Time `json:"resolvedAt,omitempty"`
+}
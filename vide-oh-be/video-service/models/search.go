@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Orders of a video search. Relevance needs search text and falls back to
+// date without it.
+const (
+	SortRelevance  = "relevance"
+	SortDate       = "date"
+	SortPopularity = "popularity"
+)
+
+// VideoSearch selects the ready, visible videos matching Text and the
+// filters. Zero filters do not apply; a zero Limit returns every match.
+type VideoSearch struct {
+	Text string
+	// Text search configuration, e.g. english
+	Language       string
+	Uploader       string
+	UploadedAfter  time.Time
+	UploadedBefore time.Time
+	// Seconds
+	MinDuration float64
+	MaxDuration float64
+	Sort        string
+	After       *SearchCursor
+	Limit       int
+}
+
+// SearchCursor is the sort key of the last video of a page; the next page
+// starts right after it.
+type SearchCursor struct {
+	Sort      string    `json:"s"`
+	ID        uint      `json:"id"`
+	Rank      float64   `json:"r,omitempty"`
+	CreatedAt time.Time `json:"t,omitempty"`
+	Views     int64     `json:"v,omitempty"`
+}
+
+// VideoHit is a search result. The headlines are set for text searches on
+// Postgres, with the matches between HighlightStart and HighlightStop.
+type VideoHit struct {
+	Video
+	Rank                float64
+	TitleHeadline       string
+	DescriptionHeadline string
+}
+
+const (
+	HighlightStart = "\x02"
+	HighlightStop  = "\x03"
+)
+
+// Cursor returns the cursor of the page that follows hit.
+func (h *VideoHit) Cursor(sort string) SearchCursor {
+	return SearchCursor{Sort: sort, ID: h.ID, Rank: h.Rank, CreatedAt: h.CreatedAt, Views: h.Views}
+}
+
+// HighlightDTO holds the title and description with the matched words in
+// <mark> elements; the rest of the text is HTML-escaped.
+type HighlightDTO struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
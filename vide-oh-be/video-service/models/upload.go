@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UploadSession tracks a direct-to-S3 multipart upload from initiation
+// until it is completed, aborted or cleaned up after ExpiresAt.
+type UploadSession struct {
+	gorm.Model
+	OwnerEmail string `gorm:"not null;index"`
+	// Object key without the extension; becomes Video.Filename
+	Filename    string    `gorm:"unique;not null"`
+	UploadID    string    `gorm:"not null"`
+	Title       string    `gorm:"not null"`
+	Description string    `gorm:"not null"`
+	Size        int64     `gorm:"not null"`
+	PartSize    int64     `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	// Becomes Video.KeepOriginal
+	KeepOriginal bool `gorm:"not null;default:false"`
+}
+
+// PartCount is the number of parts the client uploads; all but the last
+// are PartSize bytes.
+func (u *UploadSession) PartCount() int32 {
+	return int32((u.Size + u.PartSize - 1) / u.PartSize)
+}
+
+// PartLength is the size of part number; only the last part is shorter.
+func (u *UploadSession) PartLength(number int32) int64 {
+	if number == u.PartCount() {
+		return u.Size - int64(number-1)*u.PartSize
+	}
+	return u.PartSize
+}
+
+type UploadSessionDTO struct {
+	ID        uint      `json:"ID"`
+	Size      int64     `json:"size"`
+	PartSize  int64     `json:"partSize"`
+	PartCount int32     `json:"partCount"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type UploadPartURL struct {
+	PartNumber int32  `json:"partNumber"`
+	URL        string `json:"url"`
+}
@@ -1,16 +1,94 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// Processing states of a video. Uploads start as uploaded; the S3 event
+// handler moves them through processing to ready or failed. Only ready
+// videos are listed.
+const (
+	StatusUploaded   = "uploaded"
+	StatusProcessing = "processing"
+	StatusReady      = "ready"
+	StatusFailed     = "failed"
+)
+
 type Video struct {
 	gorm.Model
 	Title       string `json:"title" gorm:"not null"`
 	Filename    string `json:"filename" gorm:"unique;not-null"`
 	Description string `json:"description" gorm:"not null"`
 	OwnerEmail  string `json:"ownerEmail" gorm:"not null"`
-	Reported    bool   `json:"reported" gorm:"default:false"`
+	// Reported while the video has open reports; Hidden keeps it out of
+	// searches once their weight reaches the threshold, or after an
+	// administrator actioned a report
+	Reported bool `json:"reported" gorm:"default:false"`
+	Hidden   bool `json:"hidden" gorm:"not null;default:false"`
+	// Rows from before the state machine default to ready
+	Status        string `json:"status" gorm:"not null;default:ready;index"`
+	FailureReason string `json:"failureReason"`
+	// HLS is set once the renditions are in the bucket; older videos only
+	// have the .mp4
+	HLS bool `json:"hls" gorm:"not null;default:false"`
+	// The owner asked to keep the uploaded file next to the normalized
+	// .mp4; it is deleted after processing otherwise
+	KeepOriginal bool `json:"keepOriginal" gorm:"not null;default:false"`
+	// Sprite sheets of the seek preview storyboard; 0 when there is none
+	StoryboardSheets int `json:"storyboardSheets" gorm:"not null;default:0"`
+	// Seconds into the video of the thumbnail frame; nil once the owner
+	// uploaded a picture instead
+	ThumbnailTime *float64 `json:"thumbnailTime" gorm:"default:0"`
+	// Object key of a thumbnail picked or uploaded after processing; empty
+	// for the one processing rendered. See ThumbnailKey.
+	Thumbnail string `json:"-" gorm:"not null;default:''"`
+	// Stream URLs handed out, for sorting searches by popularity
+	Views int64 `json:"views" gorm:"not null;default:0;index"`
+	VideoMetadata
+}
+
+// VideoMetadata is what ffprobe reported when the video was processed;
+// zero for videos processed before. Width and Height are the displayed
+// size, after Rotation.
+type VideoMetadata struct {
+	// Seconds
+	Duration   float64 `json:"duration" gorm:"not null;default:0"`
+	Width      int     `json:"width" gorm:"not null;default:0"`
+	Height     int     `json:"height" gorm:"not null;default:0"`
+	VideoCodec string  `json:"videoCodec" gorm:"not null;default:''"`
+	AudioCodec string  `json:"audioCodec" gorm:"not null;default:''"`
+	// bit/s
+	Bitrate   int64   `json:"bitrate" gorm:"not null;default:0"`
+	FrameRate float64 `json:"frameRate" gorm:"not null;default:0"`
+	// Clockwise degrees
+	Rotation int `json:"rotation" gorm:"not null;default:0"`
+}
+
+// ThumbnailKey is the object key of the video's current thumbnail.
+func (v *Video) ThumbnailKey() string {
+	return ThumbnailKey(v.Filename, v.Thumbnail)
+}
+
+// ThumbnailKey resolves a Video.Thumbnail, or a VideoEdit.Thumbnail, of
+// the video stored as filename. Processing renders <filename>.png; later
+// thumbnails each get their own key below <filename>/thumbnails/, so the
+// edit history keeps the earlier ones.
+func ThumbnailKey(filename, thumbnail string) string {
+	if thumbnail != "" {
+		return thumbnail
+	}
+	return filename + ".png"
+}
+
+func (v *Video) Ready() bool {
+	return v.Status == StatusReady
+}
+
+// Done reports whether processing finished, successfully or not.
+func (v *Video) Done() bool {
+	return v.Status == StatusReady || v.Status == StatusFailed
 }
 
 type VideoSearchResultDTO struct {
@@ -20,5 +98,35 @@ type VideoSearchResultDTO struct {
 	Description  string `json:"description"`
 	OwnerEmail   string `json:"ownerEmail"`
 	Reported     bool   `json:"reported"`
+	Hidden       bool   `json:"hidden"`
 	ThumbnailURL string `json:"thumbnailUrl"`
+	Status       string `json:"status"`
+	Views        int64  `json:"views"`
+	// Only set once the storyboard exists
+	Storyboard *StoryboardDTO `json:"storyboard,omitempty"`
+	// Only set for text searches
+	Highlight *HighlightDTO `json:"highlight,omitempty"`
+	VideoMetadata
+}
+
+// StoryboardDTO links the WebVTT thumbnails track players load for seek
+// previews, and the sprite sheets its cues point into.
+type StoryboardDTO struct {
+	Track   string   `json:"track"`
+	Sprites []string `json:"sprites"`
+}
+
+// StreamDTO points at the HLS master playlist, or at the .mp4 of videos
+// processed before HLS.
+type StreamDTO struct {
+	URL    string `json:"url"`
+	Format string `json:"format"`
+}
+
+type VideoStatusDTO struct {
+	ID            uint      `json:"ID"`
+	Status        string    `json:"status"`
+	FailureReason string    `json:"failureReason,omitempty"`
+	KeepOriginal  bool      `json:"keepOriginal"`
+	UpdatedAt     time.Time `json:"updatedAt"`
 }
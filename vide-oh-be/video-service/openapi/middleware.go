@@ -0,0 +1,91 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"video-service/apperrors"
+	"video-service/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Validation modes. Report only logs mismatches; enforce also rejects
+// invalid requests with 400 and replaces invalid responses with a 500.
+const (
+	ModeOff     = "off"
+	ModeReport  = "report"
+	ModeEnforce = "enforce"
+)
+
+var mode = ModeOff
+
+func SetMode(m string) {
+	mode = m
+}
+
+// Middleware validates requests and responses of documented routes. It is
+// a no-op unless a mode other than off is set.
+func Middleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if mode == ModeOff {
+			context.Next()
+			return
+		}
+		op, ok := spec.operation(context.Request.Method, context.FullPath())
+		if !ok {
+			// Undocumented routes are reported by CheckRoutes
+			context.Next()
+			return
+		}
+		logger := logging.FromContext(context.Request.Context()).With("operation", op.OperationID)
+
+		if problems := spec.validateRequest(op, context); len(problems) > 0 {
+			logger.Warn("request does not match the OpenAPI document", "problems", problems)
+			if mode == ModeEnforce {
+				apperrors.Abort(context, apperrors.New(http.StatusBadRequest, apperrors.CodeValidationFailed, strings.Join(problems, "; ")))
+				return
+			}
+		}
+
+		writer := &bufferedWriter{ResponseWriter: context.Writer}
+		context.Writer = writer
+		context.Next()
+		context.Writer = writer.ResponseWriter
+
+		problems := spec.validateResponse(op, writer.Status(), writer.Header().Get("Content-Type"), writer.body.Bytes())
+		if len(problems) == 0 {
+			writer.flush()
+			return
+		}
+		logger.Error("response does not match the OpenAPI document", "status", writer.Status(), "problems", problems)
+		if mode == ModeEnforce {
+			err := errors.New("response does not match the OpenAPI document: " + strings.Join(problems, "; "))
+			apperrors.Abort(context, apperrors.New(http.StatusInternalServerError, apperrors.CodeInternal, err.Error()).Wrap(err))
+			return
+		}
+		writer.flush()
+	}
+}
+
+// bufferedWriter holds the body back until the response has been checked.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) flush() {
+	w.ResponseWriter.WriteHeaderNow()
+	w.ResponseWriter.Write(w.body.Bytes())
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"video-service/models"
+
+	"gorm.io/gorm"
+)
+
+type edits struct {
+	db *gorm.DB
+}
+
+func (r edits) Create(ctx context.Context, edit *models.VideoEdit) error {
+	return r.db.WithContext(ctx).Create(edit).Error
+}
+
+func (r edits) ByVideo(ctx context.Context, videoID uint) ([]models.VideoEdit, error) {
+	var list []models.VideoEdit
+	err := r.db.WithContext(ctx).Where("video_id = ?", videoID).Order("id DESC").Find(&list).Error
+	return list, err
+}
+
+func (r edits) DeleteByVideo(ctx context.Context, videoID uint) error {
+	return r.db.WithContext(ctx).Unscoped().Where("video_id = ?", videoID).Delete(&models.VideoEdit{}).Error
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// New returns the repositories backed by db. The queries are portable
+// between the Postgres and SQLite dialects.
+func New(db *gorm.DB) Repositories {
+	return Repositories{
+		Videos:     videos{db: db},
+		Uploads:    uploads{db: db},
+		Edits:      edits{db: db},
+		Reports:    reports{db: db},
+		UnitOfWork: unitOfWork{db: db},
+	}
+}
+
+type unitOfWork struct {
+	db *gorm.DB
+}
+
+func (u unitOfWork) Do(ctx context.Context, fn func(tx Repositories) error) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(New(tx))
+	})
+}
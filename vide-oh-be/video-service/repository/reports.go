@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"video-service/models"
+
+	"gorm.io/gorm"
+)
+
+type reports struct {
+	db *gorm.DB
+}
+
+func (r reports) Create(ctx context.Context, report *models.VideoReport) error {
+	return r.db.WithContext(ctx).Create(report).Error
+}
+
+func (r reports) Save(ctx context.Context, report *models.VideoReport) error {
+	return r.db.WithContext(ctx).Save(report).Error
+}
+
+func (r reports) ByID(ctx context.Context, videoID uint, id uint64) (*models.VideoReport, error) {
+	var report models.VideoReport
+	err := r.db.WithContext(ctx).Where("video_id = ?", videoID).First(&report, id).Error
+	return &report, err
+}
+
+func (r reports) ByVideo(ctx context.Context, videoID uint) ([]models.VideoReport, error) {
+	var list []models.VideoReport
+	err := r.db.WithContext(ctx).Where("video_id = ?", videoID).Order("id DESC").Find(&list).Error
+	return list, err
+}
+
+func (r reports) Reported(ctx context.Context, videoID uint, email string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.VideoReport{}).
+		Where("video_id = ? AND reporter_email = ?", videoID, email).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r reports) ResolveOpen(ctx context.Context, videoID uint, status, resolvedBy string) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.VideoReport{}).
+		Where("video_id = ? AND status = ?", videoID, models.ReportOpen).
+		Updates(map[string]any{"status": status, "resolved_by": resolvedBy, "resolved_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+func (r reports) DeleteByVideo(ctx context.Context, videoID uint) error {
+	return r.db.WithContext(ctx).Unscoped().Where("video_id = ?", videoID).Delete(&models.VideoReport{}).Error
+}
@@ -0,0 +1,94 @@
+// Package repository is the data access layer. Controllers depend on the
+// interfaces; the gorm implementation runs on Postgres in production and on
+// SQLite for local development.
+package repository
+
+import (
+	"context"
+	"time"
+	"video-service/models"
+)
+
+// Lookups return gorm.ErrRecordNotFound for missing rows and writes
+// gorm.ErrDuplicatedKey for unique violations, so apperrors.FromDB can map
+// them on either database.
+type VideoRepository interface {
+	Create(ctx context.Context, video *models.Video) error
+	Save(ctx context.Context, video *models.Video) error
+	Delete(ctx context.Context, id uint) error
+	ByID(ctx context.Context, id uint64) (*models.Video, error)
+	ByFilename(ctx context.Context, filename string) (*models.Video, error)
+	Reported(ctx context.Context) ([]models.Video, error)
+	// Search returns the ready videos that are not hidden and match the
+	// search, in its order
+	Search(ctx context.Context, search models.VideoSearch) ([]models.VideoHit, error)
+	// IncrementViews counts a stream URL handed out for the video
+	IncrementViews(ctx context.Context, id uint) error
+	// Transition moves the video from status from to status to. It returns
+	// false when the video is no longer in from, e.g. because a repeated
+	// S3 event already moved it.
+	Transition(ctx context.Context, id uint, from, to, reason string) (bool, error)
+	SetHLS(ctx context.Context, id uint, hls bool) error
+	SetMetadata(ctx context.Context, id uint, metadata models.VideoMetadata) error
+	SetStoryboard(ctx context.Context, id uint, sheets int) error
+	// Unfinished returns the videos still uploaded or processing that were
+	// last updated before t.
+	Unfinished(ctx context.Context, before time.Time) ([]models.Video, error)
+	// DeletedBefore returns the soft-deleted videos deleted before t, and
+	// FailedBefore the failed ones last updated before t
+	DeletedBefore(ctx context.Context, before time.Time) ([]models.Video, error)
+	FailedBefore(ctx context.Context, before time.Time) ([]models.Video, error)
+	// Purge removes a video row for good, unlike the soft Delete
+	Purge(ctx context.Context, id uint) error
+	// ForUpdate returns the repository with lookups that lock the rows
+	// they read until the transaction ends, so a check on a row still
+	// holds when it is saved. Only useful inside UnitOfWork.Do.
+	ForUpdate() VideoRepository
+}
+
+// UploadRepository holds the sessions of direct multipart uploads.
+type UploadRepository interface {
+	Create(ctx context.Context, upload *models.UploadSession) error
+	ByID(ctx context.Context, id uint64) (*models.UploadSession, error)
+	Delete(ctx context.Context, id uint) error
+	ExpiredBefore(ctx context.Context, t time.Time) ([]models.UploadSession, error)
+}
+
+// EditRepository holds the edit history of videos.
+type EditRepository interface {
+	Create(ctx context.Context, edit *models.VideoEdit) error
+	// ByVideo returns the edits of a video, newest first
+	ByVideo(ctx context.Context, videoID uint) ([]models.VideoEdit, error)
+	DeleteByVideo(ctx context.Context, videoID uint) error
+}
+
+// ReportRepository holds the reports users file against videos.
+type ReportRepository interface {
+	Create(ctx context.Context, report *models.VideoReport) error
+	Save(ctx context.Context, report *models.VideoReport) error
+	ByID(ctx context.Context, videoID uint, id uint64) (*models.VideoReport, error)
+	// ByVideo returns the reports of a video, newest first
+	ByVideo(ctx context.Context, videoID uint) ([]models.VideoReport, error)
+	// Reported tells whether email already reported the video
+	Reported(ctx context.Context, videoID uint, email string) (bool, error)
+	// ResolveOpen moves every open report of the video to status and
+	// returns how many there were
+	ResolveOpen(ctx context.Context, videoID uint, status, resolvedBy string) (int64, error)
+	DeleteByVideo(ctx context.Context, videoID uint) error
+}
+
+// Repositories is what controllers are constructed with. Inside
+// UnitOfWork.Do the same set is bound to the transaction.
+type Repositories struct {
+	Videos     VideoRepository
+	Uploads    UploadRepository
+	Edits      EditRepository
+	Reports    ReportRepository
+	UnitOfWork UnitOfWork
+}
+
+// UnitOfWork runs fn in one transaction, committed when fn returns nil and
+// rolled back otherwise. Nested calls use savepoints.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(tx Repositories) error) error
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"video-service/models"
+
+	"gorm.io/gorm"
+)
+
+// searchDocument is the weighted text search vector of a video, title
+// above description, in the text search configuration bound to its two
+// placeholders. The query repeats the expression of the index so
+// Postgres can use it: a parameter cast to regconfig is planned as the
+// constant the index was built with.
+const searchDocument = "(setweight(to_tsvector(CAST(? AS regconfig), videos.title), 'A') || " +
+	"setweight(to_tsvector(CAST(? AS regconfig), videos.description), 'B'))"
+
+// CreateSearchIndex adds the GIN index of the full-text search for
+// language. SQLite searches with LIKE and needs none. DDL takes no
+// parameters, so language goes into the statement; the configuration
+// only accepts names of lowercase letters and underscores.
+func CreateSearchIndex(db *gorm.DB, language string) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	document := strings.ReplaceAll(strings.ReplaceAll(searchDocument, "videos.", ""), "CAST(? AS regconfig)", "'"+language+"'::regconfig")
+	return db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_videos_search_%s ON videos USING GIN (%s)", language, document)).Error
+}
+
+// likeEscaper escapes the LIKE wildcards, and the escape character
+// itself, in a search word.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// ts_headline options; the whole title, and up to two fragments of the
+// description
+var (
+	titleHeadline       = fmt.Sprintf("HighlightAll=true, StartSel=%s, StopSel=%s", models.HighlightStart, models.HighlightStop)
+	descriptionHeadline = fmt.Sprintf("MaxFragments=2, MaxWords=30, MinWords=10, StartSel=%s, StopSel=%s", models.HighlightStart, models.HighlightStop)
+)
+
+func (r videos) Search(ctx context.Context, search models.VideoSearch) ([]models.VideoHit, error) {
+	// Without a Select gorm would pick the columns of VideoHit
+	db := r.db.WithContext(ctx).Model(&models.Video{}).Select("videos.*").
+		Where("videos.status = ? AND videos.hidden = ?", models.StatusReady, false)
+	if search.Uploader != "" {
+		db = db.Where("videos.owner_email = ?", search.Uploader)
+	}
+	if !search.UploadedAfter.IsZero() {
+		db = db.Where("videos.created_at >= ?", search.UploadedAfter)
+	}
+	if !search.UploadedBefore.IsZero() {
+		db = db.Where("videos.created_at < ?", search.UploadedBefore)
+	}
+	if search.MinDuration > 0 {
+		db = db.Where("videos.duration >= ?", search.MinDuration)
+	}
+	if search.MaxDuration > 0 {
+		db = db.Where("videos.duration <= ?", search.MaxDuration)
+	}
+
+	// rank binds the configuration of the search document twice
+	var rank string
+	var rankArgs []any
+	sort := search.Sort
+	text := strings.TrimSpace(search.Text)
+	switch {
+	case text != "" && r.db.Dialector.Name() == "postgres":
+		// websearch_to_tsquery takes "quoted phrases", or and -word and
+		// never fails on user input
+		language := search.Language
+		rank = "ts_rank_cd(" + searchDocument + ", query)"
+		rankArgs = []any{language, language}
+		db = db.Joins("CROSS JOIN websearch_to_tsquery(CAST(? AS regconfig), ?) AS query", language, text).
+			Where(searchDocument+" @@ query", language, language).
+			Select("videos.*, "+rank+" AS rank, "+
+				"ts_headline(CAST(? AS regconfig), videos.title, query, ?) AS title_headline, "+
+				"ts_headline(CAST(? AS regconfig), videos.description, query, ?) AS description_headline",
+				language, language, language, titleHeadline, language, descriptionHeadline)
+	case text != "":
+		// lower() rather than ILIKE, which SQLite lacks. The words are
+		// matched literally, wildcards included.
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			pattern := "%" + likeEscaper.Replace(word) + "%"
+			db = db.Where(r.db.Where(`lower(videos.title) LIKE ? ESCAPE '\'`, pattern).Or(`lower(videos.description) LIKE ? ESCAPE '\'`, pattern))
+		}
+		fallthrough
+	default:
+		if sort == models.SortRelevance {
+			sort = models.SortDate
+		}
+	}
+
+	// Keyset pagination: every order ends with the ID, which breaks ties
+	after := search.After
+	switch sort {
+	case models.SortRelevance:
+		if after != nil {
+			var args []any
+			args = append(append(args, rankArgs...), after.Rank)
+			args = append(append(args, rankArgs...), after.Rank, after.ID)
+			db = db.Where(fmt.Sprintf("(%[1]s < ? OR (%[1]s = ? AND videos.id < ?))", rank), args...)
+		}
+		db = db.Order("rank DESC, videos.id DESC")
+	case models.SortPopularity:
+		if after != nil {
+			db = db.Where("(videos.views < ? OR (videos.views = ? AND videos.id < ?))", after.Views, after.Views, after.ID)
+		}
+		db = db.Order("videos.views DESC, videos.id DESC")
+	default:
+		if after != nil {
+			db = db.Where("(videos.created_at < ? OR (videos.created_at = ? AND videos.id < ?))", after.CreatedAt, after.CreatedAt, after.ID)
+		}
+		db = db.Order("videos.created_at DESC, videos.id DESC")
+	}
+	if search.Limit > 0 {
+		db = db.Limit(search.Limit)
+	}
+
+	var hits []models.VideoHit
+	err := db.Find(&hits).Error
+	return hits, err
+}
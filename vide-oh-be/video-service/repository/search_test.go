@@ -0,0 +1,48 @@
+package repository_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"video-service/models"
+	"video-service/repository"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestSearchBindsTheLanguage(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun: true, DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statement *gorm.Statement
+	db.Callback().Query().After("gorm:query").Register("test:statement", func(db *gorm.DB) {
+		statement = db.Statement
+	})
+
+	repository.New(db).Videos.Search(context.Background(), models.VideoSearch{
+		Text: "cats", Language: "english", Sort: models.SortRelevance,
+		After: &models.SearchCursor{Rank: 0.5, ID: 7},
+	})
+	if statement == nil {
+		t.Fatal("no query")
+	}
+	sql := statement.SQL.String()
+	if strings.Contains(sql, "english") {
+		t.Errorf("language in the SQL: %s", sql)
+	}
+	languages := 0
+	for _, v := range statement.Vars {
+		if v == "english" {
+			languages++
+		}
+	}
+	// The query, the document of the match, the rank twice in the cursor
+	// and once selected, both headlines
+	if want := 1 + 2 + 2*2 + 2 + 2; languages != want {
+		t.Errorf("language bound %d times, want %d: %s %v", languages, want, sql, statement.Vars)
+	}
+}
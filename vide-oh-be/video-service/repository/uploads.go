@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"video-service/models"
+
+	"gorm.io/gorm"
+)
+
+type uploads struct {
+	db *gorm.DB
+}
+
+func (r uploads) Create(ctx context.Context, upload *models.UploadSession) error {
+	return r.db.WithContext(ctx).Create(upload).Error
+}
+
+func (r uploads) ByID(ctx context.Context, id uint64) (*models.UploadSession, error) {
+	var upload models.UploadSession
+	if err := r.db.WithContext(ctx).First(&upload, id).Error; err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// Delete removes the row for good; a finished session has nothing left
+// to keep.
+func (r uploads) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Unscoped().Delete(&models.UploadSession{}, id).Error
+}
+
+func (r uploads) ExpiredBefore(ctx context.Context, t time.Time) ([]models.UploadSession, error) {
+	var list []models.UploadSession
+	err := r.db.WithContext(ctx).Where("expires_at < ?", t).Find(&list).Error
+	return list, err
+}
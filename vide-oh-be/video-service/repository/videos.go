@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"video-service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type videos struct {
+	db *gorm.DB
+}
+
+func (r videos) Create(ctx context.Context, video *models.Video) error {
+	return r.db.WithContext(ctx).Create(video).Error
+}
+
+func (r videos) Save(ctx context.Context, video *models.Video) error {
+	return r.db.WithContext(ctx).Save(video).Error
+}
+
+func (r videos) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Video{}, id).Error
+}
+
+// Purge removes the row for good, deleted or not.
+func (r videos) Purge(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Unscoped().Delete(&models.Video{}, id).Error
+}
+
+func (r videos) ByID(ctx context.Context, id uint64) (*models.Video, error) {
+	var video models.Video
+	if err := r.db.WithContext(ctx).First(&video, id).Error; err != nil {
+		return nil, err
+	}
+	return &video, nil
+}
+
+func (r videos) ByFilename(ctx context.Context, filename string) (*models.Video, error) {
+	var video models.Video
+	if err := r.db.WithContext(ctx).Where("filename = ?", filename).First(&video).Error; err != nil {
+		return nil, err
+	}
+	return &video, nil
+}
+
+func (r videos) Reported(ctx context.Context) ([]models.Video, error) {
+	var list []models.Video
+	err := r.db.WithContext(ctx).Where("reported = ?", true).Find(&list).Error
+	return list, err
+}
+
+// IncrementViews counts a view without touching updated_at, so the ETag
+// stays.
+func (r videos) IncrementViews(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Model(&models.Video{}).Where("id = ?", id).
+		UpdateColumn("views", gorm.Expr("views + 1")).Error
+}
+
+func (r videos) Transition(ctx context.Context, id uint, from, to, reason string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Video{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]interface{}{"status": to, "failure_reason": reason})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r videos) SetHLS(ctx context.Context, id uint, hls bool) error {
+	return r.db.WithContext(ctx).Model(&models.Video{}).Where("id = ?", id).Update("hls", hls).Error
+}
+
+func (r videos) SetStoryboard(ctx context.Context, id uint, sheets int) error {
+	return r.db.WithContext(ctx).Model(&models.Video{}).Where("id = ?", id).Update("storyboard_sheets", sheets).Error
+}
+
+func (r videos) SetMetadata(ctx context.Context, id uint, metadata models.VideoMetadata) error {
+	// Select writes the zero values too
+	return r.db.WithContext(ctx).Model(&models.Video{}).Where("id = ?", id).
+		Select("duration", "width", "height", "video_codec", "audio_codec", "bitrate", "frame_rate", "rotation").
+		Updates(&models.Video{VideoMetadata: metadata}).Error
+}
+
+func (r videos) Unfinished(ctx context.Context, before time.Time) ([]models.Video, error) {
+	var list []models.Video
+	err := r.db.WithContext(ctx).
+		Where("status IN ? AND updated_at < ?", []string{models.StatusUploaded, models.StatusProcessing}, before).
+		Find(&list).Error
+	return list, err
+}
+
+func (r videos) DeletedBefore(ctx context.Context, before time.Time) ([]models.Video, error) {
+	var list []models.Video
+	err := r.db.WithContext(ctx).Unscoped().Where("deleted_at < ?", before).Find(&list).Error
+	return list, err
+}
+
+func (r videos) FailedBefore(ctx context.Context, before time.Time) ([]models.Video, error) {
+	var list []models.Video
+	err := r.db.WithContext(ctx).Where("status = ? AND updated_at < ?", models.StatusFailed, before).Find(&list).Error
+	return list, err
+}
+
+// ForUpdate adds SELECT … FOR UPDATE to the lookups. SQLite has no row
+// locks and drops the clause; it serializes the writing transactions.
+func (r videos) ForUpdate() VideoRepository {
+	return videos{db: r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Session(&gorm.Session{})}
+}
@@ -0,0 +1,112 @@
+package router
+
+import (
+	"log/slog"
+	"time"
+	"video-service/apperrors"
+	"video-service/controllers"
+	"video-service/health"
+	"video-service/logging"
+	"video-service/middleware"
+	"video-service/openapi"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+func CORS() gin.HandlerFunc {
+	// TO allow CORS
+	return func(c *gin.Context) {
+		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, If-Match, If-None-Match")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag, Location, Deprecation, Sunset, Link")
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+		c.Next()
+	}
+}
+
+// New builds the engine served by the Lambda handler and by -local mode.
+// On Lambda the API Gateway authorizer guards the protected routes, so
+// authorizer is nil; locally it is the in-process stand-in.
+func New(authorizer gin.HandlerFunc, videos *controllers.VideoController) *gin.Engine {
+	router := gin.New()
+	router.Use(apperrors.Recovery(), CORS())
+	router.NoRoute(apperrors.NoRoute)
+	router.MaxMultipartMemory = 10 * 1024 * 1024
+	Register(router, authorizer, videos)
+	return router
+}
+
+// The v1 routes stay until the sunset; /api/v2 replaces them.
+var (
+	v1Deprecated = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	v1Sunset     = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// Register mounts the /api/videos and /api/v2/videos routes on an
+// existing router.
+func Register(router gin.IRouter, authorizer gin.HandlerFunc, videos *controllers.VideoController) {
+	api := router.Group("/api/videos")
+	api.Use(otelgin.Middleware("video-service"), logging.Middleware(slog.Default()), openapi.Middleware())
+	{
+		api.GET("/health/live", health.Live)
+		api.GET("/health/ready", health.Ready)
+		api.GET("/openapi.json", openapi.Serve)
+
+		v1 := api.Group("", middleware.Deprecated(v1Deprecated, v1Sunset, "/api/v2/videos"))
+		v1.GET("/video-stream/:name", videos.StreamVideo)
+		v1.GET("/search-videos", videos.SearchVideos)
+
+		// protected
+		protected := v1.Group("")
+		if authorizer != nil {
+			protected.Use(authorizer)
+		}
+		protected.GET("/ping", controllers.Ping)
+		protected.GET("/report-video/:id", videos.ReportVideo)
+		protected.GET("/all-reported-videos", videos.GetAllReportedVideos)
+		protected.POST("/upload-video", videos.UploadVideo)
+		protected.GET("/delete-video/:id", videos.DeleteVideo)
+	}
+
+	v2 := router.Group("/api/v2/videos")
+	v2.Use(otelgin.Middleware("video-service"), logging.Middleware(slog.Default()), openapi.Middleware())
+	{
+		v2.GET("", videos.ListVideos)
+		v2.GET("/:id", videos.GetVideo)
+		v2.GET("/:id/stream", videos.GetStream)
+		v2.GET("/:id/hls/master.m3u8", videos.MasterPlaylist)
+		v2.GET("/:id/hls/:rendition/index.m3u8", videos.RenditionPlaylist)
+		v2.GET("/:id/storyboard.vtt", videos.StoryboardTrack)
+
+		// protected
+		protected := v2.Group("")
+		if authorizer != nil {
+			protected.Use(authorizer)
+		}
+		protected.POST("", videos.CreateVideo)
+		protected.GET("/reported", videos.ListReportedVideos)
+		protected.PATCH("/:id", videos.UpdateVideo)
+		protected.DELETE("/:id", videos.RemoveVideo)
+		protected.GET("/:id/edits", videos.ListVideoEdits)
+		protected.POST("/:id/reports", videos.CreateReport)
+		protected.GET("/:id/reports", videos.ListVideoReports)
+		protected.DELETE("/:id/reports", videos.DeleteReports)
+		protected.POST("/:id/reports/resolve", videos.ResolveReports)
+		protected.PATCH("/:id/reports/:reportId", videos.ResolveReport)
+		protected.GET("/:id/status", videos.GetVideoStatus)
+		protected.GET("/:id/original", videos.GetOriginal)
+		protected.POST("/:id/thumbnail", videos.SetPosterFrame)
+		protected.PUT("/:id/thumbnail", videos.UploadThumbnail)
+
+		// direct-to-S3 multipart uploads
+		protected.POST("/uploads", videos.CreateUpload)
+		protected.GET("/uploads/:id/parts", videos.GetUploadParts)
+		protected.POST("/uploads/:id/complete", videos.CompleteUpload)
+		protected.DELETE("/uploads/:id", videos.AbortUpload)
+	}
+}
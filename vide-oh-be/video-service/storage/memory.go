@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object is a stored object of the in-memory store.
+type Object struct {
+	Body        []byte
+	ContentType string
+}
+
+// Memory is an in-memory Bucket for tests and local runs without a bucket.
+// Its presigned URLs point nowhere; UploadPart stands in for the PUT a
+// client would send to a presigned part URL.
+type Memory struct {
+	mu      sync.Mutex
+	objects map[string]Object
+	uploads map[string]*memoryUpload
+	nextID  int
+}
+
+type memoryUpload struct {
+	key         string
+	contentType string
+	initiated   time.Time
+	parts       map[int32][]byte
+	// Content-Length signed into each part URL
+	sizes map[int32]int64
+}
+
+func NewMemory() *Memory {
+	return &Memory{objects: map[string]Object{}, uploads: map[string]*memoryUpload{}}
+}
+
+func (m *Memory) PutObject(ctx context.Context, key string, body io.Reader, contentType string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = Object{Body: data, ContentType: contentType}
+	return nil
+}
+
+func (m *Memory) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	object, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return io.NopCloser(bytes.NewReader(object.Body)), nil
+}
+
+// DeleteObject succeeds for missing keys, as S3 does.
+func (m *Memory) DeleteObject(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *Memory) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := 0
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.objects, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *Memory) PresignGetObject(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return fmt.Sprintf("https://memory.invalid/%s?X-Amz-Expires=%d", url.PathEscape(key), int(ttl.Seconds())), nil
+}
+
+// Object returns the object stored under key.
+func (m *Memory) Object(key string) (Object, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	object, ok := m.objects[key]
+	return object, ok
+}
+
+// Keys lists the stored keys in order.
+func (m *Memory) Keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.objects))
+	for key := range m.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *Memory) CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	uploadID := strconv.Itoa(m.nextID)
+	m.uploads[uploadID] = &memoryUpload{key: key, contentType: contentType, initiated: time.Now(), parts: map[int32][]byte{}, sizes: map[int32]int64{}}
+	return uploadID, nil
+}
+
+func (m *Memory) PresignUploadPart(ctx context.Context, key, uploadID string, partNumber int32, size int64, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if upload, ok := m.uploads[uploadID]; ok {
+		upload.sizes[partNumber] = size
+	}
+	return fmt.Sprintf("https://memory.invalid/%s?partNumber=%d&uploadId=%s&X-Amz-Expires=%d", url.PathEscape(key), partNumber, url.QueryEscape(uploadID), int(ttl.Seconds())), nil
+}
+
+// UploadPart stores a part and returns its ETag. Like S3 with a presigned
+// URL, it rejects a body whose length differs from the signed size.
+func (m *Memory) UploadPart(key, uploadID string, partNumber int32, body []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.key != key {
+		return "", ErrUploadNotFound
+	}
+	if size, ok := upload.sizes[partNumber]; ok && size != int64(len(body)) {
+		return "", fmt.Errorf("part %d is %d bytes, the URL was signed for %d", partNumber, len(body), size)
+	}
+	upload.parts[partNumber] = body
+	return partETag(body), nil
+}
+
+func (m *Memory) ListParts(ctx context.Context, key, uploadID string) ([]Part, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.key != key {
+		return nil, ErrUploadNotFound
+	}
+	parts := make([]Part, 0, len(upload.parts))
+	for number, data := range upload.parts {
+		parts = append(parts, Part{Number: number, ETag: partETag(data), Size: int64(len(data))})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+func (m *Memory) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []Part) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.key != key {
+		return ErrUploadNotFound
+	}
+	var body []byte
+	for i, part := range parts {
+		data, ok := upload.parts[part.Number]
+		if !ok || partETag(data) != part.ETag || (i > 0 && part.Number <= parts[i-1].Number) {
+			return fmt.Errorf("%w: part %d", ErrInvalidParts, part.Number)
+		}
+		body = append(body, data...)
+	}
+	delete(m.uploads, uploadID)
+	m.objects[key] = Object{Body: body, ContentType: upload.contentType}
+	return nil
+}
+
+func (m *Memory) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.key != key {
+		return ErrUploadNotFound
+	}
+	delete(m.uploads, uploadID)
+	return nil
+}
+
+func (m *Memory) ListMultipartUploads(ctx context.Context) ([]MultipartUpload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	uploads := make([]MultipartUpload, 0, len(m.uploads))
+	for uploadID, upload := range m.uploads {
+		uploads = append(uploads, MultipartUpload{Key: upload.key, UploadID: uploadID, Initiated: upload.initiated})
+	}
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].Initiated.Before(uploads[j].Initiated) })
+	return uploads, nil
+}
+
+func partETag(body []byte) string {
+	sum := md5.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
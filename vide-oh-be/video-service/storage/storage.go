@@ -0,0 +1,86 @@
+// Package storage hides the S3 bucket behind the operations the handlers
+// need, so they can run against the in-memory fake.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ObjectStore holds the uploaded videos, their thumbnails and HLS
+// renditions.
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, body io.Reader, contentType string) error
+	// GetObject returns ErrObjectNotFound for missing keys
+	GetObject(ctx context.Context, key string) (io.ReadCloser, error)
+	DeleteObject(ctx context.Context, key string) error
+	// DeletePrefix deletes every object whose key starts with prefix and
+	// returns how many there were
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// Presigner hands out temporary download URLs for stored objects.
+type Presigner interface {
+	PresignGetObject(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// MultipartUploader lets clients upload large files straight to the bucket
+// in parts, through presigned part URLs.
+type MultipartUploader interface {
+	CreateMultipartUpload(ctx context.Context, key, contentType string) (uploadID string, err error)
+	// PresignUploadPart signs size as the Content-Length, so the URL only
+	// accepts a part of exactly that many bytes
+	PresignUploadPart(ctx context.Context, key, uploadID string, partNumber int32, size int64, ttl time.Duration) (string, error)
+	// ListParts returns the parts uploaded so far with their sizes. It
+	// returns ErrUploadNotFound when the upload is gone.
+	ListParts(ctx context.Context, key, uploadID string) ([]Part, error)
+	// CompleteMultipartUpload assembles the parts, which must be listed in
+	// ascending order
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []Part) error
+	// AbortMultipartUpload frees the stored parts. It returns
+	// ErrUploadNotFound when the upload is already gone.
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+	ListMultipartUploads(ctx context.Context) ([]MultipartUpload, error)
+}
+
+// Bucket is everything the video handlers need from the bucket.
+type Bucket interface {
+	ObjectStore
+	Presigner
+	MultipartUploader
+}
+
+// Part is an uploaded part, identified by the ETag S3 returned for it.
+// Size is only set by ListParts.
+type Part struct {
+	Number int32
+	ETag   string
+	Size   int64
+}
+
+// MultipartUpload is an upload that was started but neither completed nor
+// aborted.
+type MultipartUpload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
+
+var (
+	ErrObjectNotFound = errors.New("object not found")
+	// ErrUploadNotFound is returned for unknown, completed or aborted
+	// uploads.
+	ErrUploadNotFound = errors.New("multipart upload not found")
+	// ErrInvalidParts is returned when completing with a part that was not
+	// uploaded, a wrong ETag, or a part other than the last below 5 MiB.
+	ErrInvalidParts = errors.New("invalid multipart upload parts")
+)
+
+// Limits of S3 multipart uploads.
+const (
+	MinPartSize = 5 << 20
+	MaxPartSize = 5 << 30
+	MaxParts    = 10000
+)
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strconv"
+	"video-service/logging"
+)
+
+var (
+	smtpHost = "localhost"
+	smtpPort = "1025"
+	mailFrom = "vide.oh@smtp.com"
+)
+
+// SetMailServer configures the SMTP relay used for notifications.
+func SetMailServer(host string, port int, from string) {
+	smtpHost = host
+	smtpPort = strconv.Itoa(port)
+	mailFrom = from
+}
+
+// SendVideoDeletedMail tells the owner that an administrator or support
+// deleted their video.
+func SendVideoDeletedMail(ctx context.Context, email string, title string, reason string) {
+	message := fmt.Sprintf("Your video %q has been deleted by the moderators.", title)
+	if reason != "" {
+		message += "\nReason: " + reason
+	}
+	sendMail(ctx, email, "video deleted", message)
+}
+
+func sendMail(ctx context.Context, email string, kind string, message string) {
+	logger := logging.FromContext(ctx).With("recipient", email)
+
+	// Receiver email address.
+	to := []string{
+		email,
+	}
+
+	// Sending email.
+	err := smtp.SendMail(smtpHost+":"+smtpPort, nil, mailFrom, to, []byte(message))
+	if err != nil {
+		logger.Error("failed to send "+kind+" mail", "error", err)
+		return
+	}
+	logger.Info(kind + " mail sent")
+}
@@ -0,0 +1,320 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Chat is a live support conversation over the API Gateway WebSocket API.
+// Messages sent by either side of the conversation, including this one,
+// arrive through Receive. Send and Receive may be called from different
+// goroutines.
+type Chat struct {
+	client *Client
+	conn   net.Conn
+	reader *bufio.Reader
+
+	writeMu sync.Mutex
+	closed  bool
+}
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	maxMessageSize = 1 << 20
+	websocketGUID  = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+var ErrChatClosed = errors.New("videoh: chat closed")
+
+// DialChat opens the conversation of userEmail on the WebSocket API at
+// websocketURL (the wss:// stage URL). Support users may join any
+// conversation, registered users only their own. The client must be
+// logged in.
+func (c *Client) DialChat(ctx context.Context, websocketURL, userEmail string) (*Chat, error) {
+	token, err := c.authToken(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(websocketURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebSocket URL: %w", err)
+	}
+	query := u.Query()
+	query.Set("token", token)
+	query.Set("userEmail", userEmail)
+	u.RawQuery = query.Encode()
+
+	conn, err := dial(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	chat := &Chat{client: c, conn: conn, reader: bufio.NewReader(conn)}
+	if err := chat.handshake(ctx, u); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return chat, nil
+}
+
+func dial(ctx context.Context, u *url.URL) (net.Conn, error) {
+	host, port := u.Hostname(), u.Port()
+	switch u.Scheme {
+	case "ws":
+		if port == "" {
+			port = "80"
+		}
+	case "wss":
+		if port == "" {
+			port = "443"
+		}
+	default:
+		return nil, fmt.Errorf("invalid WebSocket URL %q: scheme must be ws or wss", u.Redacted())
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, fmt.Errorf("chat: %w", err)
+	}
+	if u.Scheme == "wss" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("chat: %w", err)
+		}
+		conn = tlsConn
+	}
+	return conn, nil
+}
+
+func (chat *Chat) handshake(ctx context.Context, u *url.URL) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		chat.conn.SetDeadline(deadline)
+		defer chat.conn.SetDeadline(time.Time{})
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("User-Agent", chat.client.userAgent)
+	if err := req.Write(chat.conn); err != nil {
+		return fmt.Errorf("chat: %w", err)
+	}
+
+	resp, err := http.ReadResponse(chat.reader, req)
+	if err != nil {
+		return fmt.Errorf("chat: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close()
+		return decodeError(resp)
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return errors.New("chat: invalid Sec-WebSocket-Accept")
+	}
+	return nil
+}
+
+// Send posts a message to the conversation.
+func (chat *Chat) Send(ctx context.Context, text string) error {
+	token, err := chat.client.authToken(ctx, false)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(map[string]string{"message": text, "token": token})
+	if err != nil {
+		return err
+	}
+	return chat.writeFrame(ctx, opText, payload)
+}
+
+// Receive blocks until the next message arrives. It returns
+// ErrChatClosed once the server or Close ended the conversation.
+func (chat *Chat) Receive(ctx context.Context) (Message, error) {
+	stop := context.AfterFunc(ctx, func() {
+		chat.conn.SetReadDeadline(time.Now())
+	})
+	defer func() {
+		if !stop() {
+			chat.conn.SetReadDeadline(time.Time{})
+		}
+	}()
+
+	var message []byte
+	for {
+		fin, opcode, payload, err := chat.readFrame()
+		if err != nil {
+			if ctx.Err() != nil {
+				return Message{}, ctx.Err()
+			}
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return Message{}, ErrChatClosed
+			}
+			return Message{}, fmt.Errorf("chat: %w", err)
+		}
+
+		switch opcode {
+		case opPing:
+			if err := chat.writeFrame(ctx, opPong, payload); err != nil {
+				return Message{}, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			chat.writeFrame(ctx, opClose, payload)
+			chat.conn.Close()
+			return Message{}, ErrChatClosed
+		case opText, opContinuation:
+			message = append(message, payload...)
+			if len(message) > maxMessageSize {
+				return Message{}, errors.New("chat: message too large")
+			}
+		}
+		if !fin {
+			continue
+		}
+
+		var out Message
+		if err := json.Unmarshal(message, &out); err != nil {
+			return Message{}, fmt.Errorf("chat: decoding message: %w", err)
+		}
+		return out, nil
+	}
+}
+
+// Close ends the conversation.
+func (chat *Chat) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := chat.writeFrame(ctx, opClose, []byte{0x03, 0xe8}) // 1000, normal closure
+	if errors.Is(err, ErrChatClosed) {
+		return nil
+	}
+	chat.writeMu.Lock()
+	chat.closed = true
+	chat.writeMu.Unlock()
+	if closeErr := chat.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (chat *Chat) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(chat.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(chat.reader, extended[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(chat.reader, extended[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > maxMessageSize {
+		err = errors.New("frame too large")
+		return
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(chat.reader, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(chat.reader, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// writeFrame sends one masked, unfragmented frame, as clients must.
+func (chat *Chat) writeFrame(ctx context.Context, opcode byte, payload []byte) error {
+	chat.writeMu.Lock()
+	defer chat.writeMu.Unlock()
+	if chat.closed {
+		return ErrChatClosed
+	}
+
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		chat.conn.SetWriteDeadline(deadline)
+		defer chat.conn.SetWriteDeadline(time.Time{})
+	}
+	if _, err := chat.conn.Write(frame); err != nil {
+		return fmt.Errorf("chat: %w", err)
+	}
+	if opcode == opClose {
+		chat.closed = true
+	}
+	return nil
+}
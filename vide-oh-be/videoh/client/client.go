@@ -0,0 +1,290 @@
+// Package client is a typed Go client for the vide-oh REST APIs and the
+// support chat WebSocket API.
+//
+//	c, err := client.New("https://abc.execute-api.eu-central-1.amazonaws.com/dev",
+//		client.WithAPIKey(os.Getenv("VIDEOH_API_KEY")))
+//	if _, err := c.Login(ctx, email, password); err != nil { ... }
+//	videos, err := c.SearchVideos(ctx, "cats")
+//
+// Failed calls return *Error, decoded from the services' problem+json body.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The API has no refresh endpoint. A client that logged in with Login
+// logs in again when its token is about to expire or is rejected.
+const refreshMargin = time.Minute
+
+type Client struct {
+	baseURL    *url.URL
+	apiKey     string
+	httpClient *http.Client
+	retry      RetryPolicy
+	userAgent  string
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	email     string
+	password  string
+}
+
+type Option func(*Client)
+
+// WithAPIKey sets the API Gateway usage plan key sent as x-api-key.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// WithToken starts the client with an existing JWT. It is not refreshed.
+func WithToken(token string) Option {
+	return func(c *Client) { c.setToken(token) }
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New creates a client for the REST API at baseURL, e.g. the API Gateway
+// stage URL or http://localhost:8080 for the dev gateway.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retry:      DefaultRetryPolicy,
+		userAgent:  "videoh-go-client",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Token returns the current JWT, or "" before Login.
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+func (c *Client) setToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+	c.expiresAt = tokenExpiry(token)
+}
+
+// authToken returns a token for an authenticated call, logging in again
+// first when the stored credentials allow it and the token is expiring or
+// was just rejected.
+func (c *Client) authToken(ctx context.Context, rejected bool) (string, error) {
+	c.mu.Lock()
+	token, expiresAt, email, password := c.token, c.expiresAt, c.email, c.password
+	c.mu.Unlock()
+
+	expiring := !expiresAt.IsZero() && time.Until(expiresAt) < refreshMargin
+	if email != "" && (rejected || expiring) {
+		return c.Login(ctx, email, password)
+	}
+	if token == "" {
+		return "", ErrNotAuthenticated
+	}
+	return token, nil
+}
+
+func (c *Client) canRelogin() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.email != ""
+}
+
+// call describes one API request. body returns a fresh reader for every
+// attempt; a call whose body cannot be replayed is never retried.
+type call struct {
+	method      string
+	path        string
+	query       url.Values
+	body        func() (io.Reader, error)
+	replayable  bool
+	contentType string
+	auth        bool
+	// ifMatch is sent as If-Match, for conditional updates
+	ifMatch string
+}
+
+func jsonCall(method, path string, in interface{}) (call, error) {
+	c := call{method: method, path: path, replayable: true}
+	if in == nil {
+		return c, nil
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return c, err
+	}
+	c.contentType = "application/json"
+	c.body = func() (io.Reader, error) { return bytes.NewReader(data), nil }
+	return c, nil
+}
+
+// do runs r and decodes a JSON response into out, if out is not nil.
+func (c *Client) do(ctx context.Context, r call, out interface{}) error {
+	_, err := c.doTagged(ctx, r, out)
+	return err
+}
+
+// doTagged runs r like do and returns the ETag of the response, which a
+// later conditional update sends back in If-Match.
+func (c *Client) doTagged(ctx context.Context, r call, out interface{}) (string, error) {
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	tag := resp.Header.Get("ETag")
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return tag, err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return "", fmt.Errorf("%s %s: decoding response: %w", r.method, r.path, err)
+	}
+	return tag, nil
+}
+
+// send runs r with auth, retries and error decoding. The caller closes the
+// body of a successful response.
+func (c *Client) send(ctx context.Context, r call) (*http.Response, error) {
+	relogged, refresh := false, false
+	for attempt := 1; ; attempt++ {
+		req, err := c.newRequest(ctx, r, refresh)
+		refresh = false
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.httpClient.Do(req)
+
+		if wait, ok := c.retry.next(r, resp, err, attempt); ok {
+			if resp != nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			if err := sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", r.method, r.path, err)
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && r.auth && !relogged && r.replayable && c.canRelogin() {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			relogged, refresh = true, true
+			continue
+		}
+		if resp.StatusCode >= 400 {
+			defer resp.Body.Close()
+			return nil, decodeError(resp)
+		}
+		return resp, nil
+	}
+}
+
+func (c *Client) newRequest(ctx context.Context, r call, rejected bool) (*http.Request, error) {
+	u := c.baseURL.JoinPath(r.path)
+	u.RawQuery = r.query.Encode()
+
+	var body io.Reader
+	if r.body != nil {
+		var err error
+		if body, err = r.body(); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if r.ifMatch != "" {
+		req.Header.Set("If-Match", r.ifMatch)
+	}
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.apiKey != "" {
+		req.Header.Set("x-api-key", c.apiKey)
+	}
+	if r.auth {
+		token, err := c.authToken(ctx, rejected)
+		if err != nil {
+			return nil, err
+		}
+		// The services expect the bare token, without a Bearer prefix
+		req.Header.Set("Authorization", token)
+	}
+	return req, nil
+}
+
+// tokenExpiry reads the exp claim without verifying the token; the zero
+// time means unknown.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.ExpiresAt, 0)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+var ErrNotAuthenticated = errors.New("videoh: not logged in; call Login or use WithToken")
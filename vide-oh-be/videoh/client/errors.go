@@ -0,0 +1,121 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Error codes returned by the services; see each service's
+// apperrors package.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthenticated      = "unauthenticated"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeInternal             = "internal"
+	CodeUnavailable          = "unavailable"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeEmailTaken           = "email_taken"
+	CodeWeakPassword         = "weak_password"
+	CodeUserBlocked          = "user_blocked"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeThumbnailFailed      = "thumbnail_failed"
+	CodeUploadFailed         = "upload_failed"
+	CodeInvalidVideo         = "invalid_video"
+	CodeVideoTooLong         = "video_too_long"
+	CodeResolutionTooHigh    = "resolution_too_high"
+)
+
+// Error is a failed API call. Responses that are not problem+json, such
+// as a bare 502 from a proxy, get a code derived from the status.
+type Error struct {
+	StatusCode    int
+	Code          string
+	Title         string
+	Detail        string
+	Instance      string
+	CorrelationID string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("videoh: %d %s", e.StatusCode, e.Code)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.CorrelationID != "" {
+		msg += " (correlation id " + e.CorrelationID + ")"
+	}
+	return msg
+}
+
+// HasCode reports whether err is an *Error with the given code.
+func HasCode(err error, code string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
+
+func IsNotFound(err error) bool {
+	return HasCode(err, CodeNotFound)
+}
+
+func decodeError(resp *http.Response) error {
+	e := &Error{StatusCode: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" || mediaType == "application/json" {
+		var problem struct {
+			Title         string `json:"title"`
+			Detail        string `json:"detail"`
+			Instance      string `json:"instance"`
+			Code          string `json:"code"`
+			CorrelationID string `json:"correlationId"`
+			// API Gateway's default error body
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &problem) == nil {
+			e.Code = problem.Code
+			e.Detail = problem.Detail
+			e.Instance = problem.Instance
+			e.CorrelationID = problem.CorrelationID
+			if problem.Title != "" {
+				e.Title = problem.Title
+			}
+			if e.Detail == "" {
+				e.Detail = problem.Message
+			}
+		}
+	} else {
+		e.Detail = strings.TrimSpace(string(body))
+	}
+	if e.CorrelationID == "" {
+		e.CorrelationID = resp.Header.Get("X-Correlation-ID")
+	}
+	if e.Code == "" {
+		e.Code = codeForStatus(resp.StatusCode)
+	}
+	return e
+}
+
+func codeForStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case status == http.StatusForbidden:
+		return CodeForbidden
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusConflict:
+		return CodeConflict
+	case status >= 500:
+		return CodeUnavailable
+	}
+	return CodeInvalidRequest
+}
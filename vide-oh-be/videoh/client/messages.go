@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Messages returns the support conversation of userEmail. Support users
+// may read any conversation, registered users only their own.
+func (c *Client) Messages(ctx context.Context, userEmail string) ([]Message, error) {
+	var out []Message
+	if err := c.do(ctx, c.authed(http.MethodGet, "/api/messages/"+url.PathEscape(userEmail)+"/all", nil), &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Conversations lists the emails of users who have messages. Support only.
+func (c *Client) Conversations(ctx context.Context) ([]string, error) {
+	var out []string
+	if err := c.do(ctx, c.authed(http.MethodGet, "/api/messages/user-emails", nil), &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy retries transport errors and 429/502/503/504 responses with
+// jittered exponential backoff. Only idempotent methods are retried after
+// a transport error or a 502/504; 429 and 503 mean the request was not
+// processed, so any call with a replayable body is retried.
+type RetryPolicy struct {
+	// MaxAttempts includes the first try; 1 disables retries
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// next reports whether attempt should be followed by another one, and
+// after how long.
+func (p RetryPolicy) next(r call, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !r.replayable {
+		return 0, false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 0, false
+	}
+
+	idempotent := r.method == http.MethodGet || r.method == http.MethodHead ||
+		r.method == http.MethodPut || r.method == http.MethodDelete
+	switch {
+	case err != nil:
+		if !idempotent {
+			return 0, false
+		}
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		if wait, ok := retryAfter(resp); ok {
+			return min(wait, p.MaxDelay), true
+		}
+	case resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusGatewayTimeout:
+		if !idempotent {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	return p.backoff(attempt), true
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	// Full jitter keeps concurrent scripts from retrying in lockstep
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// retryAfter reads a Retry-After header given in seconds.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
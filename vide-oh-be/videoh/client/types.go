@@ -0,0 +1,96 @@
+package client
+
+import "time"
+
+type Role int
+
+// Same values as user-service/models.UserRole.
+const (
+	Administrator  Role = 0
+	RegisteredUser Role = 1
+	SupportUser    Role = 2
+)
+
+func (r Role) String() string {
+	switch r {
+	case Administrator:
+		return "Administrator"
+	case RegisteredUser:
+		return "RegisteredUser"
+	case SupportUser:
+		return "SupportUser"
+	}
+	return "Unknown"
+}
+
+type User struct {
+	ID        uint       `json:"ID"`
+	CreatedAt time.Time  `json:"CreatedAt"`
+	UpdatedAt time.Time  `json:"UpdatedAt"`
+	DeletedAt *time.Time `json:"DeletedAt"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Role      Role       `json:"userRole"`
+	Blocked   bool       `json:"blocked"`
+	// Set while Blocked
+	BlockedReason string `json:"blockedReason"`
+}
+
+type Registration struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Video is a search result; ThumbnailURL is presigned and expires. Hidden
+// videos are left out of searches because of their reports.
+type Video struct {
+	ID           uint   `json:"ID"`
+	Title        string `json:"title"`
+	Filename     string `json:"filename"`
+	Description  string `json:"description"`
+	OwnerEmail   string `json:"ownerEmail"`
+	Reported     bool   `json:"reported"`
+	Hidden       bool   `json:"hidden"`
+	Views        int64  `json:"views"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+	// uploaded, processing, ready or failed; searches only return ready
+	// videos
+	Status string `json:"status"`
+	// Nil until processing generated the seek previews
+	Storyboard *Storyboard `json:"storyboard,omitempty"`
+	// Probed when the video is uploaded; zero for older videos
+	Duration   float64 `json:"duration"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	VideoCodec string  `json:"videoCodec"`
+	AudioCodec string  `json:"audioCodec"`
+	Bitrate    int64   `json:"bitrate"`
+	FrameRate  float64 `json:"frameRate"`
+	Rotation   int     `json:"rotation"`
+}
+
+// Stream is what a player loads: the HLS master playlist, or the .mp4 of
+// videos processed before HLS. URL is presigned and expires.
+type Stream struct {
+	URL string `json:"url"`
+	// hls or mp4
+	Format string `json:"format"`
+}
+
+// Storyboard links the WebVTT thumbnails track of a video and the
+// presigned sprite sheets its cues point into.
+type Storyboard struct {
+	Track   string   `json:"track"`
+	Sprites []string `json:"sprites"`
+}
+
+type Message struct {
+	ID         uint      `json:"ID"`
+	CreatedAt  time.Time `json:"CreatedAt"`
+	Content    string    `json:"content"`
+	OwnerEmail string    `json:"ownerEmail"`
+	Date       time.Time `json:"date"`
+	// SentByUser is false for replies from support
+	SentByUser bool `json:"sentByUser"`
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Login exchanges credentials for a JWT. The credentials are kept in
+// memory so the client can log in again when the token expires.
+func (c *Client) Login(ctx context.Context, email, password string) (string, error) {
+	r, err := jsonCall(http.MethodPost, "/api/v2/users/sessions", map[string]string{"email": email, "password": password})
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Token string `json:"token"`
+	}
+	if err := c.do(ctx, r, &out); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = out.Token
+	c.expiresAt = tokenExpiry(out.Token)
+	c.email, c.password = email, password
+	return out.Token, nil
+}
+
+// Logout forgets the token and the stored credentials.
+func (c *Client) Logout() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token, c.email, c.password = "", "", ""
+	c.expiresAt = time.Time{}
+}
+
+func (c *Client) Register(ctx context.Context, registration Registration) (*User, error) {
+	r, err := jsonCall(http.MethodPost, "/api/v2/users", registration)
+	if err != nil {
+		return nil, err
+	}
+	var out User
+	if err := c.do(ctx, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) CurrentUser(ctx context.Context) (*User, error) {
+	var out User
+	if err := c.do(ctx, c.authed(http.MethodGet, "/api/v2/users/me", nil), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) User(ctx context.Context, id uint) (*User, error) {
+	var out User
+	if err := c.do(ctx, c.authed(http.MethodGet, userPath(id), nil), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ChangeName renames the logged in user. The update is conditional on the
+// version read just before, so it fails with 412 when the account changed
+// in between.
+func (c *Client) ChangeName(ctx context.Context, name string) error {
+	tag, err := c.doTagged(ctx, c.authed(http.MethodGet, "/api/v2/users/me", nil), nil)
+	if err != nil {
+		return err
+	}
+	r, err := jsonCall(http.MethodPatch, "/api/v2/users/me", map[string]string{"name": name})
+	if err != nil {
+		return err
+	}
+	r.auth, r.ifMatch = true, tag
+	return c.do(ctx, r, nil)
+}
+
+// RegisteredUsers lists users with the RegisteredUser role. Administrator
+// only.
+func (c *Client) RegisteredUsers(ctx context.Context) ([]User, error) {
+	var out []User
+	if err := c.do(ctx, c.authed(http.MethodGet, "/api/v2/users", nil), &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BlockUser blocks the user with id and mails them the reason.
+// Administrator only.
+func (c *Client) BlockUser(ctx context.Context, id uint, reason string) error {
+	r, err := jsonCall(http.MethodPut, userPath(id)+"/block", map[string]string{"reason": reason})
+	if err != nil {
+		return err
+	}
+	r.auth = true
+	return c.do(ctx, r, nil)
+}
+
+// UnblockUser lifts a block and mails the user, with reason when it is
+// not empty. Administrator only.
+func (c *Client) UnblockUser(ctx context.Context, id uint, reason string) error {
+	var body interface{}
+	if reason != "" {
+		body = map[string]string{"reason": reason}
+	}
+	r, err := jsonCall(http.MethodDelete, userPath(id)+"/block", body)
+	if err != nil {
+		return err
+	}
+	r.auth = true
+	return c.do(ctx, r, nil)
+}
+
+func userPath(id uint) string {
+	return "/api/v2/users/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// authed builds a bodiless call that sends the token.
+func (c *Client) authed(method, path string, query url.Values) call {
+	return call{method: method, path: path, query: query, replayable: true, auth: true}
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SearchVideos returns every video whose title or description matches query,
+// best matches first; an empty query lists every video, newest first. It
+// follows the pages of the search until the last one.
+func (c *Client) SearchVideos(ctx context.Context, query string) ([]Video, error) {
+	out := []Video{}
+	params := url.Values{"limit": {"100"}}
+	if query != "" {
+		params.Set("query", query)
+	}
+	for {
+		r := call{method: http.MethodGet, path: "/api/v2/videos", query: params, replayable: true}
+		var page []Video
+		cursor, err := c.page(ctx, r, &page)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, page...)
+		if cursor == "" {
+			return out, nil
+		}
+		params.Set("cursor", cursor)
+	}
+}
+
+// page runs r like do and returns the cursor of the next page, from the
+// Link header, or "" on the last page.
+func (c *Client) page(ctx context.Context, r call, out interface{}) (string, error) {
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return "", fmt.Errorf("%s %s: decoding response: %w", r.method, r.path, err)
+	}
+	return nextCursor(resp.Header.Get("Link")), nil
+}
+
+// nextCursor reads the cursor from the rel="next" link. The link carries
+// the public URL of the API, which is not necessarily the client's base
+// URL, so only the cursor is kept.
+func nextCursor(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			return ""
+		}
+		return u.Query().Get("cursor")
+	}
+	return ""
+}
+
+// ReportedVideos lists videos flagged by viewers. Administrator only.
+func (c *Client) ReportedVideos(ctx context.Context) ([]Video, error) {
+	var out []Video
+	if err := c.do(ctx, c.authed(http.MethodGet, "/api/v2/videos/reported", nil), &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Stream returns what a player loads for the ready video with id.
+func (c *Client) Stream(ctx context.Context, id uint) (*Stream, error) {
+	var out Stream
+	r := call{method: http.MethodGet, path: videoPath(id) + "/stream", replayable: true}
+	if err := c.do(ctx, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReportVideo reports a video in category, e.g. "spam" or "copyright".
+// Each user reports a video once; a second report fails with 409.
+func (c *Client) ReportVideo(ctx context.Context, id uint, category string) error {
+	r, err := jsonCall(http.MethodPost, videoPath(id)+"/reports", map[string]string{"category": category})
+	if err != nil {
+		return err
+	}
+	r.auth = true
+	return c.do(ctx, r, nil)
+}
+
+// DeleteVideo deletes a video; registered users may only delete their own.
+func (c *Client) DeleteVideo(ctx context.Context, id uint) error {
+	return c.do(ctx, c.authed(http.MethodDelete, videoPath(id), nil), nil)
+}
+
+// DismissReport dismisses a video's open reports. Administrator only.
+func (c *Client) DismissReport(ctx context.Context, id uint) error {
+	return c.do(ctx, c.authed(http.MethodDelete, videoPath(id)+"/reports", nil), nil)
+}
+
+func videoPath(id uint) string {
+	return "/api/v2/videos/" + strconv.FormatUint(uint64(id), 10)
+}
+
+type Upload struct {
+	Title       string
+	Description string
+	// The service recognizes MP4, MOV, WebM and MKV by their content, so
+	// Filename is informational
+	Filename string
+	Content  io.Reader
+}
+
+// UploadVideo streams a video to the video service and returns it, still
+// being processed. Registered users only. The body is not buffered, so
+// uploads are never retried.
+func (c *Client) UploadVideo(ctx context.Context, upload Upload) (*Video, error) {
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		err := form.WriteField("title", upload.Title)
+		if err == nil {
+			err = form.WriteField("description", upload.Description)
+		}
+		var part io.Writer
+		if err == nil {
+			part, err = form.CreateFormFile("file", upload.Filename)
+		}
+		if err == nil {
+			_, err = io.Copy(part, upload.Content)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+	// Stop the copy if the request fails before reading the whole body
+	defer reader.Close()
+
+	r := call{
+		method:      http.MethodPost,
+		path:        "/api/v2/videos",
+		body:        func() (io.Reader, error) { return reader, nil },
+		contentType: form.FormDataContentType(),
+		auth:        true,
+	}
+	var out Video
+	if err := c.do(ctx, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"videoh/client"
+)
+
+func login(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	fs.StringVar(&a.cfg.URL, "url", a.cfg.URL, "REST API base URL")
+	fs.StringVar(&a.cfg.APIKey, "api-key", a.cfg.APIKey, "API Gateway key")
+	fs.StringVar(&a.cfg.WebSocketURL, "websocket-url", a.cfg.WebSocketURL, "support chat WebSocket URL, used by tail")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	email := fs.Arg(0)
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	a.cfg.Token = ""
+	c, err := a.connect()
+	if err != nil {
+		return err
+	}
+	token, err := c.Login(ctx, email, password)
+	if err != nil {
+		return err
+	}
+	a.cfg.Email, a.cfg.Token = email, token
+	if err := a.cfg.save(); err != nil {
+		return err
+	}
+
+	user, err := c.CurrentUser(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Logged in to %s as %s (%s)\n", a.cfg.URL, user.Email, user.Role)
+	return nil
+}
+
+// readPassword takes the password from VIDEOH_PASSWORD, or the first line
+// of stdin, prompting without echo on a terminal.
+func readPassword() (string, error) {
+	if password := os.Getenv("VIDEOH_PASSWORD"); password != "" {
+		return password, nil
+	}
+	if isTerminal(os.Stdin) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		if stty("-echo") == nil {
+			defer func() {
+				stty("echo")
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		if err != nil {
+			return "", fmt.Errorf("reading password: %w", err)
+		}
+		return "", errors.New("empty password")
+	}
+	return password, nil
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+func logout(ctx context.Context, a *app, args []string) error {
+	if err := parse(flag.NewFlagSet("logout", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	a.cfg.Email, a.cfg.Token = "", ""
+	return a.cfg.save()
+}
+
+func whoami(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("whoami", flag.ContinueOnError)
+	a.outputFlag(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	c, err := a.authed()
+	if err != nil {
+		return err
+	}
+	user, err := c.CurrentUser(ctx)
+	if err != nil {
+		return err
+	}
+	return a.printUsers([]client.User{*user})
+}
+
+func users(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("users", flag.ContinueOnError)
+	a.outputFlag(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	c, err := a.authed()
+	if err != nil {
+		return err
+	}
+	list, err := c.RegisteredUsers(ctx)
+	if err != nil {
+		return err
+	}
+	return a.printUsers(list)
+}
+
+func (a *app) printUsers(list []client.User) error {
+	if a.format == formatJSON {
+		return writeJSON(os.Stdout, list)
+	}
+	t := newTable(os.Stdout, "ID", "EMAIL", "NAME", "ROLE", "BLOCKED", "REASON")
+	for _, user := range list {
+		t.row(strconv.FormatUint(uint64(user.ID), 10), user.Email, user.Name, user.Role.String(), yesNo(user.Blocked), user.BlockedReason)
+	}
+	return t.flush()
+}
+
+func reported(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("reported", flag.ContinueOnError)
+	a.outputFlag(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	c, err := a.authed()
+	if err != nil {
+		return err
+	}
+	videos, err := c.ReportedVideos(ctx)
+	if err != nil {
+		return err
+	}
+	if a.format == formatJSON {
+		return writeJSON(os.Stdout, videos)
+	}
+	t := newTable(os.Stdout, "ID", "TITLE", "OWNER", "FILENAME", "HIDDEN", "THUMBNAIL")
+	for _, video := range videos {
+		t.row(strconv.FormatUint(uint64(video.ID), 10), video.Title, video.OwnerEmail, video.Filename, strconv.FormatBool(video.Hidden), link("thumbnail", video.ThumbnailURL))
+	}
+	return t.flush()
+}
+
+func deleteVideo(ctx context.Context, a *app, args []string) error {
+	return videoAction(ctx, a, "delete", args, (*client.Client).DeleteVideo, "Deleted video %d\n")
+}
+
+func dismiss(ctx context.Context, a *app, args []string) error {
+	return videoAction(ctx, a, "dismiss", args, (*client.Client).DismissReport, "Dismissed reports of video %d\n")
+}
+
+func videoAction(ctx context.Context, a *app, name string, args []string, action func(*client.Client, context.Context, uint) error, done string) error {
+	if err := parse(flag.NewFlagSet(name, flag.ContinueOnError), args, 1); err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil || id == 0 {
+		return usageError("VIDEO_ID must be a positive integer")
+	}
+	c, err := a.authed()
+	if err != nil {
+		return err
+	}
+	if err := action(c, ctx, uint(id)); err != nil {
+		return err
+	}
+	fmt.Printf(done, id)
+	return nil
+}
+
+func block(ctx context.Context, a *app, args []string) error {
+	return blockAction(ctx, a, "block", args, (*client.Client).BlockUser, "Blocked %s\n")
+}
+
+func unblock(ctx context.Context, a *app, args []string) error {
+	return blockAction(ctx, a, "unblock", args, (*client.Client).UnblockUser, "Unblocked %s\n")
+}
+
+func blockAction(ctx context.Context, a *app, name string, args []string, action func(*client.Client, context.Context, uint, string) error, done string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	reason := fs.String("reason", "", "reason mailed to the user")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*reason) == "" {
+		return usageError("-reason is required")
+	}
+	c, err := a.authed()
+	if err != nil {
+		return err
+	}
+	id, err := userID(ctx, c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := action(c, ctx, id, *reason); err != nil {
+		return err
+	}
+	fmt.Printf(done, fs.Arg(0))
+	return nil
+}
+
+// userID takes a user ID, or looks up the registered user with that email.
+func userID(ctx context.Context, c *client.Client, user string) (uint, error) {
+	if id, err := strconv.ParseUint(user, 10, 64); err == nil && id > 0 {
+		return uint(id), nil
+	}
+	list, err := c.RegisteredUsers(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, candidate := range list {
+		if strings.EqualFold(candidate.Email, user) {
+			return candidate.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("no registered user with email %s", user)
+}
+
+func upload(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+	title := fs.String("title", "", "video title (default: the file name)")
+	description := fs.String("description", "", "video description")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	path := fs.Arg(0)
+	filename := filepath.Base(path)
+	if *title == "" {
+		*title = strings.TrimSuffix(filename, filepath.Ext(filename))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	c, err := a.authed()
+	if err != nil {
+		return err
+	}
+	video, err := c.UploadVideo(ctx, client.Upload{
+		Title:       *title,
+		Description: *description,
+		Filename:    filename,
+		Content:     file,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Uploaded video %d (%s)\n", video.ID, video.Status)
+	return nil
+}
+
+func conversations(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("conversations", flag.ContinueOnError)
+	a.outputFlag(fs)
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	c, err := a.authed()
+	if err != nil {
+		return err
+	}
+	emails, err := c.Conversations(ctx)
+	if err != nil {
+		return err
+	}
+	if a.format == formatJSON {
+		return writeJSON(os.Stdout, emails)
+	}
+	t := newTable(os.Stdout, "EMAIL")
+	for _, email := range emails {
+		t.row(email)
+	}
+	return t.flush()
+}
+
+// tail prints the last messages of a conversation, then follows it over
+// the WebSocket API until interrupted. With -o json it prints one message
+// per line.
+func tail(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	a.outputFlag(fs)
+	count := fs.Int("n", 20, "number of earlier messages to print")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	email := fs.Arg(0)
+	if a.cfg.WebSocketURL == "" {
+		return errors.New("no WebSocket URL configured (videoh login -websocket-url, or VIDEOH_WEBSOCKET_URL)")
+	}
+	c, err := a.authed()
+	if err != nil {
+		return err
+	}
+
+	history, err := c.Messages(ctx, email)
+	if err != nil {
+		return err
+	}
+	if *count >= 0 && len(history) > *count {
+		history = history[len(history)-*count:]
+	}
+	print := a.messagePrinter()
+	for _, message := range history {
+		print(message)
+	}
+
+	chat, err := c.DialChat(ctx, a.cfg.WebSocketURL, email)
+	if err != nil {
+		return err
+	}
+	defer chat.Close()
+	for {
+		message, err := chat.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, client.ErrChatClosed) {
+				return errors.New("conversation closed by the server")
+			}
+			return err
+		}
+		print(message)
+	}
+}
+
+func (a *app) messagePrinter() func(client.Message) {
+	if a.format == formatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		return func(message client.Message) { encoder.Encode(message) }
+	}
+	return func(message client.Message) {
+		from := "support"
+		if message.SentByUser {
+			from = message.OwnerEmail
+		}
+		date := message.Date
+		if date.IsZero() {
+			date = message.CreatedAt
+		}
+		fmt.Printf("%s  %s: %s\n", date.Local().Format(time.DateTime), from, message.Content)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// config is kept in the user config directory, e.g.
+// ~/.config/videoh/config.json. It holds the token, so it is only readable
+// by its owner.
+type config struct {
+	URL          string `json:"url"`
+	APIKey       string `json:"apiKey,omitempty"`
+	WebSocketURL string `json:"websocketUrl,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Token        string `json:"token,omitempty"`
+}
+
+func configPath() (string, error) {
+	if path := os.Getenv("VIDEOH_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "videoh", "config.json"), nil
+}
+
+// loadConfig reads the saved config; the environment overrides it.
+func loadConfig() (*config, error) {
+	cfg := &config{}
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, errors.New("invalid config " + path + ": " + err.Error())
+		}
+	}
+
+	if url := os.Getenv("VIDEOH_URL"); url != "" {
+		cfg.URL = url
+	}
+	if key := os.Getenv("VIDEOH_API_KEY"); key != "" {
+		cfg.APIKey = key
+	}
+	if url := os.Getenv("VIDEOH_WEBSOCKET_URL"); url != "" {
+		cfg.WebSocketURL = url
+	}
+	if cfg.URL == "" {
+		cfg.URL = "http://localhost:8080"
+	}
+	return cfg, nil
+}
+
+func (cfg *config) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0o600)
+}
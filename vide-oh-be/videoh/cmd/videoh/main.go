@@ -0,0 +1,161 @@
+// Command videoh is the vide-oh moderation CLI. It wraps the Go client for
+// the tasks administrators and support staff do outside the web app:
+//
+//	videoh login -url https://abc.execute-api.eu-central-1.amazonaws.com/dev admin@admin.com
+//	videoh reported
+//	videoh dismiss 42
+//	videoh block -reason "spam uploads" user@user.com
+//	videoh tail user@user.com
+//
+// Every listing prints a table, or JSON with -o json.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"videoh/client"
+)
+
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, app *app, args []string) error
+}
+
+var commands = map[string]command{
+	"login":         {"login [-url URL] [-api-key KEY] [-websocket-url URL] EMAIL", "log in and save the token; the password is read from VIDEOH_PASSWORD or stdin", login},
+	"logout":        {"logout", "forget the saved token", logout},
+	"whoami":        {"whoami [-o table|json]", "show the logged in user", whoami},
+	"users":         {"users [-o table|json]", "list registered users", users},
+	"reported":      {"reported [-o table|json]", "list reported videos", reported},
+	"delete":        {"delete VIDEO_ID", "delete a video", deleteVideo},
+	"dismiss":       {"dismiss VIDEO_ID", "dismiss the reports of a video and keep it", dismiss},
+	"block":         {"block -reason REASON EMAIL|USER_ID", "block a user and mail them the reason", block},
+	"unblock":       {"unblock -reason REASON EMAIL|USER_ID", "unblock a user and mail them the reason", unblock},
+	"upload":        {"upload [-title TITLE] [-description TEXT] FILE", "upload a video", upload},
+	"conversations": {"conversations [-o table|json]", "list users with support conversations", conversations},
+	"tail":          {"tail [-n COUNT] [-o table|json] EMAIL", "print a support conversation and follow new messages", tail},
+}
+
+// app is the state shared by the commands.
+type app struct {
+	cfg    *config
+	client *client.Client
+	format format
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "videoh: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fatal(err)
+	}
+	app := &app{cfg: cfg, format: formatTable}
+	if err := cmd.run(ctx, app, flag.Args()[1:]); err != nil {
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintf(os.Stderr, "videoh: %v\nusage: videoh %s\n", err, cmd.usage)
+			os.Exit(2)
+		}
+		fatal(err)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "usage: videoh COMMAND [flags] [args]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name].help)
+	}
+	fmt.Fprintln(os.Stderr, "\nVIDEOH_URL, VIDEOH_API_KEY and VIDEOH_WEBSOCKET_URL override the saved settings.")
+}
+
+func fatal(err error) {
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == 401 {
+		err = fmt.Errorf("%w (run videoh login)", err)
+	}
+	fmt.Fprintln(os.Stderr, "videoh:", err)
+	os.Exit(1)
+}
+
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// parse parses the flags of a command and checks the number of positional
+// arguments.
+func parse(fs *flag.FlagSet, args []string, nargs int) error {
+	fs.SetOutput(discard{})
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if fs.NArg() != nargs {
+		return usageError(fmt.Sprintf("expected %d argument(s), got %d", nargs, fs.NArg()))
+	}
+	return nil
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
+
+func (a *app) outputFlag(fs *flag.FlagSet) {
+	fs.Var(&a.format, "o", "output format: table or json")
+}
+
+// connect creates the client, sending the saved token.
+func (a *app) connect() (*client.Client, error) {
+	if a.client != nil {
+		return a.client, nil
+	}
+	opts := []client.Option{client.WithUserAgent("videoh-cli")}
+	if a.cfg.APIKey != "" {
+		opts = append(opts, client.WithAPIKey(a.cfg.APIKey))
+	}
+	if a.cfg.Token != "" {
+		opts = append(opts, client.WithToken(a.cfg.Token))
+	}
+	c, err := client.New(a.cfg.URL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	a.client = c
+	return c, nil
+}
+
+// authed is connect for commands that need a saved login.
+func (a *app) authed() (*client.Client, error) {
+	if a.cfg.Token == "" {
+		return nil, errors.New("not logged in (run videoh login)")
+	}
+	return a.connect()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+type format string
+
+const (
+	formatTable format = "table"
+	formatJSON  format = "json"
+)
+
+func (f *format) String() string { return string(*f) }
+
+func (f *format) Set(value string) error {
+	switch format(value) {
+	case formatTable, formatJSON:
+		*f = format(value)
+		return nil
+	}
+	return fmt.Errorf("must be table or json")
+}
+
+// table collects rows and prints them aligned. Columns holding links go
+// last, so terminal hyperlink escapes do not throw off the alignment.
+type table struct {
+	w *tabwriter.Writer
+}
+
+func newTable(out io.Writer, header ...string) *table {
+	t := &table{w: tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)}
+	t.row(header...)
+	return t
+}
+
+func (t *table) row(cells ...string) {
+	for i, cell := range cells {
+		cells[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(cell)
+	}
+	fmt.Fprintln(t.w, strings.Join(cells, "\t"))
+}
+
+func (t *table) flush() error {
+	return t.w.Flush()
+}
+
+func writeJSON(out io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// link renders url as a clickable label on terminals that support OSC 8
+// hyperlinks, and as the bare URL when stdout is not a terminal.
+func link(label, url string) string {
+	if url == "" {
+		return "-"
+	}
+	if !isTerminal(os.Stdout) {
+		return url
+	}
+	return "\x1b]8;;" + url + "\x1b\\" + label + "\x1b]8;;\x1b\\"
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
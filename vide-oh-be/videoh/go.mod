@@ -0,0 +1,3 @@
+module videoh
+
+go 1.21